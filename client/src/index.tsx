@@ -12,6 +12,7 @@ import Project from './pages/judge/project';
 
 import AdminSettings from './pages/admin/settings';
 import Expo from './pages/Expo';
+import Feedback from './pages/Feedback';
 
 import './index.css';
 
@@ -50,6 +51,14 @@ const router = createBrowserRouter([
         path: '/expo',
         element: <Expo />,
     },
+    {
+        path: '/feedback/:token',
+        element: <Feedback />,
+    },
+    {
+        path: '/events/:eventId/feedback/:token',
+        element: <Feedback />,
+    },
     {
         path: '/admin/add-projects',
         element: <AddProjects />,
@@ -0,0 +1,68 @@
+import { useEffect, useState } from 'react';
+import { useParams } from 'react-router-dom';
+import Container from '../components/Container';
+import { getRequest } from '../api';
+
+const Feedback = () => {
+    const { eventId, token } = useParams();
+    const [feedback, setFeedback] = useState<ProjectFeedback | null>(null);
+    const [error, setError] = useState('');
+
+    // Fetch the team's feedback with the token from their link
+    useEffect(() => {
+        async function fetchFeedback() {
+            const path = eventId ? `/events/${eventId}/feedback/${token}` : `/feedback/${token}`;
+            const res = await getRequest<ProjectFeedback>(path);
+            if (res.status !== 200) {
+                setError(res.error || 'Could not load feedback, please try again later.');
+                return;
+            }
+            setFeedback(res.data as ProjectFeedback);
+        }
+
+        fetchFeedback();
+    }, [eventId, token]);
+
+    return (
+        <Container noCenter>
+            <h1 className="mt-4 text-4xl text-center font-bold">Project Feedback</h1>
+            <h2 className="text-2xl text-center font-bold text-primary">
+                <a href="/">{import.meta.env.VITE_JURY_NAME}</a>
+            </h2>
+            {error && <p className="mt-8 px-4 text-center text-error">{error}</p>}
+            {feedback && (
+                <div className="px-4 mb-4">
+                    <h3 className="mt-6 text-3xl font-bold">{feedback.name}</h3>
+                    <p className="text-light">
+                        Seen by {feedback.judges} judge{feedback.judges === 1 ? '' : 's'}
+                    </p>
+                    <h4 className="mt-6 text-xl font-bold">Scores</h4>
+                    <table className="w-full">
+                        <tbody>
+                            {feedback.categories.map((category) => (
+                                <tr key={category.category}>
+                                    <td className="py-1">{category.category}</td>
+                                    <td className="py-1 text-right">
+                                        {category.count > 0 ? category.average.toFixed(1) : '-'}
+                                    </td>
+                                </tr>
+                            ))}
+                        </tbody>
+                    </table>
+                    <h4 className="mt-6 text-xl font-bold">Notes from judges</h4>
+                    {feedback.notes.length === 0 ? (
+                        <p className="text-light">No notes were shared with your team.</p>
+                    ) : (
+                        feedback.notes.map((note, idx) => (
+                            <p key={idx} className="mt-2 p-2 border-lightest border-2 rounded-md whitespace-pre-wrap">
+                                {note}
+                            </p>
+                        ))
+                    )}
+                </div>
+            )}
+        </Container>
+    );
+};
+
+export default Feedback;
@@ -2,6 +2,12 @@ import Button from '../components/Button';
 import Container from '../components/Container';
 
 const App = () => {
+    // Pass on where to return to after logging in, e.g. a project a judge scanned before logging in
+    const returnTo = new URLSearchParams(window.location.search).get('return_to');
+    const loginHref =
+        `${import.meta.env.VITE_API_ORIGIN}/api/auth/keycloak/login` +
+        (returnTo ? `?return_to=${encodeURIComponent(returnTo)}` : '');
+
     return (
         <Container>
             <h1 className="text-7xl font-bold text-center mb-4 hover:animate-wiggle cursor-pointer">
@@ -12,7 +18,7 @@ const App = () => {
             <h2 className="text-primary text-3xl text-center font-bold mb-24">
                 {import.meta.env.VITE_JURY_NAME}
             </h2>
-            <Button href={`${import.meta.env.VITE_API_ORIGIN}/api/auth/keycloak/login`} type="primary">
+            <Button href={loginHref} type="primary">
                 Login
                 <p className="text-sm italic">via auth.durhack.com</p>
             </Button>
@@ -1,5 +1,5 @@
 import { useEffect, useState } from 'react';
-import { useParams } from 'react-router-dom';
+import { useNavigate, useParams } from 'react-router-dom';
 import Container from '../../components/Container';
 import JuryHeader from '../../components/JuryHeader';
 import Paragraph from '../../components/Paragraph';
@@ -11,12 +11,18 @@ import RawTextInput from '../../components/RawTextInput';
 
 const Project = () => {
     const { id } = useParams();
+    const navigate = useNavigate();
     const [project, setProject] = useState<null | JudgedProjectWithUrl>(null);
     const [notes, setNotes] = useState('');
 
     useEffect(() => {
         async function fetchData() {
             const projRes = await getRequest<JudgedProjectWithUrl>(`/judge/project/${id}`);
+            if (projRes.status === 401) {
+                // Log in and come back to this project
+                navigate(`/?return_to=${encodeURIComponent(window.location.pathname)}`);
+                return;
+            }
             if (projRes.status !== 200) {
                 errorAlert(projRes);
                 return;
@@ -13,6 +13,7 @@ interface Project {
     challenge_list: string[];
     seen: number;
     active: boolean;
+    absent: boolean;
     score: number;
     last_activity: number;
 }
@@ -120,6 +121,9 @@ interface Flag {
     judge_name: string;
     project_location: string;
     reason: string;
+    resolved: boolean;
+    resolved_at: number;
+    resolved_by_name: string;
 }
 
 interface Options {
@@ -149,3 +153,16 @@ interface ScoredItem {
     id: string;
     score: number;
 }
+
+interface CategoryFeedback {
+    category: string;
+    average: number;
+    count: number;
+}
+
+interface ProjectFeedback {
+    name: string;
+    judges: number;
+    categories: CategoryFeedback[];
+    notes: string[];
+}
@@ -56,6 +56,15 @@ function showTopFive<T>(arr: T[]): string {
 }
 
 function errorAlert<T>(res: FetchResponse<T>) {
+    // If the session has expired, log in again in a new tab so that work on this page isn't lost
+    const loginUrl = (res.data as { login_url?: string } | null)?.login_url;
+    if (res.status === 401 && loginUrl) {
+        if (confirm('Your session has expired. Log in again in a new tab? Your work on this page will be kept.')) {
+            window.open(loginUrl, '_blank');
+        }
+        return;
+    }
+
     const err = `Error sending request to server (Status ${res.status}): ${res.error}`;
     alert(err);
     console.error(err);
@@ -2,47 +2,117 @@ package auth
 
 import (
 	"context"
-	"github.com/Nerzal/gocloak/v13"
-	"log"
-	"server/config"
+	"server/models"
+	"server/util"
 	"time"
-)
 
-var (
-	keycloakAdminClient                     *gocloak.GoCloak
-	KeycloakAdminClient                             = getKeycloakAdminClient()
-	keycloakAdminClientAccessToken          *string = nil
-	keycloakAdminClientAccessTokenExpiresAt *int64  = nil // Seconds since epoch
+	"github.com/Nerzal/gocloak/v13"
 )
 
-func getKeycloakAdminClient() *gocloak.GoCloak {
-	if keycloakAdminClient != nil {
-		return keycloakAdminClient
+// adminAccessToken returns an access token for the admin client, logging in again if it has expired
+func (k *KeycloakProvider) adminAccessToken(ctx context.Context) (string, error) {
+	k.adminTokenMutex.Lock()
+	defer k.adminTokenMutex.Unlock()
+
+	if k.adminToken != nil && k.adminTokenExpiresAt >= time.Now().Unix() {
+		return *k.adminToken, nil
 	}
 
-	ctx := context.Background()
+	jwt, err := k.adminClient.LoginClient(ctx, k.config.ClientId, k.config.ClientSecret, k.config.Realm)
+	if err != nil {
+		return "", err
+	}
+	// 10 seconds just to accommodate request time
+	k.adminTokenExpiresAt = time.Now().Unix() + int64(jwt.ExpiresIn) - 10
+	k.adminToken = &jwt.AccessToken
+	return jwt.AccessToken, nil
+}
 
-	keycloakAdminClient = gocloak.NewClient(config.KeycloakAdminBaseUrl)
-	_, err := keycloakAdminClient.LoginClient(ctx, config.ClientID, clientSecret, config.KeycloakRealm)
+// GetUserProfile gets the judge profile of a keycloak user. Missing name attributes are left empty.
+func (k *KeycloakProvider) GetUserProfile(ctx context.Context, userId string) (*models.JudgeProfile, error) {
+	accessToken, err := k.adminAccessToken(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return keycloakAdminClient
+	user, err := k.adminClient.GetUserByID(ctx, accessToken, k.config.Realm, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes map[string][]string
+	if user.Attributes != nil {
+		attributes = *user.Attributes
+	}
+	firstAttribute := func(name string) *string {
+		if values := attributes[name]; len(values) > 0 {
+			return &values[0]
+		}
+		return nil
+	}
+
+	profile := &models.JudgeProfile{
+		PreferredNames: firstAttribute("preferredNames"),
+		FirstNames:     gocloak.PString(firstAttribute("firstNames")),
+		LastNames:      gocloak.PString(firstAttribute("lastNames")),
+		Email:          gocloak.PString(user.Email),
+		SyncedAt:       util.Now(),
+	}
+	if profile.FirstNames == "" {
+		profile.FirstNames = gocloak.PString(user.FirstName)
+	}
+	if profile.LastNames == "" {
+		profile.LastNames = gocloak.PString(user.LastName)
+	}
+	return profile, nil
 }
 
-func GetKeycloakAdminClientAccessToken(ctx context.Context) (*string, error) {
-	if keycloakAdminClientAccessToken != nil && *keycloakAdminClientAccessTokenExpiresAt >= time.Now().Unix() {
-		return keycloakAdminClientAccessToken, nil
+// FindOrCreateUser finds the user with an email address, creating them with the given names if there is none.
+// Returns the ID of the user and whether they were created.
+func (k *KeycloakProvider) FindOrCreateUser(ctx context.Context, email string, firstNames string, lastNames string) (string, bool, error) {
+	accessToken, err := k.adminAccessToken(ctx)
+	if err != nil {
+		return "", false, err
 	}
 
-	jwt, err := keycloakAdminClient.LoginClient(ctx, config.ClientID, clientSecret, config.KeycloakRealm)
+	users, err := k.adminClient.GetUsers(ctx, accessToken, k.config.Realm, gocloak.GetUsersParams{
+		Email: gocloak.StringP(email),
+		Exact: gocloak.BoolP(true),
+	})
 	if err != nil {
-		return nil, err
+		return "", false, err
 	}
-	// 10 seconds just to accommodate request time
-	expiresAt := time.Now().Unix() + int64(jwt.ExpiresIn) - 10
-	keycloakAdminClientAccessTokenExpiresAt = &expiresAt
-	keycloakAdminClientAccessToken = &jwt.AccessToken
-	return keycloakAdminClientAccessToken, nil
+	if len(users) > 0 {
+		return gocloak.PString(users[0].ID), false, nil
+	}
+
+	id, err := k.adminClient.CreateUser(ctx, accessToken, k.config.Realm, gocloak.User{
+		Username:  gocloak.StringP(email),
+		Email:     gocloak.StringP(email),
+		FirstName: gocloak.StringP(firstNames),
+		LastName:  gocloak.StringP(lastNames),
+		Enabled:   gocloak.BoolP(true),
+		Attributes: &map[string][]string{
+			"firstNames": {firstNames},
+			"lastNames":  {lastNames},
+		},
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return id, true, nil
+}
+
+// AddUserToGroup adds a user to the group with a path, e.g. /judges
+func (k *KeycloakProvider) AddUserToGroup(ctx context.Context, userId string, group string) error {
+	accessToken, err := k.adminAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	keycloakGroup, err := k.adminClient.GetGroupByPath(ctx, accessToken, k.config.Realm, group)
+	if err != nil {
+		return err
+	}
+	return k.adminClient.AddUserToGroup(ctx, accessToken, k.config.Realm, userId, gocloak.PString(keycloakGroup.ID))
 }
@@ -1,21 +1,30 @@
 package auth
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"server/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nerzal/gocloak/v13"
 	"github.com/coreos/go-oidc/v3/oidc"
-	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
-	"log"
-	"net/url"
-	"server/config"
 )
 
 type DurHackKeycloakUserInfo struct {
 	// KeycloakUserInfo structure available at:
 	//https://github.com/ducompsoc/durhack/blob/130a71ab674288cbe1a6e0e2f3a518773658bc9f/server/src/lib/keycloak-client.ts#L47
+	// The local provider fills in the same fields for its users.
 	oidc.UserInfo
 	Groups         []string `json:"groups"`
 	PreferredNames *string  `json:"preferred_names"` // preferred_names can be null
 	FirstNames     string   `json:"first_names"`
+	LastNames      string   `json:"last_names"`
 }
 
 func (p *DurHackKeycloakUserInfo) GetNames() string {
@@ -25,67 +34,198 @@ func (p *DurHackKeycloakUserInfo) GetNames() string {
 	return p.FirstNames
 }
 
-type DurHackKeycloakProvider struct {
-	*oidc.Provider
+// GetFullName returns the names the user goes by followed by their last names
+func (p *DurHackKeycloakUserInfo) GetFullName() string {
+	return strings.TrimSpace(p.GetNames() + " " + p.LastNames)
+}
+
+// GetProfile returns the judge profile from the user info. Last names aren't always included in the user info,
+// so the last names of the current profile are kept if there are none.
+func (p *DurHackKeycloakUserInfo) GetProfile(current *models.JudgeProfile) *models.JudgeProfile {
+	profile := &models.JudgeProfile{
+		PreferredNames: p.PreferredNames,
+		FirstNames:     p.FirstNames,
+		LastNames:      p.LastNames,
+		Email:          p.Email,
+		SyncedAt:       current.SyncedAt,
+	}
+	if profile.LastNames == "" {
+		profile.LastNames = current.LastNames
+	}
+	return profile
+}
+
+type KeycloakConfig struct {
+	BaseUrl      string
+	AdminBaseUrl string
+	Realm        string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+}
+
+// KeycloakProvider authenticates users with keycloak over OpenID Connect and manages their accounts with the
+// keycloak admin API. Keycloak is only contacted when it is first needed, so the server can start without it.
+type KeycloakProvider struct {
+	config      KeycloakConfig
+	adminClient *gocloak.GoCloak
+
+	// Set on first use by discover
+	discoverMutex sync.Mutex
+	provider      *oidc.Provider
+	verifier      *oidc.IDTokenVerifier
+	oauth2Config  *oauth2.Config
+
+	// Access token of the admin client, renewed when it expires
+	adminTokenMutex     sync.Mutex
+	adminToken          *string
+	adminTokenExpiresAt int64 // Seconds since epoch
+}
+
+func NewKeycloakProvider(config KeycloakConfig) (*KeycloakProvider, error) {
+	if config.ClientId == "" || config.ClientSecret == "" {
+		return nil, errors.New("KEYCLOAK_OAUTH2_CLIENT_ID and KEYCLOAK_OAUTH2_CLIENT_SECRET must be set to use keycloak")
+	}
+	return &KeycloakProvider{
+		config:      config,
+		adminClient: gocloak.NewClient(config.AdminBaseUrl),
+	}, nil
 }
 
-func (p *DurHackKeycloakProvider) UserInfo(ctx context.Context, tokenSource oauth2.TokenSource) (*DurHackKeycloakUserInfo, error) {
-	userInfo, err := p.Provider.UserInfo(ctx, tokenSource)
+// discover fetches keycloak's OpenID configuration, unless it already has been
+func (k *KeycloakProvider) discover(ctx context.Context) error {
+	k.discoverMutex.Lock()
+	defer k.discoverMutex.Unlock()
+	if k.provider != nil {
+		return nil
+	}
+
+	providerUrl, err := url.JoinPath(k.config.BaseUrl, "/realms/", k.config.Realm)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	durhackUserInfo := &DurHackKeycloakUserInfo{UserInfo: *userInfo}
-	err = durhackUserInfo.Claims(durhackUserInfo)
+	// The provider keeps using this context to fetch signing keys, so it must outlive the request
+	provider, err := oidc.NewProvider(context.WithoutCancel(ctx), providerUrl)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	k.provider = provider
+	k.verifier = provider.Verifier(&oidc.Config{ClientID: k.config.ClientId})
+	k.oauth2Config = &oauth2.Config{
+		ClientID:     k.config.ClientId,
+		ClientSecret: k.config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  k.config.RedirectUrl,
+		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
 	}
-	return durhackUserInfo, nil
+	return nil
 }
 
-var (
-	clientSecret         = config.GetEnv("KEYCLOAK_OAUTH2_CLIENT_SECRET")
-	keycloakOIDCProvider *DurHackKeycloakProvider
-	keycloakOAuth2Config *oauth2.Config
-	KeycloakOIDCProvider = getKeycloakOIDCProvider()
-	KeycloakOAuth2Config = getKeycloakOAuth2Config()
-)
+// verifyIdToken verifies an ID token locally, using keycloak's signing keys which are fetched once and cached,
+// and returns the user info in its claims along with when the token expires. The nonce is only checked if given,
+// since refreshed ID tokens may not have one.
+func (k *KeycloakProvider) verifyIdToken(ctx context.Context, rawIdToken string, nonce string) (*DurHackKeycloakUserInfo, time.Time, error) {
+	idToken, err := k.verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if nonce != "" && subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return nil, time.Time{}, ErrNonceMismatch
+	}
+	durhackUserInfo := &DurHackKeycloakUserInfo{}
+	err = idToken.Claims(durhackUserInfo)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return durhackUserInfo, idToken.Expiry, nil
+}
 
-func getKeycloakOIDCProvider() *DurHackKeycloakProvider {
-	if keycloakOIDCProvider != nil {
-		return keycloakOIDCProvider
+// loginFromToken verifies the ID token of a token response from keycloak
+func (k *KeycloakProvider) loginFromToken(ctx context.Context, token *oauth2.Token, nonce string) (*Login, error) {
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no id token")
 	}
+	userInfo, expiry, err := k.verifyIdToken(ctx, idToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return NewLogin(userInfo, token, idToken, expiry), nil
+}
 
-	ctx := context.Background()
+func (k *KeycloakProvider) LoginUrl(ctx context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	err := k.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return k.oauth2Config.AuthCodeURL(state, oauth2.S256ChallengeOption(codeVerifier), oidc.Nonce(nonce)), nil
+}
 
-	keycloakProviderUrl, err := url.JoinPath(config.KeycloakBaseUrl, "/realms/", config.KeycloakRealm)
+func (k *KeycloakProvider) CompleteLogin(ctx context.Context, code string, codeVerifier string, nonce string) (*Login, error) {
+	err := k.discover(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	provider, err := oidc.NewProvider(ctx, keycloakProviderUrl)
+	token, err := k.oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	keycloakOIDCProvider = &DurHackKeycloakProvider{Provider: provider}
+	return k.loginFromToken(ctx, token, nonce)
+}
 
-	return keycloakOIDCProvider
+// RefreshLogin verifies the stored ID token locally if the tokens are still valid. The tokens are only
+// refreshed with keycloak if they have expired or the ID token can't be verified.
+func (k *KeycloakProvider) RefreshLogin(ctx context.Context, token *oauth2.Token, idToken string) (*Login, error) {
+	err := k.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the stored tokens if they are still valid
+	if token.Valid() {
+		userInfo, expiry, err := k.verifyIdToken(ctx, idToken, "")
+		if err == nil {
+			return NewLogin(userInfo, token, idToken, expiry), nil
+		}
+	}
+
+	// Otherwise refresh them, making sure the token source doesn't hand back the same access token
+	expiredToken := *token
+	expiredToken.Expiry = time.Unix(1, 0)
+	newToken, err := k.oauth2Config.TokenSource(ctx, &expiredToken).Token()
+	if err != nil {
+		return nil, err
+	}
+	return k.loginFromToken(ctx, newToken, "")
 }
 
-func getKeycloakOAuth2Config() *oauth2.Config {
-	if keycloakOAuth2Config != nil {
-		return keycloakOAuth2Config
+func (k *KeycloakProvider) LogoutUrl(ctx context.Context, idToken string, redirectUrl string) (string, error) {
+	err := k.discover(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	parsedUrl, err := url.JoinPath(config.ApiOrigin, "/api/auth/keycloak/callback")
+	// See https://github.com/coreos/go-oidc/pull/226#issuecomment-1130411016
+	var claims struct {
+		EndSessionURL string `json:"end_session_endpoint"`
+	}
+	err = k.provider.Claims(&claims)
 	if err != nil {
-		log.Fatalf("Failed to create keycloak callback URL: %v", err)
+		return "", err
 	}
-	keycloakOAuth2Config := &oauth2.Config{
-		ClientID:     config.ClientID,
-		ClientSecret: clientSecret,
-		Endpoint:     getKeycloakOIDCProvider().Endpoint(),
-		RedirectURL:  parsedUrl,
-		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+	parsedURL, err := url.Parse(claims.EndSessionURL)
+	if err != nil {
+		return "", err
 	}
 
-	return keycloakOAuth2Config
+	queryValues := parsedURL.Query()
+	queryValues.Add("id_token_hint", idToken)
+	queryValues.Add("post_logout_redirect_uri", redirectUrl)
+	parsedURL.RawQuery = queryValues.Encode()
+	return parsedURL.String(), nil
+}
+
+func (k *KeycloakProvider) InGroup(user *DurHackKeycloakUserInfo, group string) bool {
+	return slices.Contains(user.Groups, group)
 }
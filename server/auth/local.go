@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"server/config"
+	"server/models"
+	"server/util"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+)
+
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// LocalUser is a user of the local provider. Users should have a bcrypt PasswordHash; a plain text Password
+// is also accepted for development.
+type LocalUser struct {
+	Id             string   `json:"id"`
+	Email          string   `json:"email"`
+	Password       string   `json:"password"`
+	PasswordHash   string   `json:"password_hash"`
+	PreferredNames *string  `json:"preferred_names"`
+	FirstNames     string   `json:"first_names"`
+	LastNames      string   `json:"last_names"`
+	Groups         []string `json:"groups"`
+}
+
+type localCode struct {
+	userId        string
+	codeChallenge string
+	nonce         string
+	expiresAt     time.Time
+}
+
+// LocalProvider authenticates the users listed in a JSON file, of the form {"users": [...]}, with a login form
+// served by the server itself. It never contacts keycloak, and can't create users or change their groups.
+type LocalProvider struct {
+	users         []*LocalUser
+	loginDuration time.Duration
+
+	mutex sync.Mutex
+	codes map[string]localCode // One-time codes given to the login callback
+}
+
+// LoadLocalProvider reads the users of the local provider from a file
+func LoadLocalProvider(path string) (*LocalProvider, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading local users file: %w", err)
+	}
+	var file struct {
+		Users []*LocalUser `json:"users"`
+	}
+	err = json.Unmarshal(content, &file)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing local users file: %w", err)
+	}
+	return NewLocalProvider(file.Users)
+}
+
+func NewLocalProvider(users []*LocalUser) (*LocalProvider, error) {
+	ids := make(map[string]bool, len(users))
+	for i, user := range users {
+		if user.Id == "" || user.Email == "" {
+			return nil, fmt.Errorf("local user %d must have an id and an email", i+1)
+		}
+		if ids[user.Id] {
+			return nil, fmt.Errorf("local user id %s is used more than once", user.Id)
+		}
+		ids[user.Id] = true
+	}
+	return &LocalProvider{users: users, loginDuration: 12 * time.Hour, codes: make(map[string]localCode)}, nil
+}
+
+func (l *LocalProvider) findUser(userId string) *LocalUser {
+	for _, user := range l.users {
+		if user.Id == userId {
+			return user
+		}
+	}
+	return nil
+}
+
+func (l *LocalProvider) findUserByEmail(email string) *LocalUser {
+	for _, user := range l.users {
+		if strings.EqualFold(user.Email, email) {
+			return user
+		}
+	}
+	return nil
+}
+
+func (u *LocalUser) checkPassword(password string) bool {
+	if u.PasswordHash != "" {
+		return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+	}
+	return u.Password != "" && subtle.ConstantTimeCompare([]byte(u.Password), []byte(password)) == 1
+}
+
+func (u *LocalUser) userInfo() *DurHackKeycloakUserInfo {
+	userInfo := &DurHackKeycloakUserInfo{
+		Groups:         u.Groups,
+		PreferredNames: u.PreferredNames,
+		FirstNames:     u.FirstNames,
+		LastNames:      u.LastNames,
+	}
+	userInfo.Subject = u.Id
+	userInfo.Email = u.Email
+	userInfo.EmailVerified = true
+	return userInfo
+}
+
+// Authorise checks a user's email and password from the login form, and returns a one-time code for the
+// login callback. The code can only be exchanged with the verifier of the code challenge and the same nonce.
+func (l *LocalProvider) Authorise(email string, password string, codeChallenge string, nonce string) (string, error) {
+	user := l.findUserByEmail(email)
+	if user == nil || !user.checkPassword(password) {
+		return "", ErrInvalidCredentials
+	}
+
+	code, err := util.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	for other, otherCode := range l.codes {
+		if now.After(otherCode.expiresAt) {
+			delete(l.codes, other)
+		}
+	}
+	l.codes[code] = localCode{userId: user.Id, codeChallenge: codeChallenge, nonce: nonce, expiresAt: now.Add(time.Minute)}
+	return code, nil
+}
+
+// LoginUrl returns the URL of the local login form
+func (l *LocalProvider) LoginUrl(_ context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	loginUrl, err := url.JoinPath(config.ApiOrigin, "/api/auth/local/login")
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", oauth2.S256ChallengeFromVerifier(codeVerifier))
+	return loginUrl + "?" + query.Encode(), nil
+}
+
+func (l *LocalProvider) CompleteLogin(_ context.Context, code string, codeVerifier string, nonce string) (*Login, error) {
+	l.mutex.Lock()
+	localCode, ok := l.codes[code]
+	delete(l.codes, code)
+	l.mutex.Unlock()
+
+	if !ok || time.Now().After(localCode.expiresAt) {
+		return nil, errors.New("invalid or expired login code")
+	}
+	if oauth2.S256ChallengeFromVerifier(codeVerifier) != localCode.codeChallenge {
+		return nil, errors.New("code verifier doesn't match the code challenge")
+	}
+	if subtle.ConstantTimeCompare([]byte(localCode.nonce), []byte(nonce)) != 1 {
+		return nil, ErrNonceMismatch
+	}
+	user := l.findUser(localCode.userId)
+	if user == nil {
+		return nil, errors.New("user no longer exists")
+	}
+
+	// The local provider has no real tokens, so the ID token is just the user's ID
+	accessToken, err := util.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{AccessToken: accessToken, TokenType: "local", Expiry: time.Now().Add(l.loginDuration)}
+	return &Login{UserInfo: user.userInfo(), Token: token, IdToken: user.Id, Expiry: token.Expiry}, nil
+}
+
+func (l *LocalProvider) RefreshLogin(_ context.Context, token *oauth2.Token, idToken string) (*Login, error) {
+	if !token.Valid() {
+		return nil, errors.New("login has expired")
+	}
+	user := l.findUser(idToken)
+	if user == nil {
+		return nil, errors.New("user no longer exists")
+	}
+	return &Login{UserInfo: user.userInfo(), Token: token, IdToken: idToken, Expiry: token.Expiry}, nil
+}
+
+func (l *LocalProvider) LogoutUrl(_ context.Context, _ string, redirectUrl string) (string, error) {
+	return redirectUrl, nil
+}
+
+func (l *LocalProvider) InGroup(user *DurHackKeycloakUserInfo, group string) bool {
+	return slices.Contains(user.Groups, group)
+}
+
+func (l *LocalProvider) GetUserProfile(_ context.Context, userId string) (*models.JudgeProfile, error) {
+	user := l.findUser(userId)
+	if user == nil {
+		return nil, fmt.Errorf("no local user with id %s", userId)
+	}
+	return &models.JudgeProfile{
+		PreferredNames: user.PreferredNames,
+		FirstNames:     user.FirstNames,
+		LastNames:      user.LastNames,
+		Email:          user.Email,
+		SyncedAt:       util.Now(),
+	}, nil
+}
+
+// FindOrCreateUser finds the local user with an email address. Local users can't be created, so they must
+// already be in the users file.
+func (l *LocalProvider) FindOrCreateUser(_ context.Context, email string, _ string, _ string) (string, bool, error) {
+	user := l.findUserByEmail(email)
+	if user == nil {
+		return "", false, fmt.Errorf("no local user with email %s, add them to the local users file", email)
+	}
+	return user.Id, false, nil
+}
+
+// AddUserToGroup checks that a local user is already in a group, since the groups of local users can't be changed
+func (l *LocalProvider) AddUserToGroup(_ context.Context, userId string, group string) error {
+	user := l.findUser(userId)
+	if user == nil {
+		return fmt.Errorf("no local user with id %s", userId)
+	}
+	if !slices.Contains(user.Groups, group) {
+		return fmt.Errorf("local user %s is not in %s, add the group in the local users file", user.Email, group)
+	}
+	return nil
+}
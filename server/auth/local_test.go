@@ -0,0 +1,80 @@
+package auth_test
+
+import (
+	"context"
+	"server/auth"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func newLocalProvider(t *testing.T) *auth.LocalProvider {
+	provider, err := auth.NewLocalProvider([]*auth.LocalUser{
+		{Id: "judge", Email: "judge@example.com", Password: "secret", FirstNames: "Judith", Groups: []string{"/judges"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestLocalLogin(t *testing.T) {
+	provider := newLocalProvider(t)
+	verifier := oauth2.GenerateVerifier()
+	challenge := oauth2.S256ChallengeFromVerifier(verifier)
+
+	_, err := provider.Authorise("judge@example.com", "wrong", challenge, "nonce")
+	if err != auth.ErrInvalidCredentials {
+		t.Fatalf("Expected invalid credentials, got %v", err)
+	}
+
+	// Emails aren't case sensitive
+	code, err := provider.Authorise("Judge@Example.com", "secret", challenge, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The code can only be exchanged with the matching verifier
+	_, err = provider.CompleteLogin(context.Background(), code, oauth2.GenerateVerifier(), "nonce")
+	if err == nil {
+		t.Fatal("Expected a mismatched verifier to be rejected")
+	}
+	// And only with the nonce the login began with
+	code, _ = provider.Authorise("judge@example.com", "secret", challenge, "nonce")
+	_, err = provider.CompleteLogin(context.Background(), code, verifier, "other")
+	if err != auth.ErrNonceMismatch {
+		t.Fatalf("Expected a nonce mismatch, got %v", err)
+	}
+	code, _ = provider.Authorise("judge@example.com", "secret", challenge, "nonce")
+	login, err := provider.CompleteLogin(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.UserInfo.Subject != "judge" || !provider.InGroup(login.UserInfo, "/judges") {
+		t.Errorf("Expected the judge to be logged in, got %+v", login.UserInfo)
+	}
+
+	// Codes can only be used once
+	_, err = provider.CompleteLogin(context.Background(), code, verifier, "nonce")
+	if err == nil {
+		t.Error("Expected a used code to be rejected")
+	}
+
+	// Stored logins can be refreshed while they are valid
+	refreshed, err := provider.RefreshLogin(context.Background(), login.Token, login.IdToken)
+	if err != nil || refreshed.UserInfo.Subject != "judge" {
+		t.Errorf("Expected the login to refresh, got %v", err)
+	}
+}
+
+func TestLocalUsersCantBeCreated(t *testing.T) {
+	provider := newLocalProvider(t)
+	_, _, err := provider.FindOrCreateUser(context.Background(), "new@example.com", "New", "Judge")
+	if err == nil {
+		t.Error("Expected creating a local user to fail")
+	}
+	id, created, err := provider.FindOrCreateUser(context.Background(), "judge@example.com", "Judith", "Judge")
+	if err != nil || id != "judge" || created {
+		t.Errorf("Expected to find the existing judge, got %s %v %v", id, created, err)
+	}
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Login is a user's verified claims along with the tokens they were verified from
+type Login struct {
+	UserInfo *DurHackKeycloakUserInfo
+	Token    *oauth2.Token
+	IdToken  string
+	Expiry   time.Time // When the ID token or access token expires, whichever is sooner
+}
+
+// NewLogin creates a login which expires when either the ID token or the access token does
+func NewLogin(userInfo *DurHackKeycloakUserInfo, token *oauth2.Token, idToken string, idTokenExpiry time.Time) *Login {
+	expiry := idTokenExpiry
+	if !token.Expiry.IsZero() && token.Expiry.Before(expiry) {
+		expiry = token.Expiry
+	}
+	return &Login{UserInfo: userInfo, Token: token, IdToken: idToken, Expiry: expiry}
+}
+
+// LoginCache keeps verified logins in memory by user ID so that they don't need to be loaded and
+// verified on every request
+type LoginCache struct {
+	mutex  sync.Mutex
+	logins map[string]*Login
+}
+
+func NewLoginCache() *LoginCache {
+	return &LoginCache{logins: make(map[string]*Login)}
+}
+
+// Get returns the cached login of a user, or nil if there is none or it has expired
+func (c *LoginCache) Get(userId string) *Login {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	login, ok := c.logins[userId]
+	if !ok {
+		return nil
+	}
+	if !time.Now().Before(login.Expiry) {
+		delete(c.logins, userId)
+		return nil
+	}
+	return login
+}
+
+// Set caches the login of a user until it expires, dropping any other logins which have expired
+func (c *LoginCache) Set(userId string, login *Login) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for id, other := range c.logins {
+		if !now.Before(other.Expiry) {
+			delete(c.logins, id)
+		}
+	}
+	c.logins[userId] = login
+}
+
+// Forget removes the cached login of a user, e.g. when they log out
+func (c *LoginCache) Forget(userId string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.logins, userId)
+}
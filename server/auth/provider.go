@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"server/config"
+	"server/models"
+
+	"golang.org/x/oauth2"
+)
+
+var ErrNonceMismatch = errors.New("id token nonce doesn't match the login")
+
+// AuthProvider authenticates users and looks up and manages their accounts.
+// Keycloak is used in production. The local provider is for development, CI and as a fallback if keycloak goes down.
+type AuthProvider interface {
+	// LoginUrl returns the URL to send a user to in order to log in. The user is sent back to the login
+	// callback with a code and the state. The nonce is included in the ID token of the login.
+	LoginUrl(ctx context.Context, state string, nonce string, codeVerifier string) (string, error)
+	// CompleteLogin exchanges the code given to the login callback for a verified login, checking that its
+	// ID token has the nonce given to LoginUrl
+	CompleteLogin(ctx context.Context, code string, codeVerifier string, nonce string) (*Login, error)
+	// RefreshLogin verifies a stored login again, refreshing its tokens if needed.
+	// The returned login has a different access token if the tokens were refreshed.
+	RefreshLogin(ctx context.Context, token *oauth2.Token, idToken string) (*Login, error)
+	// LogoutUrl returns the URL to send a user to in order to log out, after which they are sent to redirectUrl
+	LogoutUrl(ctx context.Context, idToken string, redirectUrl string) (string, error)
+
+	// InGroup checks whether a user is in a group, e.g. /judges
+	InGroup(user *DurHackKeycloakUserInfo, group string) bool
+
+	// GetUserProfile gets the judge profile of a user
+	GetUserProfile(ctx context.Context, userId string) (*models.JudgeProfile, error)
+	// FindOrCreateUser finds the user with an email address, creating them with the given names if there is none.
+	// Returns the ID of the user and whether they were created.
+	FindOrCreateUser(ctx context.Context, email string, firstNames string, lastNames string) (string, bool, error)
+	// AddUserToGroup adds a user to a group, e.g. /judges
+	AddUserToGroup(ctx context.Context, userId string, group string) error
+}
+
+// NewProviderFromEnv creates the auth provider named by AUTH_PROVIDER, which is either keycloak (the default)
+// or local. The local provider reads its users from AUTH_LOCAL_USERS_FILE.
+func NewProviderFromEnv() (AuthProvider, error) {
+	switch name := config.GetOptEnv("AUTH_PROVIDER", ""); name {
+	case "", "keycloak":
+		redirectUrl, err := url.JoinPath(config.ApiOrigin, "/api/auth/keycloak/callback")
+		if err != nil {
+			return nil, err
+		}
+		return NewKeycloakProvider(KeycloakConfig{
+			BaseUrl:      config.KeycloakBaseUrl,
+			AdminBaseUrl: config.KeycloakAdminBaseUrl,
+			Realm:        config.KeycloakRealm,
+			ClientId:     config.ClientID,
+			ClientSecret: config.GetOptEnv("KEYCLOAK_OAUTH2_CLIENT_SECRET", ""),
+			RedirectUrl:  redirectUrl,
+		})
+	case "local":
+		usersFile := config.GetOptEnv("AUTH_LOCAL_USERS_FILE", "")
+		if usersFile == "" {
+			usersFile = "local-users.json"
+		}
+		return LoadLocalProvider(usersFile)
+	default:
+		return nil, fmt.Errorf("unknown AUTH_PROVIDER %q, expected keycloak or local", name)
+	}
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"server/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/oauth2"
+)
+
+var ErrUnknownTokenKey = errors.New("token set was encrypted with a key that is no longer configured")
+
+type tokenKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// TokenCipher encrypts the token sets of users before they are stored. Each token set is encrypted with a new
+// data key, which is encrypted with the first of the cipher's keys. The other keys can still decrypt token sets,
+// so that keys can be rotated.
+type TokenCipher struct {
+	keys []tokenKey
+}
+
+// sealedTokens is what is encrypted in a token set
+type sealedTokens struct {
+	Token   *oauth2.Token `json:"token"`
+	IdToken string        `json:"id_token"`
+}
+
+func NewTokenCipher(keys [][]byte) (*TokenCipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("token cipher needs at least one key")
+	}
+	tokenCipher := &TokenCipher{keys: make([]tokenKey, 0, len(keys))}
+	for i, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("token key %d must be 32 bytes long", i+1)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		// Keys are identified by a hash, so the key of a token set can be found without trying every key
+		hash := sha256.Sum256(key)
+		tokenCipher.keys = append(tokenCipher.keys, tokenKey{id: hex.EncodeToString(hash[:8]), aead: aead})
+	}
+	return tokenCipher, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts a message and prepends the random nonce it was encrypted with
+func seal(aead cipher.AEAD, message []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, message, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+}
+
+// IsCurrentKey checks whether a token set was encrypted with the key that new token sets are encrypted with
+func (c *TokenCipher) IsCurrentKey(tokenSet *models.TokenSet) bool {
+	return tokenSet.KeyId == c.keys[0].id
+}
+
+// Seal encrypts the tokens of a user. The token set is bound to the user, so it can't be decrypted as
+// anyone else's.
+func (c *TokenCipher) Seal(userId string, token *oauth2.Token, idToken string, expiresAt time.Time) (*models.TokenSet, error) {
+	message, err := json.Marshal(sealedTokens{Token: token, IdToken: idToken})
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, 32)
+	_, err = rand.Read(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(dataAEAD, message, []byte(userId))
+	if err != nil {
+		return nil, err
+	}
+
+	key := c.keys[0]
+	encryptedKey, err := seal(key.aead, dataKey, []byte(userId+":"+key.id))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenSet{
+		UserId:       userId,
+		KeyId:        key.id,
+		EncryptedKey: encryptedKey,
+		Ciphertext:   ciphertext,
+		ExpiresAt:    primitive.NewDateTimeFromTime(expiresAt),
+	}, nil
+}
+
+// Open decrypts the tokens of a token set. Returns ErrUnknownTokenKey if its key is no longer configured.
+func (c *TokenCipher) Open(tokenSet *models.TokenSet) (*oauth2.Token, string, error) {
+	var key *tokenKey
+	for i := range c.keys {
+		if c.keys[i].id == tokenSet.KeyId {
+			key = &c.keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return nil, "", ErrUnknownTokenKey
+	}
+
+	dataKey, err := open(key.aead, tokenSet.EncryptedKey, []byte(tokenSet.UserId+":"+key.id))
+	if err != nil {
+		return nil, "", fmt.Errorf("error decrypting data key: %w", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, "", err
+	}
+	message, err := open(dataAEAD, tokenSet.Ciphertext, []byte(tokenSet.UserId))
+	if err != nil {
+		return nil, "", fmt.Errorf("error decrypting tokens: %w", err)
+	}
+
+	var tokens sealedTokens
+	err = json.Unmarshal(message, &tokens)
+	if err != nil {
+		return nil, "", err
+	}
+	if tokens.Token == nil {
+		return nil, "", errors.New("token set has no token")
+	}
+	return tokens.Token, tokens.IdToken, nil
+}
+
+// TokenSetExpiry returns when a stored token set is no longer useful: when its refresh token expires, or when
+// its access token does if it has no refresh token. Keycloak's offline tokens don't say when they expire, so
+// they are kept for the fallback lifetime.
+func TokenSetExpiry(token *oauth2.Token, fallback time.Duration) time.Time {
+	if token.RefreshToken == "" {
+		return token.Expiry
+	}
+	var refreshExpiresIn int64
+	switch v := token.Extra("refresh_expires_in").(type) {
+	case float64:
+		refreshExpiresIn = int64(v)
+	case int64:
+		refreshExpiresIn = v
+	case int:
+		refreshExpiresIn = int64(v)
+	}
+	if refreshExpiresIn > 0 {
+		expiry := time.Now().Add(time.Duration(refreshExpiresIn) * time.Second)
+		if expiry.After(token.Expiry) {
+			return expiry
+		}
+		return token.Expiry
+	}
+	return time.Now().Add(fallback)
+}
@@ -0,0 +1,75 @@
+package auth_test
+
+import (
+	"bytes"
+	"server/auth"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func newTokenCipher(t *testing.T, keys ...byte) *auth.TokenCipher {
+	keyBytes := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		keyBytes = append(keyBytes, bytes.Repeat([]byte{key}, 32))
+	}
+	tokenCipher, err := auth.NewTokenCipher(keyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenCipher
+}
+
+func TestTokenCipherRoundTrip(t *testing.T) {
+	tokenCipher := newTokenCipher(t, 1)
+	token := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour).Round(time.Second)}
+
+	tokenSet, err := tokenCipher.Seal("judge", token, "id", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(tokenSet.Ciphertext, []byte("access")) || bytes.Contains(tokenSet.Ciphertext, []byte("refresh")) {
+		t.Fatal("Expected the tokens to be encrypted")
+	}
+
+	opened, idToken, err := tokenCipher.Open(tokenSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.AccessToken != "access" || opened.RefreshToken != "refresh" || !opened.Expiry.Equal(token.Expiry) || idToken != "id" {
+		t.Errorf("Expected the same tokens back, got %+v and %s", opened, idToken)
+	}
+
+	// A token set can't be used as someone else's
+	tokenSet.UserId = "admin"
+	_, _, err = tokenCipher.Open(tokenSet)
+	if err == nil {
+		t.Error("Expected a token set moved to another user to fail to decrypt")
+	}
+}
+
+func TestTokenCipherRotation(t *testing.T) {
+	oldCipher := newTokenCipher(t, 1)
+	tokenSet, err := oldCipher.Seal("judge", &oauth2.Token{AccessToken: "access"}, "id", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key can still decrypt token sets after a new key is added
+	rotatedCipher := newTokenCipher(t, 2, 1)
+	if rotatedCipher.IsCurrentKey(tokenSet) {
+		t.Error("Expected the old key not to be current")
+	}
+	_, _, err = rotatedCipher.Open(tokenSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// But not once it has been removed
+	newCipher := newTokenCipher(t, 2)
+	_, _, err = newCipher.Open(tokenSet)
+	if err != auth.ErrUnknownTokenKey {
+		t.Errorf("Expected an unknown key error, got %v", err)
+	}
+}
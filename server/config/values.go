@@ -15,6 +15,7 @@ var ( // this is run first before init
 	KeycloakAdminBaseUrl string
 	ClientID             string
 	DatabaseName         string
+	DefaultEventName     string
 )
 
 func init() {
@@ -30,6 +31,7 @@ func init() {
 	KeycloakRealm = GetOptEnv("KEYCLOAK_REALM", "durhack-dev")
 	KeycloakBaseUrl = GetOptEnv("KEYCLOAK_BASE_URL", "https://auth.durhack.com")
 	KeycloakAdminBaseUrl = GetOptEnv("KEYCLOAK_ADMIN_BASE_URL", "https://admin.auth.durhack.com")
-	ClientID = GetEnv("KEYCLOAK_OAUTH2_CLIENT_ID")
+	ClientID = GetOptEnv("KEYCLOAK_OAUTH2_CLIENT_ID", "")
 	DatabaseName = GetEnv("DATABASE_NAME")
+	DefaultEventName = GetOptEnv("DEFAULT_EVENT_NAME", "DurHack")
 }
@@ -6,6 +6,7 @@ import (
 	"server/models"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -13,14 +14,14 @@ type AvgSeenAgg struct {
 	AvgSeen float64 `bson:"avgSeen"`
 }
 
-// AggregateStats aggregates all stats from the database.
-func AggregateStats(db *mongo.Database) (*models.Stats, error) {
+// AggregateStats aggregates all stats of an event from the database.
+func AggregateStats(db *mongo.Database, eventId primitive.ObjectID) (*models.Stats, error) {
 	// Get the total number of projects and judges
-	totalProjects, err := db.Collection("projects").EstimatedDocumentCount(context.Background())
+	totalProjects, err := db.Collection("projects").CountDocuments(context.Background(), gin.H{"event_id": eventId})
 	if err != nil {
 		return nil, err
 	}
-	totalJudges, err := db.Collection("judges").EstimatedDocumentCount(context.Background())
+	totalJudges, err := db.Collection("judges").CountDocuments(context.Background(), gin.H{"event_id": eventId})
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +29,7 @@ func AggregateStats(db *mongo.Database) (*models.Stats, error) {
 	// Get the average project seen using an aggregation pipeline
 	projCursor, err := db.Collection("projects").Aggregate(context.Background(), []gin.H{
 		// {"$match": gin.H{"active": true}},
+		{"$match": gin.H{"event_id": eventId}},
 		{"$group": gin.H{
 			"_id": nil,
 			"avgSeen": gin.H{
@@ -52,14 +54,14 @@ func AggregateStats(db *mongo.Database) (*models.Stats, error) {
 		}
 	}
 
-	numHiddenProjects, err := db.Collection("projects").CountDocuments(context.Background(), gin.H{"active": false})
+	numHiddenProjects, err := db.Collection("projects").CountDocuments(context.Background(), gin.H{"event_id": eventId, "active": false})
 	if err != nil {
 		return nil, err
 	}
 
 	// Get the average judge seen using an aggregation pipeline
 	judgeCursor, err := db.Collection("judges").Aggregate(context.Background(), []gin.H{
-		{"$match": gin.H{"active": true}},
+		{"$match": gin.H{"event_id": eventId, "active": true}},
 		{"$group": gin.H{
 			"_id": nil,
 			"avgSeen": gin.H{
@@ -96,12 +98,10 @@ func AggregateStats(db *mongo.Database) (*models.Stats, error) {
 	return &stats, nil
 }
 
-// DropAll drops the entire database
-func DropAll(db *mongo.Database) error {
-	// Drop all collections
-	var collections = []string{"projects", "judges", "flags", "options"}
-	for _, c := range collections {
-		if err := db.Collection(c).Drop(context.Background()); err != nil {
+// DropAll deletes all projects, judges, flags and options of an event
+func DropAll(db *mongo.Database, eventId primitive.ObjectID) error {
+	for _, c := range eventCollections {
+		if _, err := db.Collection(c).DeleteMany(context.Background(), gin.H{"event_id": eventId}); err != nil {
 			return err
 		}
 	}
@@ -112,35 +112,41 @@ func DropAll(db *mongo.Database) error {
 // UpdateOptions updates the options in the database
 func UpdateOptions(db *mongo.Database, options *models.Options) error {
 	// Update the options
-	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{}, gin.H{"$set": options})
+	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{"event_id": options.EventId}, gin.H{"$set": options})
 	return err
 }
 
 // UpdateCategories updates the categories in the database
-func UpdateCategories(db *mongo.Database, categories []string) error {
+func UpdateCategories(db *mongo.Database, eventId primitive.ObjectID, categories []string) error {
 	// Update the categories
-	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{}, gin.H{"$set": gin.H{"categories": categories}})
+	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{"event_id": eventId}, gin.H{"$set": gin.H{"categories": categories}})
 	return err
 }
 
 // UpdateMinViews will update the min views setting
-func UpdateMinViews(db *mongo.Database, minViews int) error {
+func UpdateMinViews(db *mongo.Database, eventId primitive.ObjectID, minViews int) error {
 	// Update the min views
 	println(minViews)
-	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{}, gin.H{"$set": gin.H{"min_views": minViews}})
+	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{"event_id": eventId}, gin.H{"$set": gin.H{"min_views": minViews}})
 	return err
 }
 
 // UpdateBatchRankingSize will update the min views setting
-func UpdateBatchRankingSize(db *mongo.Database, batchRankingSize int) error {
+func UpdateBatchRankingSize(db *mongo.Database, eventId primitive.ObjectID, batchRankingSize int) error {
 	// Update the min views
-	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{}, gin.H{"$set": gin.H{"batch_ranking_size": batchRankingSize}})
+	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{"event_id": eventId}, gin.H{"$set": gin.H{"batch_ranking_size": batchRankingSize}})
+	return err
+}
+
+// UpdateDevpostColumns will update the Devpost CSV column mapping
+func UpdateDevpostColumns(db *mongo.Database, eventId primitive.ObjectID, columns *models.DevpostColumns) error {
+	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{"event_id": eventId}, gin.H{"$set": gin.H{"devpost_columns": columns}})
 	return err
 }
 
 // SetEndJudging will set the judging_ended flag to true
-func SetEndJudging(db *mongo.Database) error {
+func SetEndJudging(db *mongo.Database, eventId primitive.ObjectID) error {
 	// Update the min views
-	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{}, gin.H{"$set": gin.H{"judging_ended": true}})
+	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{"event_id": eventId}, gin.H{"$set": gin.H{"judging_ended": true}})
 	return err
 }
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"server/models"
+	"server/util"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureApiTokenIndexes makes token hashes unique, so tokens can be looked up by their hash
+func EnsureApiTokenIndexes(db *mongo.Database) error {
+	_, err := db.Collection("api_tokens").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	return EnsureTTLIndex(db, "api_tokens", "expires_at", 0)
+}
+
+// InsertApiToken inserts an API token, setting its ID
+func InsertApiToken(db *mongo.Database, apiToken *models.ApiToken) error {
+	result, err := db.Collection("api_tokens").InsertOne(context.Background(), apiToken)
+	if err != nil {
+		return err
+	}
+	apiToken.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindActiveApiTokens returns the API tokens which haven't expired yet, newest first
+func FindActiveApiTokens(db *mongo.Database) ([]*models.ApiToken, error) {
+	apiTokens := make([]*models.ApiToken, 0)
+	cursor, err := db.Collection("api_tokens").Find(
+		context.Background(),
+		gin.H{"expires_at": gin.H{"$gt": util.Now()}},
+		options.Find().SetSort(gin.H{"created_at": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &apiTokens)
+	return apiTokens, err
+}
+
+// FindApiTokenByHash returns the API token with a hash, or nil if there is none
+func FindApiTokenByHash(db *mongo.Database, hash string) (*models.ApiToken, error) {
+	var apiToken models.ApiToken
+	err := db.Collection("api_tokens").FindOne(context.Background(), gin.H{"hash": hash}).Decode(&apiToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &apiToken, nil
+}
+
+// SetApiTokenLastUsed records when an API token was last used
+func SetApiTokenLastUsed(db *mongo.Database, id primitive.ObjectID, lastUsedAt primitive.DateTime) error {
+	_, err := db.Collection("api_tokens").UpdateOne(context.Background(), gin.H{"_id": id}, gin.H{"$set": gin.H{"last_used_at": lastUsedAt}})
+	return err
+}
+
+// DeleteApiToken revokes an API token. Returns false if there was no such token.
+func DeleteApiToken(db *mongo.Database, id primitive.ObjectID) (bool, error) {
+	result, err := db.Collection("api_tokens").DeleteOne(context.Background(), gin.H{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
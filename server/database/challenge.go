@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InsertChallenge inserts a challenge into an event
+func InsertChallenge(db *mongo.Database, eventId primitive.ObjectID, challenge *models.Challenge) error {
+	challenge.EventId = eventId
+	res, err := db.Collection("challenges").InsertOne(context.Background(), challenge)
+	if err != nil {
+		return err
+	}
+	challenge.Id = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindChallenges returns the challenges of an event, ordered by name
+func FindChallenges(db *mongo.Database, eventId primitive.ObjectID) ([]*models.Challenge, error) {
+	challenges := make([]*models.Challenge, 0)
+	cursor, err := db.Collection("challenges").Find(
+		context.Background(),
+		gin.H{"event_id": eventId},
+		options.Find().SetSort(gin.H{"name": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &challenges)
+	if err != nil {
+		return nil, err
+	}
+	return challenges, nil
+}
+
+// FindChallengeById returns a challenge of an event, or nil if it does not exist
+func FindChallengeById(db *mongo.Database, eventId primitive.ObjectID, id *primitive.ObjectID) (*models.Challenge, error) {
+	var challenge models.Challenge
+	err := db.Collection("challenges").FindOne(context.Background(), gin.H{"_id": id, "event_id": eventId}).Decode(&challenge)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// FindChallengeEntrants returns the projects that entered a challenge
+func FindChallengeEntrants(db *mongo.Database, id *primitive.ObjectID) ([]*models.Project, error) {
+	projects := make([]*models.Project, 0)
+	cursor, err := db.Collection("projects").Find(context.Background(), gin.H{"challenge_ids": id})
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &projects)
+	if err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// CountChallengeEntrants returns the number of projects that entered a challenge
+func CountChallengeEntrants(db *mongo.Database, id *primitive.ObjectID) (int64, error) {
+	return db.Collection("projects").CountDocuments(context.Background(), gin.H{"challenge_ids": id})
+}
+
+// UpdateChallenge saves the details of a challenge. If it was renamed, the copies of its name in its entrants'
+// challenge lists are renamed too, in the same transaction.
+func UpdateChallenge(db *mongo.Database, challenge *models.Challenge, oldName string) error {
+	return WithTransaction(db, func(ctx mongo.SessionContext) (interface{}, error) {
+		_, err := db.Collection("challenges").UpdateOne(ctx, gin.H{"_id": challenge.Id}, gin.H{"$set": challenge})
+		if err != nil || challenge.Name == oldName {
+			return nil, err
+		}
+
+		_, err = db.Collection("projects").UpdateMany(
+			ctx,
+			gin.H{"challenge_ids": challenge.Id},
+			gin.H{"$set": gin.H{"challenge_list.$[c]": challenge.Name}},
+			options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{gin.H{"c": oldName}}}),
+		)
+		return nil, err
+	})
+}
+
+// DeleteChallenge deletes a challenge of an event
+func DeleteChallenge(db *mongo.Database, eventId primitive.ObjectID, id *primitive.ObjectID) error {
+	_, err := db.Collection("challenges").DeleteOne(context.Background(), gin.H{"_id": id, "event_id": eventId})
+	return err
+}
+
+// UpdateProjectChallenges sets the challenges projects entered, in bulk
+func UpdateProjectChallenges(db *mongo.Database, ctx context.Context, projects []*models.Project) error {
+	if len(projects) == 0 {
+		return nil
+	}
+	mongoModels := make([]mongo.WriteModel, 0, len(projects))
+	for _, project := range projects {
+		mongoModels = append(mongoModels, mongo.NewUpdateOneModel().SetFilter(gin.H{"_id": project.Id}).SetUpdate(gin.H{"$set": gin.H{
+			"challenge_list": project.ChallengeList,
+			"challenge_ids":  project.ChallengeIds,
+		}}))
+	}
+
+	opts := options.BulkWrite().SetOrdered(false)
+	_, err := db.Collection("projects").BulkWrite(ctx, mongoModels, opts)
+	return err
+}
+
+// MergeChallenges saves a challenge that others were merged into along with the projects that entered them,
+// then deletes the merged challenges, in one transaction
+func MergeChallenges(db *mongo.Database, target *models.Challenge, merged []primitive.ObjectID, projects []*models.Project) error {
+	return WithTransaction(db, func(ctx mongo.SessionContext) (interface{}, error) {
+		_, err := db.Collection("challenges").UpdateOne(ctx, gin.H{"_id": target.Id}, gin.H{"$set": target})
+		if err != nil {
+			return nil, err
+		}
+		err = UpdateProjectChallenges(db, ctx, projects)
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Collection("challenges").DeleteMany(ctx, gin.H{"_id": gin.H{"$in": merged}, "event_id": target.EventId})
+		return nil, err
+	})
+}
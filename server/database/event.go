@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// eventCollections are the collections whose documents are scoped by an event_id field
+var eventCollections = []string{"projects", "judges", "flags", "options", "project_history", "table_zones", "guilds", "challenges", "results"}
+
+// InsertEvent inserts an event into the database
+func InsertEvent(db *mongo.Database, event *models.Event) error {
+	res, err := db.Collection("events").InsertOne(context.Background(), event)
+	if err != nil {
+		return err
+	}
+	event.Id = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindAllEvents returns a list of all events in the database, oldest first
+func FindAllEvents(db *mongo.Database) ([]*models.Event, error) {
+	events := make([]*models.Event, 0)
+	cursor, err := db.Collection("events").Find(
+		context.Background(),
+		gin.H{},
+		options.Find().SetSort(gin.H{"created_at": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &events)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// FindEventById returns an event from the database by id, or nil if it does not exist
+func FindEventById(db *mongo.Database, id *primitive.ObjectID) (*models.Event, error) {
+	var event models.Event
+	err := db.Collection("events").FindOne(context.Background(), gin.H{"_id": id}).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// FindDefaultEvent returns the event served by the routes that are not namespaced by an event ID
+func FindDefaultEvent(db *mongo.Database) (*models.Event, error) {
+	var event models.Event
+	err := db.Collection("events").FindOne(context.Background(), gin.H{"default": true}).Decode(&event)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// UpdateEventDetails updates the name and archived state of an event
+func UpdateEventDetails(db *mongo.Database, id *primitive.ObjectID, name string, archived bool) error {
+	_, err := db.Collection("events").UpdateOne(
+		context.Background(),
+		gin.H{"_id": id},
+		gin.H{"$set": gin.H{"name": name, "archived": archived}},
+	)
+	return err
+}
+
+// SetDefaultEvent makes the given event the default event, unsetting the flag on all others
+func SetDefaultEvent(db *mongo.Database, id *primitive.ObjectID) error {
+	return WithTransaction(db, func(ctx mongo.SessionContext) (interface{}, error) {
+		_, err := db.Collection("events").UpdateMany(ctx, gin.H{"_id": gin.H{"$ne": id}}, gin.H{"$set": gin.H{"default": false}})
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Collection("events").UpdateOne(ctx, gin.H{"_id": id}, gin.H{"$set": gin.H{"default": true}})
+		return nil, err
+	})
+}
+
+// EnsureDefaultEvent makes sure that a default event exists. If the database has no events yet
+// (e.g. it was created before events existed), a new default event is created and all existing
+// projects, judges, flags and options are moved into it.
+func EnsureDefaultEvent(db *mongo.Database, name string) (*models.Event, error) {
+	event, err := FindDefaultEvent(db)
+	if err != nil || event != nil {
+		return event, err
+	}
+
+	// Promote the oldest unarchived event if there is one
+	events, err := FindAllEvents(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if !e.Archived {
+			err = SetDefaultEvent(db, &e.Id)
+			e.Default = true
+			return e, err
+		}
+	}
+
+	// Otherwise create a new event
+	event = models.NewEvent(name)
+	event.Default = true
+	err = InsertEvent(db, event)
+	if err != nil {
+		return nil, err
+	}
+
+	// Adopt all documents from before events existed
+	for _, c := range eventCollections {
+		_, err = db.Collection(c).UpdateMany(
+			context.Background(),
+			gin.H{"event_id": gin.H{"$exists": false}},
+			gin.H{"$set": gin.H{"event_id": event.Id}},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return event, nil
+}
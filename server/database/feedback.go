@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ModerateFeedback sets the moderation status of the notes of a judge of an event on a project and, if feedback is
+// not nil, the text that is shared. Returns false if the event has no such judge or they haven't shared notes on
+// the project.
+func ModerateFeedback(db *mongo.Database, eventId primitive.ObjectID, judgeId *primitive.ObjectID, projectId *primitive.ObjectID, status string, feedback *string) (bool, error) {
+	set := gin.H{"seen_projects.$[p].feedback_status": status}
+	if feedback != nil {
+		set["seen_projects.$[p].feedback"] = *feedback
+	}
+	res, err := db.Collection("judges").UpdateOne(
+		context.Background(),
+		gin.H{"_id": judgeId, "event_id": eventId, "seen_projects": gin.H{"$elemMatch": gin.H{"project_id": projectId, "share_notes": true}}},
+		gin.H{"$set": set},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			gin.H{"p.project_id": projectId, "p.share_notes": true},
+		}}),
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// SetProjectFeedbackTokens saves the feedback tokens of projects, in bulk
+func SetProjectFeedbackTokens(db *mongo.Database, projects []*models.Project) error {
+	if len(projects) == 0 {
+		return nil
+	}
+	mongoModels := make([]mongo.WriteModel, 0, len(projects))
+	for _, project := range projects {
+		mongoModels = append(mongoModels, mongo.NewUpdateOneModel().
+			SetFilter(gin.H{"_id": project.Id}).
+			SetUpdate(gin.H{"$set": gin.H{"feedback_token": project.FeedbackToken}}))
+	}
+	_, err := db.Collection("projects").BulkWrite(context.Background(), mongoModels, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// FindProjectByFeedbackToken returns the project of an event with a feedback token, or nil if there is none
+func FindProjectByFeedbackToken(db *mongo.Database, eventId primitive.ObjectID, token string) (*models.Project, error) {
+	var project models.Project
+	err := db.Collection("projects").FindOne(context.Background(), gin.H{"event_id": eventId, "feedback_token": token}).Decode(&project)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// SetFeedbackReleased sets whether teams can see their feedback
+func SetFeedbackReleased(db *mongo.Database, eventId primitive.ObjectID, released bool) error {
+	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{"event_id": eventId}, gin.H{"$set": gin.H{"feedback_released": released}})
+	return err
+}
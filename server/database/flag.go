@@ -2,10 +2,14 @@ package database
 
 import (
 	"context"
+	"errors"
 	"server/models"
+	"server/util"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // InsertFlag inserts a skip object into the database
@@ -14,10 +18,10 @@ func InsertFlag(db *mongo.Database, ctx context.Context, skip *models.Flag) erro
 	return err
 }
 
-// FindAllFlags returns all flag objects
-func FindAllFlags(db *mongo.Database) ([]*models.Flag, error) {
+// FindAllFlags returns all flag objects of an event
+func FindAllFlags(db *mongo.Database, eventId primitive.ObjectID) ([]*models.Flag, error) {
 	flags := make([]*models.Flag, 0)
-	cursor, err := db.Collection("flags").Find(context.Background(), gin.H{})
+	cursor, err := db.Collection("flags").Find(context.Background(), gin.H{"event_id": eventId})
 	if err != nil {
 		return nil, err
 	}
@@ -40,3 +44,60 @@ func FindFlagsByJudge(db *mongo.Database, judge *models.Judge, ctx mongo.Session
 	}
 	return flags, nil
 }
+
+// FindFlagById returns a flag, or nil if there is none
+func FindFlagById(db *mongo.Database, id *primitive.ObjectID) (*models.Flag, error) {
+	var flag models.Flag
+	err := db.Collection("flags").FindOne(context.Background(), gin.H{"_id": id}).Decode(&flag)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// FindUnresolvedAbsentFlags returns the absent flags of an event which haven't been resolved, oldest first
+func FindUnresolvedAbsentFlags(db *mongo.Database, eventId primitive.ObjectID) ([]*models.Flag, error) {
+	flags := make([]*models.Flag, 0)
+	cursor, err := db.Collection("flags").Find(
+		context.Background(),
+		gin.H{"event_id": eventId, "reason": "absent", "resolved": gin.H{"$ne": true}},
+		options.Find().SetSort(gin.H{"time": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &flags)
+	if err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// resolveFlags marks the unresolved flags matching a filter as resolved by a user, returning how many were resolved
+func resolveFlags(db *mongo.Database, filter gin.H, resolvedBy string, resolvedByName string) (int64, error) {
+	filter["resolved"] = gin.H{"$ne": true}
+	result, err := db.Collection("flags").UpdateMany(context.Background(), filter, gin.H{"$set": gin.H{
+		"resolved":         true,
+		"resolved_at":      util.Now(),
+		"resolved_by":      resolvedBy,
+		"resolved_by_name": resolvedByName,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// ResolveFlag marks a flag as resolved by a user. Returns false if it was already resolved.
+func ResolveFlag(db *mongo.Database, id *primitive.ObjectID, resolvedBy string, resolvedByName string) (bool, error) {
+	resolved, err := resolveFlags(db, gin.H{"_id": id}, resolvedBy, resolvedByName)
+	return resolved > 0, err
+}
+
+// ResolveProjectAbsentFlags marks the absent flags of a project as resolved by a user, e.g. when the team is back
+func ResolveProjectAbsentFlags(db *mongo.Database, projectId *primitive.ObjectID, resolvedBy string, resolvedByName string) (int64, error) {
+	return resolveFlags(db, gin.H{"project_id": projectId, "reason": "absent"}, resolvedBy, resolvedByName)
+}
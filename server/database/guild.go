@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InsertGuild inserts a guild into an event
+func InsertGuild(db *mongo.Database, eventId primitive.ObjectID, guild *models.Guild) error {
+	guild.EventId = eventId
+	res, err := db.Collection("guilds").InsertOne(context.Background(), guild)
+	if err != nil {
+		return err
+	}
+	guild.Id = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindGuilds returns the guilds of an event in display order
+func FindGuilds(db *mongo.Database, eventId primitive.ObjectID) ([]*models.Guild, error) {
+	guilds := make([]*models.Guild, 0)
+	cursor, err := db.Collection("guilds").Find(
+		context.Background(),
+		gin.H{"event_id": eventId},
+		options.Find().SetSort(bson.D{{Key: "order", Value: 1}, {Key: "name", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &guilds)
+	if err != nil {
+		return nil, err
+	}
+	return guilds, nil
+}
+
+// FindGuildById returns a guild of an event, or nil if it does not exist
+func FindGuildById(db *mongo.Database, eventId primitive.ObjectID, id *primitive.ObjectID) (*models.Guild, error) {
+	var guild models.Guild
+	err := db.Collection("guilds").FindOne(context.Background(), gin.H{"_id": id, "event_id": eventId}).Decode(&guild)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &guild, nil
+}
+
+// UpdateGuild saves the details of a guild. If it was renamed, the copies of its name in its projects,
+// judges' copies of its projects and its table zones are renamed too, in the same transaction.
+func UpdateGuild(db *mongo.Database, guild *models.Guild, oldName string) error {
+	return WithTransaction(db, func(ctx mongo.SessionContext) (interface{}, error) {
+		_, err := db.Collection("guilds").UpdateOne(ctx, gin.H{"_id": guild.Id}, gin.H{"$set": guild})
+		if err != nil || guild.Name == oldName {
+			return nil, err
+		}
+
+		// Find the guild's projects
+		var projects []*models.Project
+		cursor, err := db.Collection("projects").Find(ctx, gin.H{"guild_id": guild.Id}, options.Find().SetProjection(gin.H{"_id": 1}))
+		if err != nil {
+			return nil, err
+		}
+		err = cursor.All(ctx, &projects)
+		if err != nil {
+			return nil, err
+		}
+		projectIds := make([]primitive.ObjectID, len(projects))
+		for i, project := range projects {
+			projectIds[i] = project.Id
+		}
+
+		// Rename the guild everywhere its name is copied
+		_, err = db.Collection("projects").UpdateMany(ctx, gin.H{"guild_id": guild.Id}, gin.H{"$set": gin.H{"guild": guild.Name}})
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Collection("judges").UpdateMany(
+			ctx,
+			gin.H{"event_id": guild.EventId, "seen_projects.project_id": gin.H{"$in": projectIds}},
+			gin.H{"$set": gin.H{"seen_projects.$[p].guild": guild.Name}},
+			options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{gin.H{"p.project_id": gin.H{"$in": projectIds}}}}),
+		)
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Collection("table_zones").UpdateMany(ctx, gin.H{"event_id": guild.EventId, "guild": oldName}, gin.H{"$set": gin.H{"guild": guild.Name}})
+		return nil, err
+	})
+}
+
+// DeleteGuild deletes a guild of an event
+func DeleteGuild(db *mongo.Database, eventId primitive.ObjectID, id *primitive.ObjectID) error {
+	_, err := db.Collection("guilds").DeleteOne(context.Background(), gin.H{"_id": id, "event_id": eventId})
+	return err
+}
+
+// CountGuildProjects returns the number of projects in a guild
+func CountGuildProjects(db *mongo.Database, id *primitive.ObjectID) (int64, error) {
+	return db.Collection("projects").CountDocuments(context.Background(), gin.H{"guild_id": id})
+}
+
+// SetGuildProjectsHidden sets the active fields of all projects in a guild, returning how many projects changed
+func SetGuildProjectsHidden(db *mongo.Database, id *primitive.ObjectID, hidden bool) (int64, error) {
+	res, err := db.Collection("projects").UpdateMany(
+		context.Background(),
+		gin.H{"guild_id": id, "active": hidden},
+		gin.H{"$set": gin.H{"active": !hidden}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// UpdateProjectGuildIds sets the guilds projects reference, in bulk
+func UpdateProjectGuildIds(db *mongo.Database, projects []*models.Project) error {
+	if len(projects) == 0 {
+		return nil
+	}
+	mongoModels := make([]mongo.WriteModel, 0, len(projects))
+	for _, project := range projects {
+		mongoModels = append(mongoModels, mongo.NewUpdateOneModel().SetFilter(gin.H{"_id": project.Id}).SetUpdate(gin.H{"$set": gin.H{"guild_id": project.GuildId}}))
+	}
+
+	opts := options.BulkWrite().SetOrdered(false)
+	_, err := db.Collection("projects").BulkWrite(context.Background(), mongoModels, opts)
+	return err
+}
@@ -35,11 +35,35 @@ func InitDb() *mongo.Database {
 	db := client.Database(config.DatabaseName)
 
 	// Create indexes for token_set and judges tables/collections
-	tokenSetIndexModel := mongo.IndexModel{Keys: bson.D{{"user_id", true}}}
+	tokenSetIndexModel := mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}}
 	db.Collection("token_set").Indexes().CreateOne(context.Background(), tokenSetIndexModel)
 
-	judgesIndexModel := mongo.IndexModel{Keys: bson.D{{"keycloak_user_id", true}}}
+	judgesIndexModel := mongo.IndexModel{Keys: bson.D{{Key: "event_id", Value: 1}, {Key: "keycloak_user_id", Value: 1}}}
 	db.Collection("judges").Indexes().CreateOne(context.Background(), judgesIndexModel)
 
+	// Every other event-scoped collection is queried by its event
+	for _, c := range []string{"projects", "flags", "options", "snapshots", "table_zones", "guilds", "challenges", "results"} {
+		eventIndexModel := mongo.IndexModel{Keys: bson.D{{Key: "event_id", Value: 1}}}
+		db.Collection(c).Indexes().CreateOne(context.Background(), eventIndexModel)
+	}
+
+	guildProjectsIndexModel := mongo.IndexModel{Keys: bson.D{{Key: "guild_id", Value: 1}}}
+	db.Collection("projects").Indexes().CreateOne(context.Background(), guildProjectsIndexModel)
+
+	challengeProjectsIndexModel := mongo.IndexModel{Keys: bson.D{{Key: "challenge_ids", Value: 1}}}
+	db.Collection("projects").Indexes().CreateOne(context.Background(), challengeProjectsIndexModel)
+
+	feedbackTokenIndexModel := mongo.IndexModel{Keys: bson.D{{Key: "feedback_token", Value: 1}}, Options: options.Index().SetSparse(true)}
+	db.Collection("projects").Indexes().CreateOne(context.Background(), feedbackTokenIndexModel)
+
+	historyIndexModel := mongo.IndexModel{Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "changed_at", Value: -1}}}
+	db.Collection("project_history").Indexes().CreateOne(context.Background(), historyIndexModel)
+
+	// Make sure there is an event to serve the non-namespaced routes
+	_, err = EnsureDefaultEvent(db, config.DefaultEventName)
+	if err != nil {
+		log.Fatalf("Error creating default event: %s\n", err.Error())
+	}
+
 	return db
 }
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 
 	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
 	"server/models"
@@ -24,24 +25,90 @@ func UpdateJudgeLastActivity(db *mongo.Database, ctx context.Context, id *primit
 func GetOrCreateJudge(db *mongo.Database, judge *models.Judge) error {
 	err := db.Collection("judges").FindOneAndUpdate(
 		context.Background(),
-		gin.H{"keycloak_user_id": judge.KeycloakUserId},
+		gin.H{"event_id": judge.EventId, "keycloak_user_id": judge.KeycloakUserId},
 		gin.H{"$setOnInsert": judge},
 		mongoOptions.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(mongoOptions.After),
 	).Decode(&judge)
 	return err
 }
 
+// FindJudgeByKeycloakUserId returns the judge of an event with a keycloak user ID, or nil if there is none
+func FindJudgeByKeycloakUserId(db *mongo.Database, eventId primitive.ObjectID, keycloakUserId string) (*models.Judge, error) {
+	var judge models.Judge
+	err := db.Collection("judges").FindOne(context.Background(), gin.H{"event_id": eventId, "keycloak_user_id": keycloakUserId}).Decode(&judge)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &judge, nil
+}
+
+// InsertJudge inserts a judge before they have logged in, e.g. when judges are imported
+func InsertJudge(db *mongo.Database, judge *models.Judge) error {
+	res, err := db.Collection("judges").InsertOne(context.Background(), judge)
+	if err != nil {
+		return err
+	}
+	judge.Id = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// SetJudgeFirstLogin records that a judge has logged in for the first time
+func SetJudgeFirstLogin(db *mongo.Database, judge *models.Judge) error {
+	judge.FirstLoginAt = util.Now()
+	_, err := db.Collection("judges").UpdateOne(
+		context.Background(),
+		gin.H{"_id": judge.Id, "first_login_at": gin.H{"$in": []interface{}{nil, primitive.DateTime(0)}}},
+		gin.H{"$set": gin.H{"first_login_at": judge.FirstLoginAt}},
+	)
+	return err
+}
+
+// UpdateJudgeProfile replaces the cached keycloak profile of a judge
+func UpdateJudgeProfile(db *mongo.Database, judge *models.Judge, profile *models.JudgeProfile) error {
+	judge.Profile = *profile
+	_, err := db.Collection("judges").UpdateOne(context.Background(), gin.H{"_id": judge.Id}, gin.H{"$set": gin.H{"profile": profile}})
+	return err
+}
+
+// UpdateJudgeProfiles replaces the cached keycloak profile of every judge (in any event) of a keycloak user
+func UpdateJudgeProfiles(db *mongo.Database, keycloakUserId string, profile *models.JudgeProfile) (int64, error) {
+	res, err := db.Collection("judges").UpdateMany(context.Background(), gin.H{"keycloak_user_id": keycloakUserId}, gin.H{"$set": gin.H{"profile": profile}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// FindJudgeKeycloakUserIds returns the keycloak user IDs of the judges of every event
+func FindJudgeKeycloakUserIds(db *mongo.Database) ([]string, error) {
+	values, err := db.Collection("judges").Distinct(context.Background(), "keycloak_user_id", gin.H{})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
 // UpdateJudge updates a judge in the database
 func UpdateJudge(db *mongo.Database, judge *models.Judge) error {
 	judge.LastActivity = util.Now()
+	judge.FillRankingArrays()
 	_, err := db.Collection("judges").UpdateOne(context.Background(), gin.H{"_id": judge.Id}, gin.H{"$set": judge})
 	return err
 }
 
-// FindAllJudges returns a list of all judges in the database
-func FindAllJudges(db *mongo.Database) ([]*models.Judge, error) {
+// FindAllJudges returns a list of all judges of an event
+func FindAllJudges(db *mongo.Database, eventId primitive.ObjectID) ([]*models.Judge, error) {
 	judges := make([]*models.Judge, 0)
-	cursor, err := db.Collection("judges").Find(context.Background(), gin.H{})
+	cursor, err := db.Collection("judges").Find(context.Background(), gin.H{"event_id": eventId})
 	if err != nil {
 		return nil, err
 	}
@@ -56,17 +123,17 @@ func FindAllJudges(db *mongo.Database) ([]*models.Judge, error) {
 	return judges, nil
 }
 
-// AggregateJudgeStats aggregates statistics about judges
-func AggregateJudgeStats(db *mongo.Database) (*models.JudgeStats, error) {
+// AggregateJudgeStats aggregates statistics about the judges of an event
+func AggregateJudgeStats(db *mongo.Database, eventId primitive.ObjectID) (*models.JudgeStats, error) {
 	// Get the total number of judges
-	totalJudges, err := db.Collection("judges").EstimatedDocumentCount(context.Background())
+	totalJudges, err := db.Collection("judges").CountDocuments(context.Background(), gin.H{"event_id": eventId})
 	if err != nil {
 		return nil, err
 	}
 
 	// Get the total number of active judges and the average number of votes using an aggregation pipeline
 	cursor, err := db.Collection("judges").Aggregate(context.Background(), []gin.H{
-		{"$match": gin.H{"active": true}},
+		{"$match": gin.H{"event_id": eventId, "active": true}},
 		{"$group": gin.H{
 			"_id": nil,
 			"avgSeen": gin.H{
@@ -100,10 +167,14 @@ func AggregateJudgeStats(db *mongo.Database) (*models.JudgeStats, error) {
 }
 
 // todo: rename functions and routes to deleting judge data since the keycloak account still exists
-// DeleteJudgeById deletes a judge from the database by their id
-func DeleteJudgeById(db *mongo.Database, id primitive.ObjectID) error {
-	_, err := db.Collection("judges").DeleteOne(context.Background(), gin.H{"_id": id})
-	return err
+// DeleteJudgeById deletes a judge of an event from the database by their id. Returns false if the event has no
+// such judge.
+func DeleteJudgeById(db *mongo.Database, eventId primitive.ObjectID, id primitive.ObjectID) (bool, error) {
+	result, err := db.Collection("judges").DeleteOne(context.Background(), gin.H{"_id": id, "event_id": eventId})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
 }
 
 // UpdateAfterSeen updates the judge's seen projects and increments the seen count
@@ -121,24 +192,31 @@ func UpdateAfterSeen(db *mongo.Database, judge *models.Judge, seenProject *model
 	return err
 }
 
-// SetJudgeHidden sets the active field of a judge
-func SetJudgeHidden(db *mongo.Database, id *primitive.ObjectID, hidden bool) error {
-	_, err := db.Collection("judges").UpdateOne(
+// SetJudgeHidden sets the active field of a judge of an event. Returns false if the event has no such judge.
+func SetJudgeHidden(db *mongo.Database, eventId primitive.ObjectID, id *primitive.ObjectID, hidden bool) (bool, error) {
+	result, err := db.Collection("judges").UpdateOne(
 		context.Background(),
-		gin.H{"_id": id},
+		gin.H{"_id": id, "event_id": eventId},
 		gin.H{"$set": gin.H{"active": !hidden, "last_activity": util.Now()}},
 	)
-	return err
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
 }
 
-// UpdateJudgeBasicInfo updates the basic info of a judge (name, email, notes)
-func UpdateJudgeBasicInfo(db *mongo.Database, judgeId *primitive.ObjectID, addRequest *models.EditJudgeRequest) error {
-	_, err := db.Collection("judges").UpdateOne(
+// UpdateJudgeBasicInfo updates the basic info of a judge of an event (name, email, notes). Returns false if the
+// event has no such judge.
+func UpdateJudgeBasicInfo(db *mongo.Database, eventId primitive.ObjectID, judgeId *primitive.ObjectID, addRequest *models.EditJudgeRequest) (bool, error) {
+	result, err := db.Collection("judges").UpdateOne(
 		context.Background(),
-		gin.H{"_id": judgeId},
+		gin.H{"_id": judgeId, "event_id": eventId},
 		gin.H{"$set": gin.H{"notes": addRequest.Notes}},
 	)
-	return err
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
 }
 
 // UpdateJudgeRanking updates the judge's ranking array
@@ -151,24 +229,30 @@ func UpdateJudgeRanking(db *mongo.Database, judge *models.Judge, currentRankings
 	return err
 }
 
-// UpdateJudgePostBatch updates the judge after a batch of projects has been ranked and submitted
+// UpdateJudgePostBatchRank updates the judge after a batch of projects has been ranked and submitted
 func UpdateJudgePostBatchRank(db *mongo.Database, judge *models.Judge, batchRanking []primitive.ObjectID) error {
+	now := util.Now()
+
+	// Append with a pipeline rather than $push, which fails on judges whose arrays are stored as null
 	_, err := db.Collection("judges").UpdateOne(
 		context.Background(),
 		gin.H{"_id": judge.Id},
-		gin.H{
-			"$set": gin.H{
-				"current_rankings": []primitive.ObjectID{},
-				"last_activity":    util.Now(),
-			},
-			"$push": gin.H{
-				"past_rankings": batchRanking, // Add the latest batch ranking to the past_rankings 2D array
-			},
-		},
+		[]gin.H{{"$set": gin.H{
+			"current_rankings": []primitive.ObjectID{},
+			"last_activity":    now,
+			"past_rankings":    appendToArrayField("past_rankings", [][]primitive.ObjectID{batchRanking}), // Add the latest batch ranking to the past_rankings 2D array
+			"past_rankings_at": appendToArrayField("past_rankings_at", judge.BatchSubmissionTimes(now)),
+		}}},
 	)
 	return err
 }
 
+// appendToArrayField is an aggregation expression appending values to an array field, treating a null or missing
+// field as empty
+func appendToArrayField(field string, values interface{}) gin.H {
+	return gin.H{"$concatArrays": []interface{}{gin.H{"$ifNull": []interface{}{"$" + field, []interface{}{}}}, values}}
+}
+
 // TODO: Move the stuff from UpdateJudgeRankings to here
 func UpdateJudgeSeenProjects(db *mongo.Database, judge *models.Judge) error {
 	_, err := db.Collection("judges").UpdateOne(context.Background(), gin.H{"_id": judge.Id}, gin.H{"$set": gin.H{"seen_projects": judge.SeenProjects}})
@@ -6,17 +6,18 @@ import (
 	"server/models"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// GetOptions gets the options from the database
-func GetOptions(db *mongo.Database) (*models.Options, error) {
+// GetOptions gets the options of an event from the database
+func GetOptions(db *mongo.Database, eventId primitive.ObjectID) (*models.Options, error) {
 	var options models.Options
-	err := db.Collection("options").FindOne(context.Background(), gin.H{}).Decode(&options)
+	err := db.Collection("options").FindOne(context.Background(), gin.H{"event_id": eventId}).Decode(&options)
 
 	// If options does not exist, create it
 	if errors.Is(err, mongo.ErrNoDocuments) {
-		options = *models.NewOptions()
+		options = *models.NewOptions(eventId)
 		_, err = db.Collection("options").InsertOne(context.Background(), options)
 		return &options, err
 	}
@@ -25,35 +26,35 @@ func GetOptions(db *mongo.Database) (*models.Options, error) {
 }
 
 // UpdateOptions updates the clock in the database
-func UpdateClock(db *mongo.Database, clock *models.ClockState) error {
-	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{}, gin.H{"$set": gin.H{"clock": clock}})
+func UpdateClock(db *mongo.Database, eventId primitive.ObjectID, clock *models.ClockState) error {
+	_, err := db.Collection("options").UpdateOne(context.Background(), gin.H{"event_id": eventId}, gin.H{"$set": gin.H{"clock": clock}})
 	return err
 }
 
 // GetCategories gets the categories from the database
-func GetCategories(db *mongo.Database) ([]string, error) {
+func GetCategories(db *mongo.Database, eventId primitive.ObjectID) ([]string, error) {
 	var options models.Options
-	err := db.Collection("options").FindOne(context.Background(), gin.H{}).Decode(&options)
+	err := db.Collection("options").FindOne(context.Background(), gin.H{"event_id": eventId}).Decode(&options)
 	return options.Categories, err
 }
 
 // GetMinViews gets the minimum views option from the database
-func GetMinViews(db *mongo.Database) (int64, error) {
+func GetMinViews(db *mongo.Database, eventId primitive.ObjectID) (int64, error) {
 	var options models.Options
-	err := db.Collection("options").FindOne(context.Background(), gin.H{}).Decode(&options)
+	err := db.Collection("options").FindOne(context.Background(), gin.H{"event_id": eventId}).Decode(&options)
 	return options.MinViews, err
 }
 
 // GetBatchRankingSize gets the ranking batch size option from the database
-func GetBatchRankingSize(db *mongo.Database) (int64, error) {
+func GetBatchRankingSize(db *mongo.Database, eventId primitive.ObjectID) (int64, error) {
 	var options models.Options
-	err := db.Collection("options").FindOne(context.Background(), gin.H{}).Decode(&options)
+	err := db.Collection("options").FindOne(context.Background(), gin.H{"event_id": eventId}).Decode(&options)
 	return options.BatchRankingSize, err
 }
 
 // GetJudgingEnded gets the judgingEnded flag from the database
-func GetJudgingEnded(db *mongo.Database) (bool, error) {
-	options, err := GetOptions(db)
+func GetJudgingEnded(db *mongo.Database, eventId primitive.ObjectID) (bool, error) {
+	options, err := GetOptions(db, eventId)
 	if options != nil {
 		return options.JudgingEnded, err
 	}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"server/models"
 	"server/util"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -20,26 +21,28 @@ func UpdateProjectLastActivity(db *mongo.Database, ctx context.Context, id *prim
 	return err
 }
 
-// InsertProjects inserts a list of projects into the database
-func InsertProjects(db *mongo.Database, projects []*models.Project) error {
+// InsertProjects inserts a list of projects into an event
+func InsertProjects(db *mongo.Database, eventId primitive.ObjectID, projects []*models.Project) error {
 	var docs []interface{}
 	for _, project := range projects {
+		project.EventId = eventId
 		docs = append(docs, project)
 	}
 	_, err := db.Collection("projects").InsertMany(context.Background(), docs)
 	return err
 }
 
-// InsertProject inserts a project into the database
-func InsertProject(db *mongo.Database, project *models.Project) error {
+// InsertProject inserts a project into an event
+func InsertProject(db *mongo.Database, eventId primitive.ObjectID, project *models.Project) error {
+	project.EventId = eventId
 	_, err := db.Collection("projects").InsertOne(context.Background(), project)
 	return err
 }
 
-// FindAllProjects returns a list of all projects in the database
-func FindAllProjects(db *mongo.Database) ([]*models.Project, error) {
+// FindAllProjects returns a list of all projects of an event
+func FindAllProjects(db *mongo.Database, eventId primitive.ObjectID) ([]*models.Project, error) {
 	projects := make([]*models.Project, 0)
-	cursor, err := db.Collection("projects").Find(context.Background(), gin.H{})
+	cursor, err := db.Collection("projects").Find(context.Background(), gin.H{"event_id": eventId})
 	if err != nil {
 		return nil, err
 	}
@@ -50,23 +53,27 @@ func FindAllProjects(db *mongo.Database) ([]*models.Project, error) {
 	return projects, nil
 }
 
-// DeleteProjectById deletes a project from the database by id
-func DeleteProjectById(db *mongo.Database, id primitive.ObjectID) error {
-	_, err := db.Collection("projects").DeleteOne(context.Background(), gin.H{"_id": id})
-	return err
+// DeleteProjectById deletes a project of an event from the database by id. Returns false if the event has no
+// such project.
+func DeleteProjectById(db *mongo.Database, eventId primitive.ObjectID, id primitive.ObjectID) (bool, error) {
+	result, err := db.Collection("projects").DeleteOne(context.Background(), gin.H{"_id": id, "event_id": eventId})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
 }
 
-// AggregateProjectStats aggregates all stats from the database for a project
-func AggregateProjectStats(db *mongo.Database) (*models.ProjectStats, error) {
+// AggregateProjectStats aggregates all stats from the database for the projects of an event
+func AggregateProjectStats(db *mongo.Database, eventId primitive.ObjectID) (*models.ProjectStats, error) {
 	// Get the totoal number of projects
-	totalProjects, err := db.Collection("projects").EstimatedDocumentCount(context.Background())
+	totalProjects, err := db.Collection("projects").CountDocuments(context.Background(), gin.H{"event_id": eventId})
 	if err != nil {
 		return nil, err
 	}
 
 	// Get the average votes and average seen using an aggregation pipeline
 	cursor, err := db.Collection("projects").Aggregate(context.Background(), []gin.H{
-		{"$match": gin.H{"active": true}},
+		{"$match": gin.H{"event_id": eventId, "active": true}},
 		{"$group": gin.H{
 			"_id": nil,
 			"avgSeen": gin.H{
@@ -99,10 +106,10 @@ func AggregateProjectStats(db *mongo.Database) (*models.ProjectStats, error) {
 	return &stats, nil
 }
 
-// FindActiveProjects returns a list of all active projects in the database
-func FindActiveProjects(db *mongo.Database, ctx mongo.SessionContext) ([]*models.Project, error) {
+// FindActiveProjects returns a list of all active projects of an event
+func FindActiveProjects(db *mongo.Database, ctx mongo.SessionContext, eventId primitive.ObjectID) ([]*models.Project, error) {
 	var projects []*models.Project
-	cursor, err := db.Collection("projects").Find(ctx, gin.H{"active": true})
+	cursor, err := db.Collection("projects").Find(ctx, gin.H{"event_id": eventId, "active": true})
 	if err != nil {
 		return nil, err
 	}
@@ -115,11 +122,12 @@ func FindActiveProjects(db *mongo.Database, ctx mongo.SessionContext) ([]*models
 
 // FindBusyProjects returns a list of all projects that are currently being judged.
 // To do this, we collect all projects in the judge's "current" field
-func FindBusyProjects(db *mongo.Database, ctx mongo.SessionContext) ([]*primitive.ObjectID, error) {
+func FindBusyProjects(db *mongo.Database, ctx mongo.SessionContext, eventId primitive.ObjectID) ([]*primitive.ObjectID, error) {
 	// Get all judges that are currently judging a project
 	// TODO: This query can be optimized by projecting on the "current" field
 	var judges []*models.Judge
 	cursor, err := db.Collection("judges").Find(ctx, gin.H{
+		"event_id": eventId,
 		"current": gin.H{
 			"$ne": nil,
 		},
@@ -168,7 +176,7 @@ func UpdateAfterPickedWithTx(db *mongo.Database, project *models.Project, judge
 	_, err := db.Collection("projects").UpdateOne(
 		ctx,
 		gin.H{"_id": project.Id},
-		gin.H{"$inc": gin.H{"seen": 1}, "$set": gin.H{"prioritized": false, "last_activity": util.Now()}},
+		gin.H{"$inc": gin.H{"seen": 1}, "$set": gin.H{"prioritized": false, "priority_until": primitive.DateTime(0), "last_activity": util.Now()}},
 	)
 	if err != nil {
 		return nil, err
@@ -184,34 +192,63 @@ func UpdateAfterPickedWithTx(db *mongo.Database, project *models.Project, judge
 	return nil, err
 }
 
-// CountProjectDocuments returns the number of documents in the projects collection
-func CountProjectDocuments(db *mongo.Database) (int64, error) {
-	return db.Collection("projects").EstimatedDocumentCount(context.Background())
+// CountProjectDocuments returns the number of projects in an event
+func CountProjectDocuments(db *mongo.Database, eventId primitive.ObjectID) (int64, error) {
+	return db.Collection("projects").CountDocuments(context.Background(), gin.H{"event_id": eventId})
 }
 
-// SetProjectHidden sets the active field of a project
-func SetProjectHidden(db *mongo.Database, id *primitive.ObjectID, hidden bool) error {
-	_, err := db.Collection("projects").UpdateOne(
-		context.Background(), gin.H{"_id": id}, gin.H{"$set": gin.H{"active": !hidden}})
-	return err
+// SetProjectHidden sets the active field of a project of an event. Projects hidden or shown by admins are no
+// longer absent. Returns false if the event has no such project.
+func SetProjectHidden(db *mongo.Database, eventId primitive.ObjectID, id *primitive.ObjectID, hidden bool) (bool, error) {
+	result, err := db.Collection("projects").UpdateOne(
+		context.Background(), gin.H{"_id": id, "event_id": eventId}, gin.H{"$set": gin.H{"active": !hidden, "absent": false}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
 }
 
-// SetProjectsHidden sets the active fields of many projects in bulk
-func SetProjectsHidden(db *mongo.Database, ids *[]primitive.ObjectID, hidden bool) error {
+// SetProjectsHidden sets the active fields of many projects of an event in bulk, ignoring other events' projects
+func SetProjectsHidden(db *mongo.Database, eventId primitive.ObjectID, ids *[]primitive.ObjectID, hidden bool) error {
 	_, err := db.Collection("projects").UpdateMany(
-		context.Background(), gin.H{"_id": gin.H{"$in": ids}}, gin.H{"$set": gin.H{"active": !hidden}})
+		context.Background(), gin.H{"_id": gin.H{"$in": ids}, "event_id": eventId}, gin.H{"$set": gin.H{"active": !hidden, "absent": false}})
 	return err
 }
 
-// SetProjectPrioritized sets the prioritized field of a project
-func SetProjectPrioritized(db *mongo.Database, id *primitive.ObjectID, prioritized bool) error {
-	_, err := db.Collection("projects").UpdateOne(context.Background(), gin.H{"_id": id}, gin.H{"$set": gin.H{"prioritized": prioritized}})
-	return err
+// SetProjectPrioritized sets the prioritized field of a project of an event. When prioritizing, until is when the
+// priority expires, or 0 for it to last until the project is next picked. Returns false if the event has no such
+// project.
+func SetProjectPrioritized(db *mongo.Database, eventId primitive.ObjectID, id *primitive.ObjectID, prioritized bool, until primitive.DateTime) (bool, error) {
+	update := gin.H{"prioritized": false, "priority_until": primitive.DateTime(0)}
+	if prioritized {
+		update = gin.H{"prioritized": true, "prioritized_at": util.Now(), "priority_until": until}
+	}
+	result, err := db.Collection("projects").UpdateOne(context.Background(), gin.H{"_id": id, "event_id": eventId}, gin.H{"$set": update})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
 }
 
-func UpdateProjectLocationValue(db *mongo.Database, id *primitive.ObjectID, location string) error {
-	_, err := db.Collection("projects").UpdateOne(context.Background(), gin.H{"_id": id}, gin.H{"$set": gin.H{"location": location}})
-	return err
+// FindPrioritizedProjects returns the active projects of an event that are prioritized and whose priority
+// hasn't expired, in the order they were prioritized
+func FindPrioritizedProjects(db *mongo.Database, eventId primitive.ObjectID) ([]*models.Project, error) {
+	projects := make([]*models.Project, 0)
+	cursor, err := db.Collection("projects").Find(
+		context.Background(),
+		gin.H{"event_id": eventId, "active": true, "prioritized": true},
+		options.Find().SetSort(gin.H{"prioritized_at": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &projects)
+	if err != nil {
+		return nil, err
+	}
+
+	now := util.Now()
+	return slices.DeleteFunc(projects, func(p *models.Project) bool { return !p.IsPrioritized(now) }), nil
 }
 
 // UpdateProjects will update ALL projects in the database
@@ -231,3 +268,161 @@ func DecrementProjectSeenCount(db *mongo.Database, ctx context.Context, project
 	_, err := db.Collection("projects").UpdateOne(ctx, gin.H{"_id": project.Id}, gin.H{"$inc": gin.H{"seen": -1}})
 	return err
 }
+
+// ApplyProjectImport creates, updates and hides the projects of an import in bulk.
+// Only the imported details of updated projects are written, so their judging state is left untouched.
+func ApplyProjectImport(db *mongo.Database, eventId primitive.ObjectID, create []*models.Project, update []*models.Project, hide []*models.Project) error {
+	mongoModels := make([]mongo.WriteModel, 0, len(create)+len(update)+1)
+	for _, project := range create {
+		project.EventId = eventId
+		mongoModels = append(mongoModels, mongo.NewInsertOneModel().SetDocument(project))
+	}
+	for _, project := range update {
+		mongoModels = append(mongoModels, mongo.NewUpdateOneModel().SetFilter(gin.H{"_id": project.Id}).SetUpdate(gin.H{"$set": gin.H{
+			"name":           project.Name,
+			"guild":          project.Guild,
+			"guild_id":       project.GuildId,
+			"location":       project.Location,
+			"description":    project.Description,
+			"url":            project.Url,
+			"try_link":       project.TryLink,
+			"video_link":     project.VideoLink,
+			"challenge_list": project.ChallengeList,
+			"challenge_ids":  project.ChallengeIds,
+			"contact_emails": project.ContactEmails,
+		}}))
+	}
+	if len(hide) > 0 {
+		ids := make([]primitive.ObjectID, len(hide))
+		for i, project := range hide {
+			ids[i] = project.Id
+		}
+		mongoModels = append(mongoModels, mongo.NewUpdateManyModel().SetFilter(gin.H{"_id": gin.H{"$in": ids}}).SetUpdate(gin.H{"$set": gin.H{"active": false}}))
+	}
+	if len(mongoModels) == 0 {
+		return nil
+	}
+
+	opts := options.BulkWrite().SetOrdered(false)
+	_, err := db.Collection("projects").BulkWrite(context.Background(), mongoModels, opts)
+	return err
+}
+
+// UpdateProjectDetails saves the editable fields of a project and records the change in its history, in one
+// transaction. If propagate is set, the copies of the project in judges' seen projects are updated as well.
+func UpdateProjectDetails(db *mongo.Database, project *models.Project, change *models.ProjectChange, propagate bool) error {
+	return WithTransaction(db, func(ctx mongo.SessionContext) (interface{}, error) {
+		_, err := db.Collection("projects").UpdateOne(ctx, gin.H{"_id": project.Id}, gin.H{"$set": gin.H{
+			"name":           project.Name,
+			"guild":          project.Guild,
+			"guild_id":       project.GuildId,
+			"location":       project.Location,
+			"description":    project.Description,
+			"url":            project.Url,
+			"try_link":       project.TryLink,
+			"video_link":     project.VideoLink,
+			"challenge_list": project.ChallengeList,
+			"challenge_ids":  project.ChallengeIds,
+			"active":         project.Active,
+			"absent":         project.Absent,
+		}})
+		if err != nil {
+			return nil, err
+		}
+
+		if propagate {
+			_, err = db.Collection("judges").UpdateMany(
+				ctx,
+				gin.H{"event_id": project.EventId, "seen_projects.project_id": project.Id},
+				gin.H{"$set": gin.H{
+					"seen_projects.$[p].name":        project.Name,
+					"seen_projects.$[p].guild":       project.Guild,
+					"seen_projects.$[p].location":    project.Location,
+					"seen_projects.$[p].description": project.Description,
+				}},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{gin.H{"p.project_id": project.Id}}}),
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		_, err = db.Collection("project_history").InsertOne(ctx, change)
+		return nil, err
+	})
+}
+
+// FindProjectHistory returns the change history of a project, most recent first
+func FindProjectHistory(db *mongo.Database, projectId *primitive.ObjectID) ([]*models.ProjectChange, error) {
+	history := make([]*models.ProjectChange, 0)
+	cursor, err := db.Collection("project_history").Find(
+		context.Background(),
+		gin.H{"project_id": projectId},
+		options.Find().SetSort(gin.H{"changed_at": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &history)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// FindEventProjectHistory returns the change history of every project of an event, oldest first
+func FindEventProjectHistory(db *mongo.Database, eventId primitive.ObjectID) ([]*models.ProjectChange, error) {
+	history := make([]*models.ProjectChange, 0)
+	cursor, err := db.Collection("project_history").Find(
+		context.Background(),
+		gin.H{"event_id": eventId},
+		options.Find().SetSort(gin.H{"changed_at": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &history)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// UpdateProjectLocations moves projects to new locations and records each move in the project's history, in one
+// transaction. Judges' copies of the projects are updated too, since they say where to find the project.
+func UpdateProjectLocations(db *mongo.Database, eventId primitive.ObjectID, locations map[primitive.ObjectID]string, history []*models.ProjectChange) error {
+	if len(locations) == 0 {
+		return nil
+	}
+	return WithTransaction(db, func(ctx mongo.SessionContext) (interface{}, error) {
+		projectModels := make([]mongo.WriteModel, 0, len(locations))
+		judgeModels := make([]mongo.WriteModel, 0, len(locations))
+		for id, location := range locations {
+			projectModels = append(projectModels, mongo.NewUpdateOneModel().
+				SetFilter(gin.H{"_id": id, "event_id": eventId}).
+				SetUpdate(gin.H{"$set": gin.H{"location": location}}))
+			judgeModels = append(judgeModels, mongo.NewUpdateManyModel().
+				SetFilter(gin.H{"event_id": eventId, "seen_projects.project_id": id}).
+				SetUpdate(gin.H{"$set": gin.H{"seen_projects.$[p].location": location}}).
+				SetArrayFilters(options.ArrayFilters{Filters: []interface{}{gin.H{"p.project_id": id}}}))
+		}
+
+		_, err := db.Collection("projects").BulkWrite(ctx, projectModels)
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Collection("judges").BulkWrite(ctx, judgeModels)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(history) > 0 {
+			docs := make([]interface{}, len(history))
+			for i, change := range history {
+				docs[i] = change
+			}
+			_, err = db.Collection("project_history").InsertMany(ctx, docs)
+		}
+		return nil, err
+	})
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FindResults returns the results of an event, or nil if they haven't been generated
+func FindResults(db *mongo.Database, eventId primitive.ObjectID) (*models.Results, error) {
+	var results models.Results
+	err := db.Collection("results").FindOne(context.Background(), gin.H{"event_id": eventId}).Decode(&results)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// SaveResults replaces the results of an event
+func SaveResults(db *mongo.Database, results *models.Results) error {
+	var saved models.Results
+	err := db.Collection("results").FindOneAndReplace(
+		context.Background(),
+		gin.H{"event_id": results.EventId},
+		results,
+		options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&saved)
+	if err != nil {
+		return err
+	}
+	results.Id = saved.Id
+	return nil
+}
+
+// UpdateResultsReveal sets whether the results of an event are published and how many places are revealed
+func UpdateResultsReveal(db *mongo.Database, results *models.Results) error {
+	_, err := db.Collection("results").UpdateOne(
+		context.Background(),
+		gin.H{"_id": results.Id},
+		gin.H{"$set": gin.H{"published": results.Published, "revealed": results.Revealed}},
+	)
+	return err
+}
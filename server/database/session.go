@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"server/models"
+	"server/util"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureTTLIndex makes the documents of a collection expire a number of seconds after the time in a field.
+// If the field already has a TTL index with a different expiry, the expiry is changed.
+func EnsureTTLIndex(db *mongo.Database, collection string, field string, seconds int32) error {
+	_, err := db.Collection(collection).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(seconds),
+	})
+
+	// An index on the field with other options already exists, so change its expiry instead
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && (commandErr.Name == "IndexOptionsConflict" || commandErr.Name == "IndexKeySpecsConflict") {
+		return db.RunCommand(context.Background(), bson.D{
+			{Key: "collMod", Value: collection},
+			{Key: "index", Value: bson.D{
+				{Key: "keyPattern", Value: bson.D{{Key: field, Value: 1}}},
+				{Key: "expireAfterSeconds", Value: seconds},
+			}},
+		}).Err()
+	}
+	return err
+}
+
+// SaveUserSession records who a session belongs to, replacing any previous record of the session
+func SaveUserSession(db *mongo.Database, userSession *models.UserSession) error {
+	_, err := db.Collection("user_sessions").ReplaceOne(
+		context.Background(),
+		gin.H{"_id": userSession.Id},
+		userSession,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// FindActiveUserSessions returns the sessions which haven't expired yet, newest first
+func FindActiveUserSessions(db *mongo.Database) ([]*models.UserSession, error) {
+	userSessions := make([]*models.UserSession, 0)
+	cursor, err := db.Collection("user_sessions").Find(
+		context.Background(),
+		gin.H{"expires_at": gin.H{"$gt": util.Now()}},
+		options.Find().SetSort(gin.H{"created_at": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &userSessions)
+	return userSessions, err
+}
+
+// FindUserSession returns the record of a session, or nil if there is none
+func FindUserSession(db *mongo.Database, id primitive.ObjectID) (*models.UserSession, error) {
+	var userSession models.UserSession
+	err := db.Collection("user_sessions").FindOne(context.Background(), gin.H{"_id": id}).Decode(&userSession)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &userSession, nil
+}
+
+// DeleteUserSessionRecord deletes the record of a session, e.g. when the user logs out
+func DeleteUserSessionRecord(db *mongo.Database, id primitive.ObjectID) error {
+	_, err := db.Collection("user_sessions").DeleteOne(context.Background(), gin.H{"_id": id})
+	return err
+}
+
+// RevokeUserSession deletes a session and its record. If the user has no other sessions, their stored
+// token set is deleted too. Returns whether the token set was deleted.
+func RevokeUserSession(db *mongo.Database, userSession *models.UserSession) (bool, error) {
+	tokenSetDeleted := false
+	err := WithTransaction(db, func(sc mongo.SessionContext) (interface{}, error) {
+		_, err := db.Collection("sessions").DeleteOne(sc, gin.H{"_id": userSession.Id})
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Collection("user_sessions").DeleteOne(sc, gin.H{"_id": userSession.Id})
+		if err != nil {
+			return nil, err
+		}
+
+		remaining, err := db.Collection("user_sessions").CountDocuments(sc, gin.H{"user_id": userSession.UserId, "expires_at": gin.H{"$gt": util.Now()}})
+		if err != nil {
+			return nil, err
+		}
+		if remaining == 0 {
+			_, err = db.Collection("token_set").DeleteMany(sc, gin.H{"user_id": userSession.UserId})
+			tokenSetDeleted = err == nil
+		}
+		return nil, err
+	})
+	return tokenSetDeleted, err
+}
+
+// RevokeUserSessions deletes every session of a user, their records and the user's stored token set.
+// Returns the number of sessions revoked.
+func RevokeUserSessions(db *mongo.Database, userId string) (int, error) {
+	revoked := 0
+	err := WithTransaction(db, func(sc mongo.SessionContext) (interface{}, error) {
+		ids := make([]primitive.ObjectID, 0)
+		cursor, err := db.Collection("user_sessions").Find(sc, gin.H{"user_id": userId})
+		if err != nil {
+			return nil, err
+		}
+		var userSessions []*models.UserSession
+		err = cursor.All(sc, &userSessions)
+		if err != nil {
+			return nil, err
+		}
+		for _, userSession := range userSessions {
+			ids = append(ids, userSession.Id)
+		}
+
+		_, err = db.Collection("sessions").DeleteMany(sc, gin.H{"_id": gin.H{"$in": ids}})
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Collection("user_sessions").DeleteMany(sc, gin.H{"user_id": userId})
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Collection("token_set").DeleteMany(sc, gin.H{"user_id": userId})
+		revoked = len(ids)
+		return nil, err
+	})
+	return revoked, err
+}
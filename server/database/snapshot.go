@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BuildEventBundle collects the entire state of an event into a bundle
+func BuildEventBundle(db *mongo.Database, event *models.Event) (*models.EventBundle, error) {
+	eventOptions, err := GetOptions(db, event.Id)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := FindAllProjects(db, event.Id)
+	if err != nil {
+		return nil, err
+	}
+	judges, err := FindAllJudges(db, event.Id)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := FindAllFlags(db, event.Id)
+	if err != nil {
+		return nil, err
+	}
+	zones, err := FindTableZones(db, event.Id)
+	if err != nil {
+		return nil, err
+	}
+	guilds, err := FindGuilds(db, event.Id)
+	if err != nil {
+		return nil, err
+	}
+	challenges, err := FindChallenges(db, event.Id)
+	if err != nil {
+		return nil, err
+	}
+	history, err := FindEventProjectHistory(db, event.Id)
+	if err != nil {
+		return nil, err
+	}
+	results, err := FindResults(db, event.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.EventBundle{
+		Version:    models.EventBundleVersion,
+		Event:      event,
+		Options:    eventOptions,
+		Projects:   projects,
+		Judges:     judges,
+		Flags:      flags,
+		Zones:      zones,
+		Guilds:     guilds,
+		Challenges: challenges,
+		History:    history,
+		Results:    results,
+	}, nil
+}
+
+// CreateSnapshot stores the current state of an event as a new snapshot
+func CreateSnapshot(db *mongo.Database, event *models.Event, reason string) (*models.Snapshot, error) {
+	bundle, err := BuildEventBundle(db, event)
+	if err != nil {
+		return nil, err
+	}
+	data, err := models.EncodeEventBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := models.NewSnapshot(bundle, reason, data)
+	res, err := db.Collection("snapshots").InsertOne(context.Background(), snapshot)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Id = res.InsertedID.(primitive.ObjectID)
+	return snapshot, nil
+}
+
+// FindSnapshots returns the snapshots of an event, newest first, without their data
+func FindSnapshots(db *mongo.Database, eventId primitive.ObjectID) ([]*models.Snapshot, error) {
+	snapshots := make([]*models.Snapshot, 0)
+	cursor, err := db.Collection("snapshots").Find(
+		context.Background(),
+		gin.H{"event_id": eventId},
+		options.Find().SetSort(gin.H{"created_at": -1}).SetProjection(gin.H{"data": 0}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &snapshots)
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// FindSnapshotById returns a snapshot of an event, including its data, or nil if it does not exist
+func FindSnapshotById(db *mongo.Database, eventId primitive.ObjectID, id *primitive.ObjectID) (*models.Snapshot, error) {
+	var snapshot models.Snapshot
+	err := db.Collection("snapshots").FindOne(context.Background(), gin.H{"_id": id, "event_id": eventId}).Decode(&snapshot)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// bundledCollections returns the event collections which a bundle has the contents of. Bundles written before
+// a collection was added to them don't have it, so it is left alone when they are restored.
+func bundledCollections(bundle *models.EventBundle) []string {
+	return slices.DeleteFunc(slices.Clone(eventCollections), func(c string) bool {
+		return (c == "project_history" || c == "results") && bundle.Version < 2
+	})
+}
+
+// RestoreEventBundle replaces all projects, judges, flags, options, table zones, guilds, challenges, project
+// history and results of an event with those in the bundle.
+// Everything is written to the given event, whichever event the bundle was taken from.
+func RestoreEventBundle(db *mongo.Database, eventId primitive.ObjectID, bundle *models.EventBundle) error {
+	return WithTransaction(db, func(ctx mongo.SessionContext) (interface{}, error) {
+		// Remove the current state of the event
+		for _, c := range bundledCollections(bundle) {
+			if _, err := db.Collection(c).DeleteMany(ctx, gin.H{"event_id": eventId}); err != nil {
+				return nil, err
+			}
+		}
+
+		// Insert the options
+		bundle.Options.EventId = eventId
+		if _, err := db.Collection("options").InsertOne(ctx, bundle.Options); err != nil {
+			return nil, err
+		}
+
+		// Insert the results, if there were any
+		if bundle.Results != nil {
+			bundle.Results.EventId = eventId
+			if _, err := db.Collection("results").InsertOne(ctx, bundle.Results); err != nil {
+				return nil, err
+			}
+		}
+
+		// Insert the projects, judges, flags, zones, guilds, challenges and project history
+		var projects, judges, flags, zones, guilds, challenges, history []interface{}
+		for _, project := range bundle.Projects {
+			project.EventId = eventId
+			projects = append(projects, project)
+		}
+		for _, judge := range bundle.Judges {
+			judge.EventId = eventId
+			judges = append(judges, judge)
+		}
+		for _, flag := range bundle.Flags {
+			flag.EventId = eventId
+			flags = append(flags, flag)
+		}
+		for _, zone := range bundle.Zones {
+			zone.EventId = eventId
+			zones = append(zones, zone)
+		}
+		for _, guild := range bundle.Guilds {
+			guild.EventId = eventId
+			guilds = append(guilds, guild)
+		}
+		for _, challenge := range bundle.Challenges {
+			challenge.EventId = eventId
+			challenges = append(challenges, challenge)
+		}
+		for _, change := range bundle.History {
+			change.EventId = eventId
+			history = append(history, change)
+		}
+		collections := map[string][]interface{}{
+			"projects":        projects,
+			"judges":          judges,
+			"flags":           flags,
+			"table_zones":     zones,
+			"guilds":          guilds,
+			"challenges":      challenges,
+			"project_history": history,
+		}
+		for c, docs := range collections {
+			if len(docs) == 0 {
+				continue
+			}
+			if _, err := db.Collection(c).InsertMany(ctx, docs); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	})
+}
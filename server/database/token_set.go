@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FindTokenSet returns the stored token set of a user, or nil if there is none
+func FindTokenSet(db *mongo.Database, userId string) (*models.TokenSet, error) {
+	var tokenSet models.TokenSet
+	err := db.Collection("token_set").FindOne(context.Background(), gin.H{"user_id": userId}).Decode(&tokenSet)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tokenSet, nil
+}
+
+// FindAllTokenSets returns every stored token set
+func FindAllTokenSets(db *mongo.Database) ([]*models.TokenSet, error) {
+	tokenSets := make([]*models.TokenSet, 0)
+	cursor, err := db.Collection("token_set").Find(context.Background(), gin.H{})
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &tokenSets)
+	return tokenSets, err
+}
+
+// SaveTokenSet saves the token set of a user, replacing their previous one
+func SaveTokenSet(db *mongo.Database, tokenSet *models.TokenSet) error {
+	_, err := db.Collection("token_set").ReplaceOne(
+		context.Background(),
+		gin.H{"user_id": tokenSet.UserId},
+		tokenSet,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// DeleteTokenSet deletes the stored token set of a user
+func DeleteTokenSet(db *mongo.Database, userId string) error {
+	_, err := db.Collection("token_set").DeleteMany(context.Background(), gin.H{"user_id": userId})
+	return err
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InsertTableZone inserts a table zone into an event
+func InsertTableZone(db *mongo.Database, eventId primitive.ObjectID, zone *models.TableZone) error {
+	zone.EventId = eventId
+	res, err := db.Collection("table_zones").InsertOne(context.Background(), zone)
+	if err != nil {
+		return err
+	}
+	zone.Id = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindTableZones returns the table zones of an event, ordered by guild then first table
+func FindTableZones(db *mongo.Database, eventId primitive.ObjectID) ([]*models.TableZone, error) {
+	zones := make([]*models.TableZone, 0)
+	cursor, err := db.Collection("table_zones").Find(
+		context.Background(),
+		gin.H{"event_id": eventId},
+		options.Find().SetSort(bson.D{{Key: "guild", Value: 1}, {Key: "first_table", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &zones)
+	if err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// FindTableZoneById returns a table zone of an event, or nil if it does not exist
+func FindTableZoneById(db *mongo.Database, eventId primitive.ObjectID, id *primitive.ObjectID) (*models.TableZone, error) {
+	var zone models.TableZone
+	err := db.Collection("table_zones").FindOne(context.Background(), gin.H{"_id": id, "event_id": eventId}).Decode(&zone)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+// UpdateTableZone saves the details of a table zone
+func UpdateTableZone(db *mongo.Database, zone *models.TableZone) error {
+	_, err := db.Collection("table_zones").UpdateOne(context.Background(), gin.H{"_id": zone.Id}, gin.H{"$set": zone})
+	return err
+}
+
+// DeleteTableZone deletes a table zone of an event
+func DeleteTableZone(db *mongo.Database, eventId primitive.ObjectID, id *primitive.ObjectID) error {
+	_, err := db.Collection("table_zones").DeleteOne(context.Background(), gin.H{"_id": id, "event_id": eventId})
+	return err
+}
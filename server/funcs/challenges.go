@@ -0,0 +1,61 @@
+package funcs
+
+import (
+	"server/models"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CanonicaliseChallengeList replaces the names in a challenge list with the names of the challenges they match,
+// tidies the spacing of the rest and removes duplicates
+func CanonicaliseChallengeList(challenges []*models.Challenge, list []string) []string {
+	canonical := make([]string, 0, len(list))
+	seen := make(map[string]bool)
+	for _, name := range list {
+		name = strings.Join(strings.Fields(name), " ")
+		if name == "" {
+			continue
+		}
+		if challenge := models.FindChallengeByName(challenges, name); challenge != nil {
+			name = challenge.Name
+		}
+		if key := models.NormaliseChallengeName(name); !seen[key] {
+			seen[key] = true
+			canonical = append(canonical, name)
+		}
+	}
+	return canonical
+}
+
+// MergeChallengeEntries moves the projects that entered any of the merged challenges into the target challenge,
+// rebuilding their challenge lists from names, which must hold the name of every challenge they entered.
+// Returns the projects that changed.
+func MergeChallengeEntries(projects []*models.Project, targetId primitive.ObjectID, merged map[primitive.ObjectID]bool, names map[primitive.ObjectID]string) []*models.Project {
+	changed := make([]*models.Project, 0)
+	for _, project := range projects {
+		ids := make([]primitive.ObjectID, 0, len(project.ChallengeIds))
+		moved := false
+		for _, id := range project.ChallengeIds {
+			if merged[id] {
+				id = targetId
+				moved = true
+			}
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		if !moved {
+			continue
+		}
+
+		project.ChallengeIds = ids
+		project.ChallengeList = make([]string, len(ids))
+		for i, id := range ids {
+			project.ChallengeList[i] = names[id]
+		}
+		changed = append(changed, project)
+	}
+	return changed
+}
@@ -0,0 +1,42 @@
+package funcs_test
+
+import (
+	"server/funcs"
+	"server/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCanonicaliseChallengeList(t *testing.T) {
+	lockheed := models.NewChallenge("Lockheed Martin")
+	lockheed.Aliases = []string{"Best Use of Space Tech"}
+
+	list := funcs.CanonicaliseChallengeList([]*models.Challenge{lockheed}, []string{"Lockheed Martin ", " best use of  space tech", "Best  Design", "", "best design"})
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 challenges, got %d: %v", len(list), list)
+	}
+	Assert(t, list[0], "Lockheed Martin")
+	Assert(t, list[1], "Best Design")
+}
+
+func TestMergeChallengeEntries(t *testing.T) {
+	target, dupe, other := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	names := map[primitive.ObjectID]string{target: "Lockheed Martin", dupe: "Lockheed", other: "Best Design"}
+
+	both := newTableProject("Arke", "", "1")
+	both.ChallengeIds = []primitive.ObjectID{target, dupe, other}
+	onlyDupe := newTableProject("Nub", "", "2")
+	onlyDupe.ChallengeIds = []primitive.ObjectID{dupe}
+	untouched := newTableProject("Zap", "", "3")
+	untouched.ChallengeIds = []primitive.ObjectID{other}
+
+	changed := funcs.MergeChallengeEntries([]*models.Project{both, onlyDupe, untouched}, target, map[primitive.ObjectID]bool{dupe: true}, names)
+	if len(changed) != 2 {
+		t.Fatalf("Expected 2 changed projects, got %d", len(changed))
+	}
+	Assert(t, len(both.ChallengeIds), 2)
+	Assert(t, both.ChallengeList[1], "Best Design")
+	Assert(t, onlyDupe.ChallengeIds[0], target)
+	Assert(t, onlyDupe.ChallengeList[0], "Lockheed Martin")
+}
@@ -7,7 +7,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"server/models"
@@ -17,141 +21,289 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// Read CSV file and return a slice of project structs
-func ParseProjectCsv(content string, hasHeader bool) ([]*models.Project, error) {
+// ProjectCsvColumn describes a column of the generic project CSV format
+type ProjectCsvColumn struct {
+	Header      string `json:"header"`
+	Required    bool   `json:"required"`
+	Description string `json:"description"`
+	Example     string `json:"example"`
+}
+
+// ProjectCsvColumns are the columns of the generic project CSV format. Files without a header must have the
+// columns in this order; files with a header may have them in any order and leave out the optional ones.
+var ProjectCsvColumns = []ProjectCsvColumn{
+	{"Name", true, "Name of the project", "Jury"},
+	{"Location", false, "Table number or location of the project", "12"},
+	{"Description", false, "Short description of the project", "A judging system for hackathons"},
+	{"URL", false, "Link to the project's submission page", "https://devpost.com/software/jury"},
+	{"Try Link", false, "Link to try the project out", "https://jury.example.com"},
+	{"Video Link", false, "Link to a video demo of the project", "https://youtu.be/example"},
+	{"Challenges", false, "Comma separated list of the challenges the project entered", "Best Use of AI, Best Design"},
+	{"Guild", false, "Guild or megateam of the project", "Grand Dragon"},
+}
+
+// CreateProjectCsvTemplate creates a CSV file with the header of the generic project CSV format and an example row
+func CreateProjectCsvTemplate() []byte {
+	header := make([]string, len(ProjectCsvColumns))
+	example := make([]string, len(ProjectCsvColumns))
+	for i, column := range ProjectCsvColumns {
+		header[i] = column.Header
+		example[i] = column.Example
+	}
+
+	csvBuffer := &bytes.Buffer{}
+	w := csv.NewWriter(csvBuffer)
+	w.Write(header)
+	w.Write(example)
+	w.Flush()
+	return csvBuffer.Bytes()
+}
+
+// Read a CSV file in the generic project format (see ProjectCsvColumns) and return the projects along with the
+// lines they were read from. If the file has a header, columns are found by their header, otherwise by their
+// position. Rows that fail validation are skipped and reported with their line number so that the valid rows can
+// still be imported; an error is only returned if the file as a whole can't be read.
+func ParseProjectCsv(content string, hasHeader bool) ([]*CsvProject, []CsvIssue, error) {
 	r := csv.NewReader(strings.NewReader(content))
+	r.FieldsPerRecord = -1
 
 	// Empty CSV file
 	if content == "" {
-		return []*models.Project{}, nil
+		return []*CsvProject{}, []CsvIssue{}, nil
 	}
 
-	// If the CSV file has a header, skip the first line
+	// Find the columns, from the header if there is one
+	cols := make([]int, len(ProjectCsvColumns))
+	for i := range cols {
+		cols[i] = i
+	}
 	if hasHeader {
-		r.Read()
+		header, err := r.Read()
+		if err != nil {
+			return nil, nil, err
+		}
+		findColumn := headerIndexer(header)
+		for i, column := range ProjectCsvColumns {
+			cols[i] = findColumn(column.Header)
+			if cols[i] == -1 && column.Required {
+				return nil, nil, fmt.Errorf("the header must contain the '%s' column: '%s'", column.Header, strings.Join(header, ","))
+			}
+		}
 	}
+	nameCol, locationCol, descriptionCol, urlCol, tryLinkCol, videoLinkCol, challengesCol, guildCol :=
+		cols[0], cols[1], cols[2], cols[3], cols[4], cols[5], cols[6], cols[7]
 
 	// Read the CSV file, looping through each record
-	var projects []*models.Project
+	projects := make([]*CsvProject, 0)
+	issues := make([]CsvIssue, 0)
 	for {
 		record, err := r.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-
-		// Make sure the record has at least 4 elements (name, location, description, URL)
-		if len(record) < 4 {
-			return nil, fmt.Errorf("record contains less than 4 elements: '%s'", strings.Join(record, ","))
+		line, _ := r.FieldPos(0)
+		field := func(i int) string {
+			if i == -1 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
 		}
 
-		// Get the challenge list
-		var challengeList []string
-		if len(record) > 5 && record[6] != "" {
-			challengeList = strings.Split(record[6], ",")
-		}
-		for i := range challengeList {
-			challengeList[i] = strings.TrimSpace(challengeList[i])
+		// Skip blank rows
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
 		}
 
-		// Optional fields
-		var tryLink string
-		if len(record) > 3 && record[4] != "" {
-			tryLink = record[3]
+		// Validate the row
+		rowIssues := make([]CsvIssue, 0)
+		if field(nameCol) == "" {
+			rowIssues = append(rowIssues, CsvIssue{line, "project has no name"})
 		}
-		var videoLink string
-		if len(record) > 4 && record[5] != "" {
-			videoLink = record[4]
+		for _, col := range []int{urlCol, tryLinkCol, videoLinkCol} {
+			if link := field(col); link != "" && !isHttpUrl(link) {
+				rowIssues = append(rowIssues, CsvIssue{line, fmt.Sprintf("'%s' is not a valid http(s) link", link)})
+			}
+		}
+		if len(rowIssues) > 0 {
+			issues = append(issues, rowIssues...)
+			continue
 		}
 
 		// Add project to slice
-		projects = append(projects, models.NewProject(record[0], "", record[1], record[2], record[3], tryLink, videoLink, challengeList))
+		projects = append(projects, &CsvProject{line, models.NewProject(
+			field(nameCol),
+			field(guildCol),
+			field(locationCol),
+			field(descriptionCol),
+			field(urlCol),
+			field(tryLinkCol),
+			field(videoLinkCol),
+			splitList(field(challengesCol)),
+		)})
 	}
 
-	return projects, nil
+	return projects, issues, nil
+}
+
+// CsvProject is a project parsed from a CSV file, along with the line it was read from
+type CsvProject struct {
+	Line    int             `json:"line"`
+	Project *models.Project `json:"project"`
+}
+
+// CsvIssue is a problem found while parsing a CSV file. Line is 0 for problems with the file as a whole.
+type CsvIssue struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
 }
 
-// TODO: After event, devpost will add a column between 0 and 1, the "auto assigned table numbers" TT - idk what to do abt this
-// Generate a workable CSV for Jury based on the output CSV from Devpost
-// Columns:
-//  0. Project Title - title
-//  1. Submission Url - url
-//  2. Project Status - Draft or Submitted (ignore drafts)
-//  3. Judging Status - ignore
-//  4. Highest Step Completed - ignore
-//  5. Project Created At - ignore
-//  6. About The Project - description
-//  7. "Try it out" Links" - try_link
-//  8. Video Demo Link - video_link
-//  9. Opt-In Prizes - challenge_list
-//  10. Built With - ignore
-//  11. Notes - ignore
-//  12. Team Colleges/Universities - ignore
-//  13. Additional Team Member Count - ignore
-//  14. !Megateam/Guild - guild  [column O in excel]
-//  15. !Table number - location [column P in excel]
-//
-// (16+.and remiaining columns) Custom questions - custom_questions (ignore for now)
-func ParseDevpostCSV(content string) ([]*models.Project, error) {
+// Generate a workable CSV for Jury based on the output CSV from Devpost.
+// Columns are found by their header (see models.DevpostColumns) rather than their position, since
+// Devpost adds columns after the event, e.g. the "auto assigned table numbers" column.
+// The title and submission URL columns are required; any other missing column is reported as a warning
+// and left empty. Drafts are skipped.
+func ParseDevpostCSV(content string, columns models.DevpostColumns) ([]*CsvProject, []CsvIssue, error) {
 	r := csv.NewReader(strings.NewReader(content))
+	r.FieldsPerRecord = -1
 
 	// Empty CSV file
 	if content == "" {
-		return []*models.Project{}, nil
+		return []*CsvProject{}, []CsvIssue{}, nil
 	}
 
-	// Skip the first line
-	_, err := r.Read()
+	// Find the columns from the header
+	header, err := r.Read()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	columns = columns.WithDefaults()
+	findColumn := headerIndexer(header)
+	warnings := make([]CsvIssue, 0)
+	optionalColumn := func(name string, header string) int {
+		i := findColumn(header)
+		if i == -1 {
+			warnings = append(warnings, CsvIssue{0, fmt.Sprintf("no '%s' column found for the project %s, it will be left empty", header, name)})
+		}
+		return i
+	}
+	nameCol, urlCol := findColumn(columns.Name), findColumn(columns.Url)
+	if nameCol == -1 || urlCol == -1 {
+		return nil, nil, fmt.Errorf("the header must contain the '%s' and '%s' columns (invalid devpost csv): '%s'", columns.Name, columns.Url, strings.Join(header, ","))
 	}
+	statusCol := optionalColumn("status", columns.Status)
+	descriptionCol := optionalColumn("description", columns.Description)
+	tryLinkCol := optionalColumn("try link", columns.TryLink)
+	videoLinkCol := optionalColumn("video link", columns.VideoLink)
+	challengesCol := optionalColumn("challenges", columns.Challenges)
+	guildCol := optionalColumn("guild", columns.Guild)
+	locationCol := optionalColumn("location", columns.Location)
+	emailCol := optionalColumn("contact email", columns.Email)
 
 	// Read the CSV file, looping through each record
-	var projects []*models.Project
+	projects := make([]*CsvProject, 0)
 	for {
 		record, err := r.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-
-		// Make sure the record has 14 or more elements (see above)
-		if len(record) < 14 {
-			return nil, fmt.Errorf("record does not contain 15 or more elements (invalid devpost csv): '%s'", strings.Join(record, ","))
+		line, _ := r.FieldPos(0)
+		field := func(i int) string {
+			if i == -1 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
 		}
 
 		// If the project is a Draft, skip it
-		if record[2] == "Draft" {
+		if strings.EqualFold(field(statusCol), "Draft") {
 			continue
 		}
 
-		// Split challenge list into a slice and trim them
-		challengeList := strings.Split(record[9], ",")
-		if record[9] == "" {
-			challengeList = []string{}
+		// Check the row
+		if len(record) != len(header) {
+			warnings = append(warnings, CsvIssue{line, fmt.Sprintf("row has %d columns but the header has %d", len(record), len(header))})
 		}
-		for i := range challengeList {
-			challengeList[i] = strings.TrimSpace(challengeList[i])
+		if field(nameCol) == "" {
+			warnings = append(warnings, CsvIssue{line, "project has no title and was skipped"})
+			continue
+		}
+		if field(urlCol) == "" {
+			warnings = append(warnings, CsvIssue{line, fmt.Sprintf("project '%s' has no submission URL", field(nameCol))})
+		}
+		if locationCol != -1 && field(locationCol) == "" {
+			warnings = append(warnings, CsvIssue{line, fmt.Sprintf("project '%s' has no location", field(nameCol))})
 		}
 
 		// Add project to slice
-		projects = append(projects, models.NewProject(
-			record[0],
-			record[14],
-			record[15],
-			record[6],
-			record[1],
-			record[7],
-			record[8],
-			challengeList,
-		))
+		project := models.NewProject(
+			field(nameCol),
+			field(guildCol),
+			field(locationCol),
+			field(descriptionCol),
+			field(urlCol),
+			field(tryLinkCol),
+			field(videoLinkCol),
+			splitList(field(challengesCol)),
+		)
+		project.ContactEmails = splitList(strings.ReplaceAll(field(emailCol), ";", ","))
+		projects = append(projects, &CsvProject{line, project})
 	}
 
-	return projects, nil
+	return projects, warnings, nil
+}
+
+// headerIndexer returns a function that finds the index of a column in a CSV header, or -1 if there is no
+// such column. Headers are compared ignoring case, spacing and punctuation.
+func headerIndexer(header []string) func(string) int {
+	indices := make(map[string]int, len(header))
+	for i, h := range header {
+		key := normaliseHeader(h)
+		if _, ok := indices[key]; !ok {
+			indices[key] = i
+		}
+	}
+	return func(h string) int {
+		i, ok := indices[normaliseHeader(h)]
+		if !ok {
+			return -1
+		}
+		return i
+	}
+}
+
+// normaliseHeader lower-cases a CSV header and strips everything but letters and digits
+func normaliseHeader(h string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(h) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// splitList splits a comma separated list, trimming each item and dropping empty ones
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// isHttpUrl checks that a string is an absolute http or https URL
+func isHttpUrl(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
 }
 
 // Adapted from https://stackoverflow.com/a/74700627/7253717
@@ -170,6 +322,13 @@ func AddCsvData(name string, content []byte, ctx *gin.Context) {
 	ctx.Data(http.StatusOK, "text/csv", content)
 }
 
+// AddJsonFile adds a JSON file to the response as a download
+func AddJsonFile(name string, content []byte, ctx *gin.Context) {
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", name))
+	ctx.Header("Content-Type", "application/json")
+	ctx.Data(http.StatusOK, "application/json", content)
+}
+
 // AddZipFile adds a zip file to the response
 func AddZipFile(name string, content []byte, ctx *gin.Context) {
 	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.zip", name))
@@ -177,49 +336,87 @@ func AddZipFile(name string, content []byte, ctx *gin.Context) {
 	ctx.Data(http.StatusOK, "application/octet-stream", content)
 }
 
-// Create a CSV file from the judges but only the rankings
-func CreateJudgeRankingCSV(judges []*models.Judge) []byte {
+// BuildJudgeBatchRankings lists every batch ranking submitted by the judges, resolving each ranked
+// project against the judge's seen projects to get its name, location and category scores
+func BuildJudgeBatchRankings(judges []*models.Judge, judgeNames map[primitive.ObjectID]string) []*models.JudgeBatchRanking {
+	batches := make([]*models.JudgeBatchRanking, 0)
+	for _, judge := range judges {
+		for i, batch := range judge.PastRankings {
+			var submittedAt int64
+			if i < len(judge.PastRankingsAt) {
+				submittedAt = int64(judge.PastRankingsAt[i])
+			}
+
+			projects := make([]models.RankedJudgedProject, 0, len(batch))
+			for _, projId := range batch {
+				idx := util.IndexFunc(judge.SeenProjects, func(p models.JudgedProject) bool {
+					return p.ProjectId == projId
+				})
+				if idx == -1 {
+					projects = append(projects, models.RankedJudgedProject{ProjectId: projId, Categories: map[string]int{}})
+					continue
+				}
+				proj := judge.SeenProjects[idx]
+				projects = append(projects, models.RankedJudgedProject{
+					ProjectId:  projId,
+					Name:       proj.Name,
+					Location:   proj.GetLocationString(),
+					Categories: proj.Categories,
+				})
+			}
+
+			batches = append(batches, &models.JudgeBatchRanking{
+				JudgeId:     judge.Id,
+				JudgeName:   judgeNames[judge.Id],
+				BatchIndex:  i,
+				SubmittedAt: submittedAt,
+				Projects:    projects,
+			})
+		}
+	}
+	return batches
+}
+
+// Create a CSV file from the judges' batch rankings, with one row per batch. The project, location and
+// category score columns each list the batch's projects from first to last place, separated by semicolons.
+func CreateJudgeRankingCSV(batches []*models.JudgeBatchRanking, categories []string) []byte {
 	csvBuffer := &bytes.Buffer{}
 
 	// Create a new CSV writer
 	w := csv.NewWriter(csvBuffer)
 
 	// Write the header
-	// todo: remove unranked concept fully from export too
-	w.Write([]string{"Name", "Code", "Ranked", "Unranked"})
+	w.Write(append([]string{"Judge", "Judge ID", "Batch", "Submitted At", "Projects", "Locations"}, categories...))
 
-	// Write each judge
-	for _, judge := range judges {
-		// Don't include if their rankings are empty :/
-		if len(judge.CurrentRankings) == 0 {
-			continue
+	// Write each batch
+	for _, batch := range batches {
+		submittedAt := ""
+		if batch.SubmittedAt != 0 {
+			submittedAt = primitive.DateTime(batch.SubmittedAt).Time().UTC().Format(time.RFC3339)
 		}
 
-		// Create a list of all ranked projects (just their location)
-		// todo: update for new ranking object structures which requires past_rankings to be considered (2D array)
-		ranked := make([]string, 0, len(judge.CurrentRankings))
-		for _, projId := range judge.CurrentRankings {
-			idx := util.IndexFunc(judge.SeenProjects, func(p models.JudgedProject) bool {
-				return p.ProjectId == projId
-			})
-			if idx == -1 {
-				continue
+		names := make([]string, 0, len(batch.Projects))
+		locations := make([]string, 0, len(batch.Projects))
+		scores := make([][]string, len(categories))
+		for _, proj := range batch.Projects {
+			names = append(names, proj.Name)
+			locations = append(locations, proj.Location)
+			for i, category := range categories {
+				score, ok := proj.Categories[category]
+				if ok {
+					scores[i] = append(scores[i], strconv.Itoa(score))
+				} else {
+					scores[i] = append(scores[i], "")
+				}
 			}
-			proj := judge.SeenProjects[idx]
-			ranked = append(ranked, proj.GetLocationString())
 		}
 
-		// Create a list of all unranked projects (filter using ranked projects)
-		unranked := make([]string, 0, len(judge.SeenProjects)-len(judge.CurrentRankings))
-		for _, proj := range judge.SeenProjects {
-			if util.ContainsFunc(ranked, func(location string) bool { return location == proj.Location }) {
-				unranked = append(unranked, proj.Location)
-			}
+		row := []string{batch.JudgeName, batch.JudgeId.Hex(), strconv.Itoa(batch.BatchIndex + 1), submittedAt,
+			strings.Join(names, "; "), strings.Join(locations, "; ")}
+		for _, categoryScores := range scores {
+			row = append(row, strings.Join(categoryScores, "; "))
 		}
-
-		// Write line to CSV
-		// todo: get judge info (using admin api as when getting judges for listing) to write to csv
-		w.Write([]string{judge.KeycloakUserId, strings.Join(ranked, ","), strings.Join(unranked, ",")})
+		w.Write(row)
 	}
 
 	// Flush the writer
@@ -262,24 +459,27 @@ func CreateProjectCSV(projects []*models.Project, scores []ranking.RankedObject)
 func CreateProjectChallengeZip(projects []*models.Project, scores []ranking.RankedObject) ([]byte, error) {
 	var csvList [][]byte
 
-	// Get list of challenges
+	// Get list of challenges, treating names that differ only in case or spacing as the same challenge
 	var challengeList []string
+	challengeProjects := make(map[string][]*models.Project)
 	for _, project := range projects {
+		entered := make(map[string]bool)
 		for _, challenge := range project.ChallengeList {
-			if !contains(challengeList, challenge) {
-				challengeList = append(challengeList, challenge)
+			key := models.NormaliseChallengeName(challenge)
+			if key == "" || entered[key] {
+				continue
 			}
+			entered[key] = true
+			if _, ok := challengeProjects[key]; !ok {
+				challengeList = append(challengeList, strings.Join(strings.Fields(challenge), " "))
+			}
+			challengeProjects[key] = append(challengeProjects[key], project)
 		}
 	}
 
 	// Create a CSV for each challenge
 	for _, challenge := range challengeList {
-		var currChallengeProjects []*models.Project
-		for _, project := range projects {
-			if contains(project.ChallengeList, challenge) {
-				currChallengeProjects = append(currChallengeProjects, project)
-			}
-		}
+		currChallengeProjects := challengeProjects[models.NormaliseChallengeName(challenge)]
 
 		// Create CSV for the challenge
 		challengeCSV := CreateProjectCSV(currChallengeProjects, scores)
@@ -313,13 +513,3 @@ func CreateProjectChallengeZip(projects []*models.Project, scores []ranking.Rank
 
 	return zipBuffer.Bytes(), nil
 }
-
-// contains checks if a string is in a list of strings
-func contains(list []string, str string) bool {
-	for _, s := range list {
-		if s == str {
-			return true
-		}
-	}
-	return false
-}
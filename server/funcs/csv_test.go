@@ -0,0 +1,121 @@
+package funcs_test
+
+import (
+	"encoding/csv"
+	"server/funcs"
+	"server/models"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateJudgeRankingCSV(t *testing.T) {
+	proj1 := models.NewProject("Arke", "Guild", "1", "", "", "", "", []string{})
+	proj1.Id = primitive.NewObjectID()
+	proj2 := models.NewProject("Nub", "Guild", "2", "", "", "", "", []string{})
+	proj2.Id = primitive.NewObjectID()
+
+	judge := models.NewJudge(primitive.NewObjectID(), "keycloak-user")
+	judge.Id = primitive.NewObjectID()
+	judge.SeenProjects = []models.JudgedProject{
+		*models.JudgeProjectFromProject(proj1, map[string]int{"Design": 4}),
+		*models.JudgeProjectFromProject(proj2, map[string]int{"Design": 2}),
+	}
+	judge.PastRankings = [][]primitive.ObjectID{{proj2.Id, proj1.Id}, {proj1.Id}}
+	judge.PastRankingsAt = []primitive.DateTime{0, 1700000000000}
+
+	batches := funcs.BuildJudgeBatchRankings([]*models.Judge{judge}, map[primitive.ObjectID]string{judge.Id: "Bob Joe"})
+	if len(batches) != 2 {
+		t.Fatalf("Expected 2 batches, got %d", len(batches))
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(funcs.CreateJudgeRankingCSV(batches, []string{"Design"})))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %d records", len(records))
+	}
+	Assert(t, records[1][0], "Bob Joe")
+	Assert(t, records[1][2], "1")
+	Assert(t, records[1][3], "")
+	Assert(t, records[1][4], "Nub; Arke")
+	Assert(t, records[1][5], "Guild|2; Guild|1")
+	Assert(t, records[1][6], "2; 4")
+	Assert(t, records[2][3], "2023-11-14T22:13:20Z")
+}
+
+func Assert(t *testing.T, actual any, expected any) {
+	if actual != expected {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestParseDevpostCSV(t *testing.T) {
+	content := "Project Title,Extra,Submission Url,Project Status,Table Number,Megateam/Guild,Submitter Email\n" +
+		"Arke,x,https://devpost.com/arke,Submitted (Gallery/Visible),12,Grand Dragon,arke@example.com\n" +
+		"Nub,x,https://devpost.com/nub,Draft,13,Grand Dragon,\n" +
+		"Zap,x,https://devpost.com/zap,Submitted (Gallery/Visible),,Grand Dragon,\n"
+
+	projects, warnings, err := funcs.ParseDevpostCSV(content, models.DefaultDevpostColumns())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 2 {
+		t.Fatalf("Expected 2 projects, got %d", len(projects))
+	}
+	Assert(t, projects[0].Project.Name, "Arke")
+	Assert(t, projects[0].Project.Location, "12")
+	Assert(t, projects[0].Project.Guild, "Grand Dragon")
+	Assert(t, len(projects[0].Project.ContactEmails), 1)
+	Assert(t, projects[0].Project.ContactEmails[0], "arke@example.com")
+	Assert(t, len(projects[1].Project.ContactEmails), 0)
+	Assert(t, projects[1].Line, 4)
+
+	// Missing description, try link, video link and challenges columns plus Zap's missing location
+	if len(warnings) != 5 {
+		t.Fatalf("Expected 5 warnings, got %d: %v", len(warnings), warnings)
+	}
+	Assert(t, warnings[4].Line, 4)
+
+	_, _, err = funcs.ParseDevpostCSV("Name,Url\nArke,x\n", models.DefaultDevpostColumns())
+	if err == nil {
+		t.Fatal("Expected an error for a header without the required columns")
+	}
+}
+
+func TestParseProjectCsv(t *testing.T) {
+	content := "Guild,Name,URL,Challenges\n" +
+		"Grand Dragon,Arke,https://devpost.com/arke,\"Best Design, Best Use of AI\"\n" +
+		"Grand Dragon,,https://devpost.com/nameless,\n" +
+		"Grand Dragon,Nub,not a link,\n" +
+		"\n" +
+		"Grand Dragon,Zap\n"
+
+	projects, rowErrors, err := funcs.ParseProjectCsv(content, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 2 {
+		t.Fatalf("Expected 2 projects, got %d", len(projects))
+	}
+	Assert(t, projects[0].Project.Guild, "Grand Dragon")
+	Assert(t, len(projects[0].Project.ChallengeList), 2)
+	Assert(t, projects[1].Project.Name, "Zap")
+	Assert(t, projects[1].Line, 6)
+	if len(rowErrors) != 2 {
+		t.Fatalf("Expected 2 row errors, got %d: %v", len(rowErrors), rowErrors)
+	}
+	Assert(t, rowErrors[0].Line, 3)
+	Assert(t, rowErrors[1].Line, 4)
+
+	// Without a header, columns are read in order
+	projects, _, err = funcs.ParseProjectCsv("Arke,12,Desc,https://devpost.com/arke,https://try.it,https://video.it,AI\n", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, projects[0].Project.TryLink, "https://try.it")
+	Assert(t, projects[0].Project.VideoLink, "https://video.it")
+	Assert(t, projects[0].Project.ChallengeList[0], "AI")
+}
@@ -0,0 +1,81 @@
+package funcs
+
+import (
+	"server/models"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListFeedback lists the notes judges have shared, optionally only those with the given moderation status,
+// ordered by project name. Project names are taken from the projects, falling back to the judges' copies.
+func ListFeedback(judges []*models.Judge, projects []*models.Project, status string) []models.FeedbackEntry {
+	names := make(map[primitive.ObjectID]string, len(projects))
+	for _, project := range projects {
+		names[project.Id] = project.Name
+	}
+
+	entries := make([]models.FeedbackEntry, 0)
+	for _, judge := range judges {
+		for _, jp := range judge.SeenProjects {
+			if jp.FeedbackStatus == "" || (status != "" && jp.FeedbackStatus != status) {
+				continue
+			}
+			name, ok := names[jp.ProjectId]
+			if !ok {
+				name = jp.Name
+			}
+			entries = append(entries, models.FeedbackEntry{
+				JudgeId:     judge.Id,
+				ProjectId:   jp.ProjectId,
+				ProjectName: name,
+				Notes:       jp.Notes,
+				Feedback:    jp.Feedback,
+				Status:      jp.FeedbackStatus,
+			})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ProjectName < entries[j].ProjectName })
+	return entries
+}
+
+// BuildProjectFeedback collects the anonymised feedback of a project: the average score given in each category
+// by the judges who scored it (a score of 0 means unscored) and the approved notes. Notes are sorted so that
+// their order doesn't give away which judge wrote them.
+func BuildProjectFeedback(project *models.Project, judges []*models.Judge, categories []string) *models.ProjectFeedback {
+	feedback := &models.ProjectFeedback{
+		Name:       project.Name,
+		Categories: make([]models.CategoryFeedback, 0, len(categories)),
+		Notes:      make([]string, 0),
+	}
+
+	totals := make(map[string]int)
+	counts := make(map[string]int)
+	for _, judge := range judges {
+		for _, jp := range judge.SeenProjects {
+			if jp.ProjectId != project.Id {
+				continue
+			}
+			feedback.Judges++
+			for _, category := range categories {
+				if score := jp.Categories[category]; score > 0 {
+					totals[category] += score
+					counts[category]++
+				}
+			}
+			if jp.FeedbackStatus == models.FeedbackApproved && jp.Feedback != "" {
+				feedback.Notes = append(feedback.Notes, jp.Feedback)
+			}
+		}
+	}
+
+	for _, category := range categories {
+		average := 0.0
+		if counts[category] > 0 {
+			average = float64(totals[category]) / float64(counts[category])
+		}
+		feedback.Categories = append(feedback.Categories, models.CategoryFeedback{Category: category, Average: average, Count: counts[category]})
+	}
+	sort.Strings(feedback.Notes)
+	return feedback
+}
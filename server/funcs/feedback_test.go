@@ -0,0 +1,46 @@
+package funcs_test
+
+import (
+	"server/funcs"
+	"server/models"
+	"testing"
+)
+
+func TestProjectFeedback(t *testing.T) {
+	arke, nub := newTableProject("Arke", "", "1"), newTableProject("Nub", "", "2")
+
+	first := models.NewJudge(arke.EventId, "first")
+	first.SeenProjects = []models.JudgedProject{
+		{ProjectId: arke.Id, Categories: map[string]int{"Design": 4, "Presentation": 0}},
+		{ProjectId: nub.Id, Categories: map[string]int{"Design": 1}},
+	}
+	first.SeenProjects[0].SetNotes("Lovely demo", true)
+	first.SeenProjects[0].FeedbackStatus = models.FeedbackApproved
+	first.SeenProjects[1].SetNotes("Did not work", true)
+	second := models.NewJudge(arke.EventId, "second")
+	second.SeenProjects = []models.JudgedProject{{ProjectId: arke.Id, Categories: map[string]int{"Design": 2, "Presentation": 5}}}
+	second.SeenProjects[0].SetNotes("Keep this to myself", false)
+	judges := []*models.Judge{first, second}
+
+	// Only shared notes are listed for moderation
+	entries := funcs.ListFeedback(judges, []*models.Project{arke, nub}, "")
+	Assert(t, len(entries), 2)
+	Assert(t, entries[0].ProjectName, "Arke")
+	Assert(t, entries[1].Status, models.FeedbackPending)
+	Assert(t, len(funcs.ListFeedback(judges, nil, models.FeedbackPending)), 1)
+
+	// Teams only see approved notes, and unscored categories aren't averaged
+	feedback := funcs.BuildProjectFeedback(arke, judges, []string{"Design", "Presentation"})
+	Assert(t, feedback.Judges, 2)
+	Assert(t, feedback.Categories[0].Average, 3.0)
+	Assert(t, feedback.Categories[1].Average, 5.0)
+	Assert(t, feedback.Categories[1].Count, 1)
+	Assert(t, len(feedback.Notes), 1)
+	Assert(t, feedback.Notes[0], "Lovely demo")
+
+	// Editing approved notes sends them back for moderation
+	first.SeenProjects[0].SetNotes("Lovely demo, shaky pitch", true)
+	Assert(t, first.SeenProjects[0].FeedbackStatus, models.FeedbackPending)
+	first.SeenProjects[0].SetNotes("Lovely demo, shaky pitch", false)
+	Assert(t, first.SeenProjects[0].FeedbackStatus, "")
+}
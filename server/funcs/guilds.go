@@ -0,0 +1,70 @@
+package funcs
+
+import (
+	"server/models"
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AggregateGuildStats works out the judging progress of each guild's projects, in the order of the guilds.
+// Projects without a guild are counted in a final entry with no guild ID, if there are any.
+func AggregateGuildStats(guilds []*models.Guild, projects []*models.Project, flags []*models.Flag, busy []*primitive.ObjectID) []*models.GuildStats {
+	now := util.Now()
+
+	// Find the flagged and busy projects
+	flagged := make(map[primitive.ObjectID]bool)
+	for _, flag := range flags {
+		if flag.ProjectId != nil && flag.Reason != "busy" {
+			flagged[*flag.ProjectId] = true
+		}
+	}
+	isBusy := make(map[primitive.ObjectID]bool)
+	for _, id := range busy {
+		if id != nil {
+			isBusy[*id] = true
+		}
+	}
+
+	// Create an entry for each guild
+	stats := make([]*models.GuildStats, 0, len(guilds)+1)
+	byGuild := make(map[primitive.ObjectID]*models.GuildStats)
+	for _, guild := range guilds {
+		s := &models.GuildStats{GuildId: &guild.Id, Name: guild.Name}
+		stats = append(stats, s)
+		byGuild[guild.Id] = s
+	}
+	noGuild := &models.GuildStats{}
+
+	// Count the projects
+	totalSeen := make(map[*models.GuildStats]int64)
+	for _, project := range projects {
+		s := noGuild
+		if project.GuildId != nil && byGuild[*project.GuildId] != nil {
+			s = byGuild[*project.GuildId]
+		}
+		s.Projects++
+		totalSeen[s] += project.Seen
+		if project.Active {
+			s.Active++
+		}
+		if flagged[project.Id] {
+			s.Flagged++
+		}
+		if isBusy[project.Id] {
+			s.Busy++
+		}
+		if project.IsPrioritized(now) {
+			s.Prioritized++
+		}
+	}
+	if noGuild.Projects > 0 {
+		stats = append(stats, noGuild)
+	}
+	for _, s := range stats {
+		if s.Projects > 0 {
+			s.AvgSeen = float64(totalSeen[s]) / float64(s.Projects)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,46 @@
+package funcs_test
+
+import (
+	"server/funcs"
+	"server/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAggregateGuildStats(t *testing.T) {
+	dragon := models.NewGuild("Dragon")
+	dragon.Id = primitive.NewObjectID()
+	phoenix := models.NewGuild("Phoenix")
+	phoenix.Id = primitive.NewObjectID()
+
+	arke := newTableProject("Arke", "Dragon", "1")
+	arke.GuildId = &dragon.Id
+	arke.Seen = 3
+	nub := newTableProject("Nub", "Dragon", "2")
+	nub.GuildId = &dragon.Id
+	nub.Seen = 2
+	nub.Active = false
+	loner := newTableProject("Loner", "", "3")
+
+	flags := []*models.Flag{
+		{ProjectId: &arke.Id, Reason: "absent"},
+		{ProjectId: &arke.Id, Reason: "cannot-demo"},
+		{ProjectId: &nub.Id, Reason: "busy"},
+	}
+
+	stats := funcs.AggregateGuildStats([]*models.Guild{dragon, phoenix}, []*models.Project{arke, nub, loner}, flags, []*primitive.ObjectID{&nub.Id})
+	if len(stats) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(stats))
+	}
+	Assert(t, stats[0].Name, "Dragon")
+	Assert(t, stats[0].Projects, int64(2))
+	Assert(t, stats[0].Active, int64(1))
+	Assert(t, stats[0].AvgSeen, 2.5)
+	Assert(t, stats[0].Flagged, int64(1))
+	Assert(t, stats[0].Busy, int64(1))
+	Assert(t, stats[1].Projects, int64(0))
+	if stats[2].GuildId != nil || stats[2].Projects != 1 {
+		t.Errorf("Expected an entry for the project without a guild, got %+v", stats[2])
+	}
+}
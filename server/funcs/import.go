@@ -0,0 +1,186 @@
+package funcs
+
+import (
+	"fmt"
+	"server/models"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of a row in a project import report
+const (
+	ImportCreated   = "created"
+	ImportUpdated   = "updated"
+	ImportUnchanged = "unchanged"
+	ImportErrored   = "errored"
+	ImportHidden    = "hidden"
+)
+
+// Keys that imported projects can be matched to existing projects by
+const (
+	MatchByUrl  = "url"
+	MatchByName = "name"
+)
+
+// ProjectImportRow is the outcome of importing one row of a CSV file.
+// Rows for projects hidden because they were missing from the file have a line of 0.
+type ProjectImportRow struct {
+	Line      int                 `json:"line"`
+	Status    string              `json:"status"`
+	ProjectId *primitive.ObjectID `json:"project_id,omitempty"`
+	Name      string              `json:"name"`
+	Changes   []string            `json:"changes,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	Warning   string              `json:"warning,omitempty"`
+}
+
+// ProjectImportPlan is the set of changes needed to bring the projects of an event in line with a CSV file
+type ProjectImportPlan struct {
+	Rows   []*ProjectImportRow
+	Create []*models.Project
+	Update []*models.Project
+	Hide   []*models.Project
+}
+
+// Counts returns the number of rows with each status
+func (p *ProjectImportPlan) Counts() map[string]int {
+	counts := map[string]int{ImportCreated: 0, ImportUpdated: 0, ImportUnchanged: 0, ImportErrored: 0, ImportHidden: 0}
+	for _, row := range p.Rows {
+		counts[row.Status]++
+	}
+	return counts
+}
+
+// AddTableIssues adds the problems allocating tables to the created projects as warnings on their rows
+func (p *ProjectImportPlan) AddTableIssues(issues []TableIssue) {
+	for _, issue := range issues {
+		for _, row := range p.Rows {
+			if row.Status == ImportCreated && row.ProjectId != nil && *row.ProjectId == issue.ProjectId {
+				row.Warning = "project has no location: " + issue.Message
+			}
+		}
+	}
+}
+
+// importKey returns the key a project is matched by, or an empty string if it has none
+func importKey(p *models.Project, matchBy string) string {
+	if matchBy == MatchByName {
+		return strings.ToLower(strings.TrimSpace(p.Name))
+	}
+	url := strings.ToLower(strings.TrimSpace(p.Url))
+	url = strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	return strings.TrimSuffix(url, "/")
+}
+
+// importChanges copies the imported fields of src to dst, returning the names of the fields that changed
+func importChanges(dst *models.Project, src *models.Project) []string {
+	changes := make([]string, 0)
+	set := func(name string, dst *string, src string) {
+		if *dst != src {
+			*dst = src
+			changes = append(changes, name)
+		}
+	}
+	set("name", &dst.Name, src.Name)
+	set("guild", &dst.Guild, src.Guild)
+	set("location", &dst.Location, src.Location)
+	set("description", &dst.Description, src.Description)
+	set("url", &dst.Url, src.Url)
+	set("try_link", &dst.TryLink, src.TryLink)
+	set("video_link", &dst.VideoLink, src.VideoLink)
+	if !slices.Equal(dst.ChallengeList, src.ChallengeList) && (len(dst.ChallengeList) != 0 || len(src.ChallengeList) != 0) {
+		dst.ChallengeList = src.ChallengeList
+		changes = append(changes, "challenge_list")
+	}
+
+	// Not every import format has contact emails, so keep the existing ones unless new ones are given
+	if len(src.ContactEmails) != 0 && !slices.Equal(dst.ContactEmails, src.ContactEmails) {
+		dst.ContactEmails = src.ContactEmails
+		changes = append(changes, "contact_emails")
+	}
+	return changes
+}
+
+// PlanProjectImport matches the projects parsed from a CSV file against the existing projects of an event.
+// Projects are matched by submission URL or by name (see MatchByUrl and MatchByName); unmatched projects are
+// created and matched projects have their details updated, leaving their judging state untouched.
+// Rows without a key, rows repeating a key earlier in the file and rows matching more than one existing
+// project are reported as errors. If hideMissing is set, active projects that aren't in the file are hidden.
+// Projects to be created are given their IDs here so that they can be reported.
+func PlanProjectImport(existing []*models.Project, incoming []*CsvProject, matchBy string, hideMissing bool) *ProjectImportPlan {
+	plan := &ProjectImportPlan{
+		Rows:   make([]*ProjectImportRow, 0, len(incoming)),
+		Create: make([]*models.Project, 0),
+		Update: make([]*models.Project, 0),
+		Hide:   make([]*models.Project, 0),
+	}
+
+	// Index the existing projects by key
+	byKey := make(map[string][]*models.Project)
+	for _, p := range existing {
+		key := importKey(p, matchBy)
+		if key != "" {
+			byKey[key] = append(byKey[key], p)
+		}
+	}
+
+	seen := make(map[string]int)
+	matched := make(map[*models.Project]bool)
+	for _, csvProject := range incoming {
+		row := &ProjectImportRow{Line: csvProject.Line, Name: csvProject.Project.Name}
+		plan.Rows = append(plan.Rows, row)
+
+		key := importKey(csvProject.Project, matchBy)
+		if key == "" {
+			row.Status = ImportErrored
+			row.Error = fmt.Sprintf("project has no %s to match it by", matchBy)
+			continue
+		}
+		if line, ok := seen[key]; ok {
+			row.Status = ImportErrored
+			row.Error = fmt.Sprintf("duplicate of the project on line %d", line)
+			continue
+		}
+		seen[key] = csvProject.Line
+
+		switch matches := byKey[key]; len(matches) {
+		case 0:
+			row.Status = ImportCreated
+			csvProject.Project.Id = primitive.NewObjectID()
+			row.ProjectId = &csvProject.Project.Id
+			plan.Create = append(plan.Create, csvProject.Project)
+		case 1:
+			project := matches[0]
+			matched[project] = true
+			row.ProjectId = &project.Id
+			row.Changes = importChanges(project, csvProject.Project)
+			if len(row.Changes) == 0 {
+				row.Status = ImportUnchanged
+			} else {
+				row.Status = ImportUpdated
+				plan.Update = append(plan.Update, project)
+			}
+		default:
+			for _, p := range matches {
+				matched[p] = true
+			}
+			row.Status = ImportErrored
+			row.Error = fmt.Sprintf("matches %d existing projects", len(matches))
+		}
+	}
+
+	// Hide the active projects that weren't in the file
+	if hideMissing {
+		for _, p := range existing {
+			if matched[p] || !p.Active {
+				continue
+			}
+			plan.Hide = append(plan.Hide, p)
+			plan.Rows = append(plan.Rows, &ProjectImportRow{Status: ImportHidden, ProjectId: &p.Id, Name: p.Name})
+		}
+	}
+
+	return plan
+}
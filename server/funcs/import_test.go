@@ -0,0 +1,44 @@
+package funcs_test
+
+import (
+	"server/funcs"
+	"server/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPlanProjectImport(t *testing.T) {
+	arke := models.NewProject("Arke", "", "1", "Old", "https://devpost.com/arke", "", "", []string{})
+	arke.Id = primitive.NewObjectID()
+	nub := models.NewProject("Nub", "", "2", "", "https://devpost.com/nub", "", "", []string{})
+	nub.Id = primitive.NewObjectID()
+	gone := models.NewProject("Gone", "", "3", "", "https://devpost.com/gone", "", "", []string{})
+	gone.Id = primitive.NewObjectID()
+
+	incoming := []*funcs.CsvProject{
+		{Line: 2, Project: models.NewProject("Arke", "", "1", "New", "https://devpost.com/arke/", "", "", []string{})},
+		{Line: 3, Project: models.NewProject("Nub", "", "2", "", "https://devpost.com/nub", "", "", nil)},
+		{Line: 4, Project: models.NewProject("Zap", "", "4", "", "https://devpost.com/zap", "", "", []string{})},
+		{Line: 5, Project: models.NewProject("Zap again", "", "5", "", "https://devpost.com/zap", "", "", []string{})},
+	}
+
+	plan := funcs.PlanProjectImport([]*models.Project{arke, nub, gone}, incoming, funcs.MatchByUrl, true)
+	if len(plan.Rows) != 5 {
+		t.Fatalf("Expected 5 rows, got %d", len(plan.Rows))
+	}
+	Assert(t, plan.Rows[0].Status, funcs.ImportUpdated)
+	Assert(t, *plan.Rows[0].ProjectId, arke.Id)
+	Assert(t, arke.Description, "New")
+	Assert(t, plan.Rows[1].Status, funcs.ImportUnchanged)
+	Assert(t, plan.Rows[2].Status, funcs.ImportCreated)
+	Assert(t, plan.Rows[3].Status, funcs.ImportErrored)
+	Assert(t, plan.Rows[4].Status, funcs.ImportHidden)
+	Assert(t, *plan.Rows[4].ProjectId, gone.Id)
+
+	counts := plan.Counts()
+	Assert(t, counts[funcs.ImportCreated], 1)
+	Assert(t, len(plan.Create), 1)
+	Assert(t, len(plan.Update), 1)
+	Assert(t, len(plan.Hide), 1)
+}
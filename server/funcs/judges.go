@@ -0,0 +1,123 @@
+package funcs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JudgeCsvHeaders are the columns of the judge CSV format, in the order they are read when there is no header
+var JudgeCsvHeaders = []string{"Name", "Email", "Notes"}
+
+// JudgeCsvRow is a judge read from a CSV file
+type JudgeCsvRow struct {
+	Line       int    `json:"line"`
+	Name       string `json:"name"`
+	FirstNames string `json:"first_names"`
+	LastNames  string `json:"last_names"`
+	Email      string `json:"email"`
+	Notes      string `json:"notes"`
+}
+
+// splitName splits a full name into first names and the last name
+func splitName(name string) (string, string) {
+	fields := strings.Fields(name)
+	if len(fields) < 2 {
+		return strings.Join(fields, " "), ""
+	}
+	return strings.Join(fields[:len(fields)-1], " "), fields[len(fields)-1]
+}
+
+// ParseJudgeCsv reads a CSV file of judges with name, email and notes columns (see JudgeCsvHeaders). If the file
+// has a header, columns are found by their header, otherwise by their position. Rows without a name or a valid
+// email, and rows repeating an email earlier in the file, are skipped and reported with their line number.
+func ParseJudgeCsv(content string, hasHeader bool) ([]*JudgeCsvRow, []CsvIssue, error) {
+	r := csv.NewReader(strings.NewReader(content))
+	r.FieldsPerRecord = -1
+
+	// Empty CSV file
+	if content == "" {
+		return []*JudgeCsvRow{}, []CsvIssue{}, nil
+	}
+
+	// Find the columns, from the header if there is one
+	nameCol, emailCol, notesCol := 0, 1, 2
+	if hasHeader {
+		header, err := r.Read()
+		if err != nil {
+			return nil, nil, err
+		}
+		findColumn := headerIndexer(header)
+		nameCol, emailCol, notesCol = findColumn("Name"), findColumn("Email"), findColumn("Notes")
+		if nameCol == -1 || emailCol == -1 {
+			return nil, nil, fmt.Errorf("the header must contain the 'Name' and 'Email' columns: '%s'", strings.Join(header, ","))
+		}
+	}
+
+	// Read the CSV file, looping through each record
+	judges := make([]*JudgeCsvRow, 0)
+	issues := make([]CsvIssue, 0)
+	seen := make(map[string]int)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := r.FieldPos(0)
+		field := func(i int) string {
+			if i == -1 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		// Skip blank rows
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		// Validate the row
+		name := strings.Join(strings.Fields(field(nameCol)), " ")
+		if name == "" {
+			issues = append(issues, CsvIssue{line, "judge has no name"})
+			continue
+		}
+		address, err := mail.ParseAddress(field(emailCol))
+		if err != nil {
+			issues = append(issues, CsvIssue{line, fmt.Sprintf("'%s' is not a valid email address", field(emailCol))})
+			continue
+		}
+		email := strings.ToLower(address.Address)
+		if first, ok := seen[email]; ok {
+			issues = append(issues, CsvIssue{line, fmt.Sprintf("duplicate of the judge on line %d", first)})
+			continue
+		}
+		seen[email] = line
+
+		firstNames, lastNames := splitName(name)
+		judges = append(judges, &JudgeCsvRow{line, name, firstNames, lastNames, email, field(notesCol)})
+	}
+
+	return judges, issues, nil
+}
+
+// JudgeImportRow is the outcome of importing one judge from a CSV file. Status is one of ImportCreated,
+// ImportUpdated (the judge's notes changed), ImportUnchanged or ImportErrored.
+type JudgeImportRow struct {
+	Line           int                 `json:"line"`
+	Name           string              `json:"name"`
+	Email          string              `json:"email"`
+	Status         string              `json:"status"`
+	JudgeId        *primitive.ObjectID `json:"judge_id,omitempty"`
+	KeycloakUserId string              `json:"keycloak_user_id,omitempty"`
+	UserCreated    bool                `json:"user_created"` // Whether a keycloak account was created for the judge
+	Error          string              `json:"error,omitempty"`
+	Warning        string              `json:"warning,omitempty"`
+}
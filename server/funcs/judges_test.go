@@ -0,0 +1,45 @@
+package funcs_test
+
+import (
+	"server/funcs"
+	"testing"
+)
+
+func TestParseJudgeCsv(t *testing.T) {
+	content := "Egor Al,hello@gmail.com,Catboy | actually a furry\n" +
+		"Mary Jane Watson, MJ@Example.com ,\n" +
+		"Madonna,madonna@example.com,\n" +
+		",nobody@example.com,No name\n" +
+		"Bob Joe,not-an-email,\n" +
+		"Egor Again,HELLO@gmail.com,\n"
+
+	judges, issues, err := funcs.ParseJudgeCsv(content, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, len(judges), 3)
+	Assert(t, judges[0].FirstNames, "Egor")
+	Assert(t, judges[0].LastNames, "Al")
+	Assert(t, judges[0].Notes, "Catboy | actually a furry")
+	Assert(t, judges[1].FirstNames, "Mary Jane")
+	Assert(t, judges[1].Email, "mj@example.com")
+	Assert(t, judges[2].FirstNames, "Madonna")
+	Assert(t, judges[2].LastNames, "")
+
+	// Missing name, invalid email and duplicate email
+	Assert(t, len(issues), 3)
+	Assert(t, issues[0].Line, 4)
+	Assert(t, issues[2].Message, "duplicate of the judge on line 1")
+
+	judges, _, err = funcs.ParseJudgeCsv("Notes,E-mail,Name\nLikes robots,ada@example.com,Ada Lovelace\n", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, judges[0].Name, "Ada Lovelace")
+	Assert(t, judges[0].Notes, "Likes robots")
+
+	_, _, err = funcs.ParseJudgeCsv("Name,Notes\nAda,\n", true)
+	if err == nil {
+		t.Error("Expected an error for a header without an email column")
+	}
+}
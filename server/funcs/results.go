@@ -0,0 +1,165 @@
+package funcs
+
+import (
+	"fmt"
+	"server/models"
+	"server/ranking"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// scoredProject is a project with the score it is placed by
+type scoredProject struct {
+	project *models.Project
+	score   float64
+}
+
+// placeProjects orders the projects by score (highest first, then by name) and returns the top n as places.
+// Tied projects share a place, and projects tied with the last place are included too.
+func placeProjects(scored []scoredProject, n int) []models.ResultPlace {
+	slices.SortStableFunc(scored, func(a, b scoredProject) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		if a.project.Name < b.project.Name {
+			return -1
+		}
+		if a.project.Name > b.project.Name {
+			return 1
+		}
+		return 0
+	})
+
+	places := make([]models.ResultPlace, 0, n)
+	for i, s := range scored {
+		place := i + 1
+		if i > 0 && s.score == scored[i-1].score {
+			place = places[i-1].Place
+		}
+		if place > n {
+			break
+		}
+		places = append(places, models.ResultPlace{
+			Place:     place,
+			ProjectId: s.project.Id,
+			Name:      s.project.Name,
+			Guild:     s.project.Guild,
+			Location:  s.project.Location,
+			Url:       s.project.Url,
+			Score:     s.score,
+		})
+	}
+	return places
+}
+
+// BuildResults works out the winners of an event from the judging. Only active projects can win.
+//   - Challenge winners are the entrants of each challenge with the highest overall scores.
+//   - Category winners are the projects with the highest average category score given by the judges
+//     who scored them.
+//   - The overall places are the projects with the highest overall scores.
+//
+// Sections are ordered for the reveal: challenges, then categories, then overall last. Sections without
+// places are left out.
+func BuildResults(req *models.GenerateResultsRequest, projects []*models.Project, challenges []*models.Challenge, judges []*models.Judge, scores []ranking.RankedObject) ([]models.ResultSection, error) {
+	if req.TopN < 0 || req.ChallengePlaces < 0 || req.CategoryPlaces < 0 {
+		return nil, fmt.Errorf("the number of places can't be negative")
+	}
+
+	// Index the overall scores of the active projects
+	overall := make(map[primitive.ObjectID]float64, len(scores))
+	for _, s := range scores {
+		overall[s.Id] = s.Score
+	}
+	active := make([]*models.Project, 0, len(projects))
+	for _, project := range projects {
+		if project.Active {
+			active = append(active, project)
+		}
+	}
+
+	sections := make([]models.ResultSection, 0)
+	addSection := func(section models.ResultSection) {
+		if len(section.Places) > 0 {
+			sections = append(sections, section)
+		}
+	}
+
+	// Challenge winners
+	if req.ChallengePlaces > 0 {
+		selected := challenges
+		if req.ChallengeIds != nil {
+			selected = make([]*models.Challenge, 0, len(req.ChallengeIds))
+			for _, id := range req.ChallengeIds {
+				i := slices.IndexFunc(challenges, func(c *models.Challenge) bool { return c.Id.Hex() == id })
+				if i == -1 {
+					return nil, fmt.Errorf("challenge %s does not exist", id)
+				}
+				selected = append(selected, challenges[i])
+			}
+		}
+		for _, challenge := range selected {
+			entrants := make([]scoredProject, 0)
+			for _, project := range active {
+				if slices.Contains(project.ChallengeIds, challenge.Id) {
+					entrants = append(entrants, scoredProject{project, overall[project.Id]})
+				}
+			}
+			challengeId := challenge.Id
+			addSection(models.ResultSection{
+				Kind:        models.ResultsChallenge,
+				Title:       challenge.Name,
+				ChallengeId: &challengeId,
+				Sponsor:     challenge.Sponsor,
+				Places:      placeProjects(entrants, req.ChallengePlaces),
+			})
+		}
+	}
+
+	// Category winners
+	if req.CategoryPlaces > 0 {
+		for _, category := range req.Categories {
+			totals := make(map[primitive.ObjectID]int)
+			counts := make(map[primitive.ObjectID]int)
+			for _, judge := range judges {
+				for _, jp := range judge.SeenProjects {
+					// A score of 0 means the judge didn't score the category
+					if score := jp.Categories[category]; score > 0 {
+						totals[jp.ProjectId] += score
+						counts[jp.ProjectId]++
+					}
+				}
+			}
+			scored := make([]scoredProject, 0)
+			for _, project := range active {
+				if counts[project.Id] > 0 {
+					scored = append(scored, scoredProject{project, float64(totals[project.Id]) / float64(counts[project.Id])})
+				}
+			}
+			addSection(models.ResultSection{
+				Kind:     models.ResultsCategory,
+				Title:    category,
+				Category: category,
+				Places:   placeProjects(scored, req.CategoryPlaces),
+			})
+		}
+	}
+
+	// Overall places
+	if req.TopN > 0 {
+		scored := make([]scoredProject, 0, len(active))
+		for _, project := range active {
+			scored = append(scored, scoredProject{project, overall[project.Id]})
+		}
+		addSection(models.ResultSection{
+			Kind:   models.ResultsOverall,
+			Title:  "Overall",
+			Places: placeProjects(scored, req.TopN),
+		})
+	}
+
+	return sections, nil
+}
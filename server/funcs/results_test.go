@@ -0,0 +1,56 @@
+package funcs_test
+
+import (
+	"server/funcs"
+	"server/models"
+	"server/ranking"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBuildResults(t *testing.T) {
+	arke, nub, pip, hid := newTableProject("Arke", "", "1"), newTableProject("Nub", "", "2"), newTableProject("Pip", "", "3"), newTableProject("Hid", "", "4")
+	hid.Active = false
+	challenge := models.NewChallenge("Best Hardware Hack")
+	challenge.Id = primitive.NewObjectID()
+	nub.ChallengeIds = append(nub.ChallengeIds, challenge.Id)
+	pip.ChallengeIds = append(pip.ChallengeIds, challenge.Id)
+
+	judge := models.NewJudge(arke.EventId, "judge")
+	judge.SeenProjects = []models.JudgedProject{
+		{ProjectId: arke.Id, Categories: map[string]int{"Design": 3}},
+		{ProjectId: nub.Id, Categories: map[string]int{"Design": 5}},
+		{ProjectId: pip.Id, Categories: map[string]int{"Design": 0}},
+	}
+	scores := []ranking.RankedObject{{Id: hid.Id, Score: 9}, {Id: pip.Id, Score: 4}, {Id: arke.Id, Score: 2}, {Id: nub.Id, Score: 2}}
+
+	req := &models.GenerateResultsRequest{TopN: 2, ChallengePlaces: 1, Categories: []string{"Design"}, CategoryPlaces: 3}
+	sections, err := funcs.BuildResults(req, []*models.Project{arke, nub, pip, hid}, []*models.Challenge{challenge}, []*models.Judge{judge}, scores)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sections are revealed challenges first and overall last
+	Assert(t, len(sections), 3)
+	Assert(t, sections[0].Kind, models.ResultsChallenge)
+	Assert(t, sections[0].Places[0].Name, "Pip")
+	Assert(t, len(sections[0].Places), 1)
+
+	// Unscored categories are ignored
+	Assert(t, sections[1].Kind, models.ResultsCategory)
+	Assert(t, len(sections[1].Places), 2)
+	Assert(t, sections[1].Places[0].Name, "Nub")
+
+	// Hidden projects can't win, and projects tied for the last place are all included
+	Assert(t, sections[2].Kind, models.ResultsOverall)
+	Assert(t, len(sections[2].Places), 3)
+	Assert(t, sections[2].Places[0].Name, "Pip")
+	Assert(t, sections[2].Places[1].Place, 2)
+	Assert(t, sections[2].Places[2].Place, 2)
+
+	_, err = funcs.BuildResults(&models.GenerateResultsRequest{TopN: -1}, nil, nil, nil, nil)
+	if err == nil {
+		t.Error("Negative numbers of places should be rejected")
+	}
+}
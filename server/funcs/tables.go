@@ -0,0 +1,227 @@
+package funcs
+
+import (
+	"fmt"
+	"server/models"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TableAssignment is a table number given to a project
+type TableAssignment struct {
+	ProjectId   primitive.ObjectID `json:"project_id"`
+	Name        string             `json:"name"`
+	Guild       string             `json:"guild"`
+	OldLocation string             `json:"old_location"`
+	Location    string             `json:"location"`
+	Zone        string             `json:"zone"`
+}
+
+// TableIssue is a project that couldn't be given a table number
+type TableIssue struct {
+	ProjectId primitive.ObjectID `json:"project_id"`
+	Name      string             `json:"name"`
+	Message   string             `json:"message"`
+}
+
+// TableCollision is a location shared by more than one active project
+type TableCollision struct {
+	Location   string               `json:"location"`
+	ProjectIds []primitive.ObjectID `json:"project_ids"`
+	Names      []string             `json:"names"`
+}
+
+// tableNumber parses the table number of a location, returning false if it isn't a number
+func tableNumber(location string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(location))
+	return n, err == nil
+}
+
+// zonesByGuild groups zones by their guild, ordering each guild's zones by their first table
+func zonesByGuild(zones []*models.TableZone) map[string][]*models.TableZone {
+	byGuild := make(map[string][]*models.TableZone)
+	for _, zone := range zones {
+		byGuild[zone.Guild] = append(byGuild[zone.Guild], zone)
+	}
+	for _, guildZones := range byGuild {
+		slices.SortFunc(guildZones, func(a, b *models.TableZone) int { return a.FirstTable - b.FirstTable })
+	}
+	return byGuild
+}
+
+// noTableIssue describes why a project of a guild couldn't be given a table
+func noTableIssue(project *models.Project, hasZones bool) TableIssue {
+	message := fmt.Sprintf("no zone has been set up for guild '%s'", project.Guild)
+	if hasZones {
+		message = fmt.Sprintf("every zone of guild '%s' is full", project.Guild)
+	}
+	return TableIssue{project.Id, project.Name, message}
+}
+
+// AllocateTables gives each of the targets without a location the lowest free table number in the first zone
+// of its guild with space, setting its location. Tables are taken if any existing project (hidden or not) of
+// the same guild sits at them.
+func AllocateTables(zones []*models.TableZone, existing []*models.Project, targets []*models.Project) ([]TableAssignment, []TableIssue) {
+	byGuild := zonesByGuild(zones)
+
+	// Find the taken tables and how full each zone is
+	taken := make(map[string]map[int]bool)
+	used := make(map[*models.TableZone]int)
+	take := func(guild string, table int) {
+		if taken[guild] == nil {
+			taken[guild] = make(map[int]bool)
+		}
+		taken[guild][table] = true
+		for _, zone := range byGuild[guild] {
+			if zone.Contains(table) {
+				used[zone]++
+			}
+		}
+	}
+	for _, project := range existing {
+		if table, ok := tableNumber(project.Location); ok {
+			take(project.Guild, table)
+		}
+	}
+
+	assignments := make([]TableAssignment, 0)
+	issues := make([]TableIssue, 0)
+	for _, project := range targets {
+		if strings.TrimSpace(project.Location) != "" {
+			continue
+		}
+
+		// Find the first zone with a free table
+		assigned := false
+		for _, zone := range byGuild[project.Guild] {
+			if used[zone] >= zone.Size() {
+				continue
+			}
+			for table := zone.FirstTable; table <= zone.LastTable; table++ {
+				if taken[project.Guild][table] {
+					continue
+				}
+				take(project.Guild, table)
+				project.Location = strconv.Itoa(table)
+				assignments = append(assignments, TableAssignment{project.Id, project.Name, project.Guild, "", project.Location, zone.Name})
+				assigned = true
+				break
+			}
+			if assigned {
+				break
+			}
+		}
+		if !assigned {
+			issues = append(issues, noTableIssue(project, len(byGuild[project.Guild]) > 0))
+		}
+	}
+	return assignments, issues
+}
+
+// FindTableCollisions finds the locations (including guild) shared by more than one active project
+func FindTableCollisions(projects []*models.Project) []TableCollision {
+	byLocation := make(map[string][]*models.Project)
+	for _, project := range projects {
+		if !project.Active || strings.TrimSpace(project.Location) == "" {
+			continue
+		}
+		location := project.GetLocationString()
+		byLocation[location] = append(byLocation[location], project)
+	}
+
+	collisions := make([]TableCollision, 0)
+	for location, sharing := range byLocation {
+		if len(sharing) < 2 {
+			continue
+		}
+		collision := TableCollision{Location: location}
+		for _, project := range sharing {
+			collision.ProjectIds = append(collision.ProjectIds, project.Id)
+			collision.Names = append(collision.Names, project.Name)
+		}
+		collisions = append(collisions, collision)
+	}
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Location < collisions[j].Location })
+	return collisions
+}
+
+// RenumberTables gives the projects of every guild with zones consecutive table numbers, filling the guild's
+// zones in order. Active projects are numbered before hidden ones, and each keeps the relative order of its
+// current table number, then its name. Only assignments that change a project's location are returned; the
+// projects themselves are not modified. If guild is not nil, only that guild is renumbered.
+func RenumberTables(zones []*models.TableZone, projects []*models.Project, guild *string) ([]TableAssignment, []TableIssue) {
+	byGuild := zonesByGuild(zones)
+
+	// Group the projects of the guilds to renumber
+	guildProjects := make(map[string][]*models.Project)
+	for _, project := range projects {
+		if guild != nil && project.Guild != *guild {
+			continue
+		}
+		guildProjects[project.Guild] = append(guildProjects[project.Guild], project)
+	}
+
+	assignments := make([]TableAssignment, 0)
+	issues := make([]TableIssue, 0)
+	guilds := make([]string, 0, len(guildProjects))
+	for g := range guildProjects {
+		guilds = append(guilds, g)
+	}
+	sort.Strings(guilds)
+	for _, g := range guilds {
+		guildZones := byGuild[g]
+		if len(guildZones) == 0 {
+			continue
+		}
+
+		// Order the guild's projects
+		toNumber := slices.Clone(guildProjects[g])
+		slices.SortStableFunc(toNumber, func(a, b *models.Project) int {
+			if a.Active != b.Active {
+				if a.Active {
+					return -1
+				}
+				return 1
+			}
+			at, aOk := tableNumber(a.Location)
+			bt, bOk := tableNumber(b.Location)
+			switch {
+			case aOk && bOk && at != bt:
+				return at - bt
+			case aOk != bOk:
+				if aOk {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		// Number the projects through the zones
+		zoneIdx, table, used := 0, guildZones[0].FirstTable, 0
+		for _, project := range toNumber {
+			for zoneIdx < len(guildZones) && (used >= guildZones[zoneIdx].Size() || table > guildZones[zoneIdx].LastTable) {
+				zoneIdx++
+				if zoneIdx < len(guildZones) {
+					table, used = guildZones[zoneIdx].FirstTable, 0
+				}
+			}
+			if zoneIdx == len(guildZones) {
+				issues = append(issues, noTableIssue(project, true))
+				continue
+			}
+
+			location := strconv.Itoa(table)
+			if location != project.Location {
+				assignments = append(assignments, TableAssignment{project.Id, project.Name, project.Guild, project.Location, location, guildZones[zoneIdx].Name})
+			}
+			table++
+			used++
+		}
+	}
+	return assignments, issues
+}
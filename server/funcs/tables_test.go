@@ -0,0 +1,84 @@
+package funcs_test
+
+import (
+	"server/funcs"
+	"server/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTableProject(name string, guild string, location string) *models.Project {
+	project := models.NewProject(name, guild, location, "", "", "", "", []string{})
+	project.Id = primitive.NewObjectID()
+	return project
+}
+
+func TestAllocateTables(t *testing.T) {
+	zones := []*models.TableZone{
+		models.NewTableZone("Hall B", "Dragon", 10, 11, 0),
+		models.NewTableZone("Hall A", "Dragon", 1, 3, 2),
+	}
+	existing := []*models.Project{newTableProject("Arke", "Dragon", "1"), newTableProject("Nub", "Phoenix", "2")}
+	targets := []*models.Project{
+		newTableProject("Zap", "Dragon", ""),
+		newTableProject("Bop", "Dragon", ""),
+		newTableProject("Kit", "Dragon", "7"),
+		newTableProject("Fin", "Phoenix", ""),
+	}
+
+	assignments, issues := funcs.AllocateTables(zones, existing, targets)
+	if len(assignments) != 2 {
+		t.Fatalf("Expected 2 assignments, got %d: %v", len(assignments), assignments)
+	}
+	// Hall A holds 2 projects, so only one more fits before moving on to Hall B
+	Assert(t, targets[0].Location, "2")
+	Assert(t, targets[1].Location, "10")
+	Assert(t, assignments[1].Zone, "Hall B")
+	Assert(t, targets[2].Location, "7")
+	if len(issues) != 1 || issues[0].ProjectId != targets[3].Id {
+		t.Fatalf("Expected an issue for the project without a zone, got %v", issues)
+	}
+}
+
+func TestFindTableCollisions(t *testing.T) {
+	hidden := newTableProject("Gone", "Dragon", "1")
+	hidden.Active = false
+	projects := []*models.Project{
+		newTableProject("Arke", "Dragon", "1"),
+		newTableProject("Nub", "Dragon", "1"),
+		newTableProject("Zap", "Phoenix", "1"),
+		hidden,
+	}
+
+	collisions := funcs.FindTableCollisions(projects)
+	if len(collisions) != 1 {
+		t.Fatalf("Expected 1 collision, got %d", len(collisions))
+	}
+	Assert(t, collisions[0].Location, "Dragon|1")
+	Assert(t, len(collisions[0].ProjectIds), 2)
+}
+
+func TestRenumberTables(t *testing.T) {
+	zones := []*models.TableZone{models.NewTableZone("Hall A", "Dragon", 1, 2, 0)}
+	hidden := newTableProject("Gone", "Dragon", "1")
+	hidden.Active = false
+	projects := []*models.Project{
+		newTableProject("Arke", "Dragon", "9"),
+		newTableProject("Nub", "Dragon", "4"),
+		hidden,
+		newTableProject("Zap", "Phoenix", "30"),
+	}
+
+	assignments, issues := funcs.RenumberTables(zones, projects, nil)
+	if len(assignments) != 2 {
+		t.Fatalf("Expected 2 assignments, got %d: %v", len(assignments), assignments)
+	}
+	Assert(t, assignments[0].Name, "Nub")
+	Assert(t, assignments[0].Location, "1")
+	Assert(t, assignments[1].Name, "Arke")
+	Assert(t, assignments[1].Location, "2")
+	if len(issues) != 1 || issues[0].Name != "Gone" {
+		t.Fatalf("Expected the hidden project to overflow, got %v", issues)
+	}
+}
@@ -51,16 +51,16 @@ func CreateComparisons(projects []*models.Project, judges []*models.Judge) *Comp
 	return &comps
 }
 
-// LoadComparisons will create the comparisons from the database
-func LoadComparisons(db *mongo.Database) (*Comparisons, error) {
+// LoadComparisons will create the comparisons of an event from the database
+func LoadComparisons(db *mongo.Database, eventId primitive.ObjectID) (*Comparisons, error) {
 	// Get all judges
-	judges, err := database.FindAllJudges(db)
+	judges, err := database.FindAllJudges(db, eventId)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get all project
-	projects, err := database.FindAllProjects(db)
+	projects, err := database.FindAllProjects(db, eventId)
 	if err != nil {
 		return nil, err
 	}
@@ -15,7 +15,7 @@ import (
 
 // SkipCurrentProject skips the current project for a judge.
 // This is in the judging module instead of the database module to avoid dependency cycles.
-func SkipCurrentProject(db *mongo.Database, judge *models.Judge, judgeName string, comps *Comparisons, reason string, getNew bool) error {
+func SkipCurrentProject(db *mongo.Database, judge *models.Judge, comps *Comparisons, reason string, getNew bool) error {
 	// Get skipped project from database
 	skippedProject, err := database.FindProjectById(db, judge.Current)
 	if err != nil {
@@ -27,7 +27,7 @@ func SkipCurrentProject(db *mongo.Database, judge *models.Judge, judgeName strin
 		// If skipping for any reason other than wanting a break, add the project to the skipped list
 		if reason != "break" {
 			// Create a new skip object
-			skip, err := models.NewFlag(skippedProject, judge, judgeName, reason)
+			skip, err := models.NewFlag(skippedProject, judge, reason)
 			if err != nil {
 				return nil, errors.New("error creating flag object: " + err.Error())
 			}
@@ -98,7 +98,7 @@ func PickNextProject(db *mongo.Database, judge *models.Judge, ctx mongo.SessionC
 	}
 
 	// Get min views from db
-	minViews, err := database.GetMinViews(db)
+	minViews, err := database.GetMinViews(db, judge.EventId)
 	if err != nil {
 		return nil, err
 	}
@@ -130,14 +130,15 @@ func PickNextProject(db *mongo.Database, judge *models.Judge, ctx mongo.SessionC
 //  2. Filter out all projects that the judge has already seen
 //  3. Filter out all projects that the judge has flagged (except for busy projects)
 //  4. Filter out projects that are currently being judged (if no projects remain after filter, ignore step)
-//  5. Filter out all projects that have more than the current smallest number of views (if no projects remain after filter, ignore step)
+//  5. Filter out projects that are not prioritized (if no projects remain after filter, ignore step)
+//  6. Filter out all projects that have more than the current smallest number of views (if no projects remain after filter, ignore step)
 func FindPreferredItems(db *mongo.Database, judge *models.Judge, ctx mongo.SessionContext) ([]*models.Project, error) {
 	// todo: Ensure judge does not get the project they _just_ skipped -- ideally we would keep a list of projects that the judge has skipped and clear it when the judge finds a project they don't skip (after judging it)
 	// todo: preference too for projects that are near where the judge currently is (clustering)
 	//  (so they don't need to run around as much) - see upstream for this maybe?
 
 	// Get the list of all active projects
-	projects, err := database.FindActiveProjects(db, ctx)
+	projects, err := database.FindActiveProjects(db, ctx, judge.EventId)
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +182,7 @@ func FindPreferredItems(db *mongo.Database, judge *models.Judge, ctx mongo.Sessi
 
 	// Get all projects currently being judged and remove them from the list
 	// Also convert the list to a map for faster lookup
-	busyProjects, err := database.FindBusyProjects(db, ctx)
+	busyProjects, err := database.FindBusyProjects(db, ctx, judge.EventId)
 	if err != nil {
 		return nil, err
 	}
@@ -202,6 +203,19 @@ func FindPreferredItems(db *mongo.Database, judge *models.Judge, ctx mongo.Sessi
 		projects = freeProjects
 	}
 
+	// Filter out projects that aren't prioritized
+	// If no projects are prioritized, ignore this condition
+	now := util.Now()
+	var prioritizedProjects []*models.Project
+	for _, proj := range projects {
+		if proj.IsPrioritized(now) {
+			prioritizedProjects = append(prioritizedProjects, proj)
+		}
+	}
+	if len(prioritizedProjects) > 0 {
+		projects = prioritizedProjects
+	}
+
 	// Get the current smallest number of views of the remaining projects
 	minSeen := projects[0].Seen
 	for _, proj := range projects {
@@ -0,0 +1,150 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is an email to send. At least one of the plain text and HTML bodies must be set.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	Html    string
+}
+
+// Validate checks that the message has recipients with valid addresses and a body
+func (m *Message) Validate() error {
+	if len(m.To) == 0 {
+		return errors.New("message has no recipients")
+	}
+	if _, err := m.recipients(); err != nil {
+		return err
+	}
+	if m.Text == "" && m.Html == "" {
+		return errors.New("message has no body")
+	}
+	return nil
+}
+
+// recipients parses the recipients of a message
+func (m *Message) recipients() ([]*mail.Address, error) {
+	addrs := make([]*mail.Address, len(m.To))
+	for i, to := range m.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient '%s': %s", to, err.Error())
+		}
+		addrs[i] = addr
+	}
+	return addrs, nil
+}
+
+// Transport sends messages from a configured sender
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewTransportFromEnv creates the transport configured by the environment: SMTP if EMAIL_HOST is set,
+// otherwise SendGrid if SENDGRID_API_KEY is set (see config.CheckEnv)
+func NewTransportFromEnv() (Transport, error) {
+	from := os.Getenv("EMAIL_FROM")
+	if from == "" {
+		return nil, errors.New("EMAIL_FROM is not set")
+	}
+	fromName := os.Getenv("EMAIL_FROM_NAME")
+
+	if host := os.Getenv("EMAIL_HOST"); host != "" {
+		port := os.Getenv("EMAIL_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SmtpTransport{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("EMAIL_USERNAME"),
+			Password: os.Getenv("EMAIL_PASSWORD"),
+			From:     from,
+			FromName: fromName,
+		}, nil
+	}
+	if apiKey := os.Getenv("SENDGRID_API_KEY"); apiKey != "" {
+		return &SendGridTransport{ApiKey: apiKey, From: from, FromName: fromName}, nil
+	}
+	return nil, errors.New("neither EMAIL_HOST nor SENDGRID_API_KEY is set")
+}
+
+// encodeMessage encodes a message as a MIME email with a plain text and/or HTML body
+func encodeMessage(from string, fromName string, msg *Message, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(key string, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+
+	// Addresses are formatted by net/mail and the subject is encoded, so none of them can inject headers
+	addrs, err := msg.recipients()
+	if err != nil {
+		return nil, err
+	}
+	to := make([]string, len(addrs))
+	for i, addr := range addrs {
+		to[i] = addr.String()
+	}
+	header("From", (&mail.Address{Name: fromName, Address: from}).String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	// Write a single part if there is only one body
+	if msg.Text == "" || msg.Html == "" {
+		contentType, body := "text/plain; charset=utf-8", msg.Text
+		if msg.Html != "" {
+			contentType, body = "text/html; charset=utf-8", msg.Html
+		}
+		header("Content-Type", contentType)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		return buf.Bytes(), writeQuotedPrintable(&buf, body)
+	}
+
+	// Otherwise let the client choose between the bodies
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{{"text/plain; charset=utf-8", msg.Text}, {"text/html; charset=utf-8", msg.Html}} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err = writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const sendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGridTransport sends messages through the SendGrid v3 mail API
+type SendGridTransport struct {
+	ApiKey   string
+	From     string
+	FromName string
+	Endpoint string       // Defaults to the SendGrid API
+	Client   *http.Client // Defaults to http.DefaultClient
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridRequest struct {
+	Personalizations []struct {
+		To []sendGridAddress `json:"to"`
+	} `json:"personalizations"`
+	From    sendGridAddress   `json:"from"`
+	Subject string            `json:"subject"`
+	Content []sendGridContent `json:"content"`
+}
+
+func (t *SendGridTransport) Send(ctx context.Context, msg *Message) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+
+	// Build the request, with the plain text body first as the API requires
+	req := sendGridRequest{From: sendGridAddress{t.From, t.FromName}, Subject: msg.Subject}
+	req.Personalizations = make([]struct {
+		To []sendGridAddress `json:"to"`
+	}, 1)
+	addrs, _ := msg.recipients()
+	for _, addr := range addrs {
+		req.Personalizations[0].To = append(req.Personalizations[0].To, sendGridAddress{addr.Address, addr.Name})
+	}
+	if msg.Text != "" {
+		req.Content = append(req.Content, sendGridContent{"text/plain", msg.Text})
+	}
+	if msg.Html != "" {
+		req.Content = append(req.Content, sendGridContent{"text/html", msg.Html})
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = sendGridEndpoint
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+t.ApiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		errBody, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("sendgrid responded with %s: %s", res.Status, string(errBody))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package mail_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/mail"
+)
+
+func Assert(t *testing.T, actual any, expected any) {
+	if actual != expected {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestSendGridTransport(t *testing.T) {
+	var received map[string]any
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	transport := &mail.SendGridTransport{ApiKey: "key", From: "jury@durhack.com", FromName: "DurHack Jury", Endpoint: server.URL}
+	msg, err := mail.AbsentNotice([]string{"team@example.com"}, mail.AbsentData{EventName: "DurHack", ProjectName: "Jury", Location: "12"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = transport.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Assert(t, authorization, "Bearer key")
+	Assert(t, received["subject"], "[DurHack] A judge couldn't find Jury")
+	content := received["content"].([]any)
+	Assert(t, len(content), 2)
+	Assert(t, content[0].(map[string]any)["type"], "text/plain")
+	to := received["personalizations"].([]any)[0].(map[string]any)["to"].([]any)
+	Assert(t, to[0].(map[string]any)["email"], "team@example.com")
+}
+
+func TestSendGridTransportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":[{"message":"bad key"}]}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	transport := &mail.SendGridTransport{ApiKey: "key", From: "jury@durhack.com", Endpoint: server.URL}
+	err := transport.Send(context.Background(), &mail.Message{To: []string{"team@example.com"}, Text: "Hi"})
+	if err == nil {
+		t.Error("Errors from SendGrid should be returned")
+	}
+}
@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SmtpTransport sends messages through an SMTP server, upgrading the connection with STARTTLS when the server
+// supports it. Username may be left empty for servers that don't need authentication.
+type SmtpTransport struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	FromName string
+}
+
+func (t *SmtpTransport) Send(ctx context.Context, msg *Message) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	body, err := encodeMessage(t.From, t.FromName, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// The envelope only takes the bare addresses
+	addrs, _ := msg.recipients()
+	to := make([]string, len(addrs))
+	for i, addr := range addrs {
+		to[i] = addr.Address
+	}
+
+	var auth smtp.Auth
+	if t.Username != "" {
+		auth = smtp.PlainAuth("", t.Username, t.Password, t.Host)
+	}
+
+	// smtp.SendMail can't be cancelled, so give up waiting on it when the context is done
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(t.Host, t.Port), auth, t.From, to, body)
+	}()
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
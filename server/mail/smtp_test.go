@@ -0,0 +1,161 @@
+package mail_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"strings"
+	"testing"
+
+	"server/mail"
+)
+
+// smtpSink is a minimal SMTP server that accepts every message it is sent
+type smtpSink struct {
+	listener net.Listener
+	messages chan sunkMessage
+}
+
+type sunkMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSmtpSink(t *testing.T) *smtpSink {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &smtpSink{listener, make(chan sunkMessage, 10)}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+	return sink
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 sink ready")
+	var msg sunkMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 sink")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			msg = sunkMessage{from: strings.Trim(strings.TrimSpace(line)[10:], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			msg.to = append(msg.to, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			msg.data = data.String()
+			s.messages <- msg
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSmtpTransport(t *testing.T) {
+	sink := newSmtpSink(t)
+	host, port, _ := net.SplitHostPort(sink.listener.Addr().String())
+	transport := &mail.SmtpTransport{Host: host, Port: port, From: "jury@durhack.com", FromName: "DurHack Jury"}
+
+	msg, err := mail.FeedbackLink([]string{"team@example.com", "Ada <ada@example.com>"}, mail.FeedbackLinkData{
+		EventName:   "DurHack",
+		ProjectName: "Jury & Co",
+		Link:        "https://jury.durhack.com/feedback/abc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = transport.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sunk := <-sink.messages
+	Assert(t, sunk.from, "jury@durhack.com")
+	Assert(t, strings.Join(sunk.to, ","), "team@example.com,ada@example.com")
+
+	// The message should have a plain text and an HTML part
+	parsed, err := netmail.ReadMessage(strings.NewReader(sunk.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, parsed.Header.Get("Subject"), "[DurHack] Judges' feedback on Jury & Co")
+	Assert(t, parsed.Header.Get("From"), `"DurHack Jury" <jury@durhack.com>`)
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(t, mediaType, "multipart/alternative")
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part)
+		bodies = append(bodies, part.Header.Get("Content-Type")+"\n"+string(body))
+	}
+	Assert(t, len(bodies), 2)
+	if !strings.Contains(bodies[0], "text/plain") || !strings.Contains(bodies[0], "https://jury.durhack.com/feedback/abc") {
+		t.Errorf("Plain text part should contain the link, got %q", bodies[0])
+	}
+	if !strings.Contains(bodies[1], "text/html") || !strings.Contains(bodies[1], "Jury &amp; Co") {
+		t.Errorf("HTML part should contain the escaped project name, got %q", bodies[1])
+	}
+}
+
+func TestSmtpTransportRejectsInvalidMessages(t *testing.T) {
+	transport := &mail.SmtpTransport{Host: "127.0.0.1", Port: "1", From: "jury@durhack.com"}
+	err := transport.Send(context.Background(), &mail.Message{To: []string{"not an address"}, Text: "Hi"})
+	if err == nil {
+		t.Error("Messages to invalid addresses should be rejected")
+	}
+	err = transport.Send(context.Background(), &mail.Message{To: []string{"team@example.com"}})
+	if err == nil {
+		t.Error("Messages without a body should be rejected")
+	}
+}
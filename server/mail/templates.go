@@ -0,0 +1,135 @@
+package mail
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// template is a message with a subject, plain text body and HTML body, each filled in from the same data
+type template struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+func newTemplate(name string, subject string, text string, html string) *template {
+	return &template{
+		subject: texttemplate.Must(texttemplate.New(name + ".subject").Parse(subject)),
+		text:    texttemplate.Must(texttemplate.New(name + ".text").Parse(text)),
+		html:    htmltemplate.Must(htmltemplate.New(name + ".html").Parse(html)),
+	}
+}
+
+// render fills in the template to create a message to the recipients
+func (t *template) render(to []string, data any) (*Message, error) {
+	var subject, text, html bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+	if err := t.text.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	if err := t.html.Execute(&html, data); err != nil {
+		return nil, err
+	}
+	return &Message{
+		To:      to,
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    text.String(),
+		Html:    html.String(),
+	}, nil
+}
+
+// AbsentData fills in the message sent to a team when a judge flags them absent
+type AbsentData struct {
+	EventName   string
+	ProjectName string
+	Location    string
+}
+
+var absentTemplate = newTemplate("absent",
+	`[{{.EventName}}] A judge couldn't find {{.ProjectName}}`,
+	`Hi {{.ProjectName}} team,
+
+A judge came to {{if .Location}}table {{.Location}}{{else}}your table{{end}} but couldn't find anyone to demo {{.ProjectName}}.
+Please make sure someone is at your table so that judges can see your project, and let an organiser know if
+you've had to leave.
+
+{{.EventName}} organisers
+`,
+	`<p>Hi {{.ProjectName}} team,</p>
+<p>A judge came to {{if .Location}}table {{.Location}}{{else}}your table{{end}} but couldn't find anyone to demo <strong>{{.ProjectName}}</strong>.
+Please make sure someone is at your table so that judges can see your project, and let an organiser know if you've had to leave.</p>
+<p>{{.EventName}} organisers</p>
+`)
+
+// AbsentNotice creates the message sent to a team when a judge flags them absent
+func AbsentNotice(to []string, data AbsentData) (*Message, error) {
+	return absentTemplate.render(to, data)
+}
+
+// JudgeWelcomeData fills in the welcome message sent to a judge
+type JudgeWelcomeData struct {
+	EventName  string
+	Name       string
+	JudgingUrl string
+}
+
+var judgeWelcomeTemplate = newTemplate("judge-welcome",
+	`Welcome to judging at {{.EventName}}`,
+	`Hi {{.Name}},
+
+Thank you for judging at {{.EventName}}! When judging starts, log in at {{.JudgingUrl}} and you will be sent to
+your first project. Score each project in every category, add any notes you'd like to keep, and rank the
+projects you've seen when asked. If a team isn't at their table, flag them absent and move on.
+
+{{.EventName}} organisers
+`,
+	`<p>Hi {{.Name}},</p>
+<p>Thank you for judging at {{.EventName}}! When judging starts, log in at <a href="{{.JudgingUrl}}">{{.JudgingUrl}}</a>
+and you will be sent to your first project.</p>
+<ul>
+<li>Score each project in every category, and add any notes you'd like to keep.</li>
+<li>Rank the projects you've seen when asked.</li>
+<li>If a team isn't at their table, flag them absent and move on.</li>
+</ul>
+<p>{{.EventName}} organisers</p>
+`)
+
+// JudgeWelcome creates the welcome message with judging instructions sent to a judge
+func JudgeWelcome(to []string, data JudgeWelcomeData) (*Message, error) {
+	return judgeWelcomeTemplate.render(to, data)
+}
+
+// FeedbackLinkData fills in the message sending a team the link to their feedback
+type FeedbackLinkData struct {
+	EventName   string
+	ProjectName string
+	Link        string
+}
+
+var feedbackLinkTemplate = newTemplate("feedback-link",
+	`[{{.EventName}}] Judges' feedback on {{.ProjectName}}`,
+	`Hi {{.ProjectName}} team,
+
+Thank you for taking part in {{.EventName}}! The judges' feedback on {{.ProjectName}} is available at:
+
+{{.Link}}
+
+Anyone with the link can see the feedback, so only share it with your team.
+
+{{.EventName}} organisers
+`,
+	`<p>Hi {{.ProjectName}} team,</p>
+<p>Thank you for taking part in {{.EventName}}! The judges' feedback on <strong>{{.ProjectName}}</strong> is available
+<a href="{{.Link}}">here</a>.</p>
+<p>Anyone with the link can see the feedback, so only share it with your team.</p>
+<p>{{.EventName}} organisers</p>
+`)
+
+// FeedbackLink creates the message sending a team the link to their feedback
+func FeedbackLink(to []string, data FeedbackLinkData) (*Message, error) {
+	return feedbackLinkTemplate.render(to, data)
+}
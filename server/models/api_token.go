@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/json"
+	"server/util"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Scopes of API tokens, each allowing a token to use a group of admin endpoints
+const (
+	ScopeProjectsRead  = "projects:read"
+	ScopeProjectsWrite = "projects:write"
+	ScopeJudgesRead    = "judges:read"
+	ScopeJudgesWrite   = "judges:write"
+	ScopeExportRead    = "export:read"
+	ScopeImportWrite   = "import:write"
+)
+
+var ApiTokenScopes = []string{ScopeProjectsRead, ScopeProjectsWrite, ScopeJudgesRead, ScopeJudgesWrite, ScopeExportRead, ScopeImportWrite}
+
+// ApiToken lets scripts call admin endpoints with an Authorization: Bearer header instead of a browser session.
+// Only a hash of the token is stored, so the token itself is only shown when it is created.
+type ApiToken struct {
+	Id            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name          string             `bson:"name" json:"name"`
+	Prefix        string             `bson:"prefix" json:"prefix"` // Start of the token, so admins can tell which token is which
+	Hash          string             `bson:"hash" json:"-"`
+	Scopes        []string           `bson:"scopes" json:"scopes"`
+	CreatedBy     string             `bson:"created_by" json:"created_by"`
+	CreatedByName string             `bson:"created_by_name" json:"created_by_name"`
+	CreatedAt     primitive.DateTime `bson:"created_at" json:"created_at"`
+	ExpiresAt     primitive.DateTime `bson:"expires_at" json:"expires_at"` // The token is deleted by a TTL index after this
+	LastUsedAt    primitive.DateTime `bson:"last_used_at" json:"last_used_at"`
+}
+
+func NewApiToken(name string, scopes []string, createdBy string, createdByName string, lifetime time.Duration) *ApiToken {
+	return &ApiToken{
+		Name:          name,
+		Scopes:        scopes,
+		CreatedBy:     createdBy,
+		CreatedByName: createdByName,
+		CreatedAt:     util.Now(),
+		ExpiresAt:     primitive.NewDateTimeFromTime(time.Now().Add(lifetime)),
+	}
+}
+
+// IsValidScope checks whether a scope is one of ApiTokenScopes
+func IsValidScope(scope string) bool {
+	return slices.Contains(ApiTokenScopes, scope)
+}
+
+// HasScopes checks whether the token has all the given scopes
+func (t *ApiToken) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(t.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsExpired checks whether the token has expired, as the TTL index doesn't delete expired tokens straight away
+func (t *ApiToken) IsExpired(now primitive.DateTime) bool {
+	return now >= t.ExpiresAt
+}
+
+// Create custom marshal function to change the format of the primitive.DateTime to a unix timestamp
+func (t *ApiToken) MarshalJSON() ([]byte, error) {
+	type Alias ApiToken
+	return json.Marshal(&struct {
+		*Alias
+		CreatedAt  int64 `json:"created_at"`
+		ExpiresAt  int64 `json:"expires_at"`
+		LastUsedAt int64 `json:"last_used_at"`
+	}{
+		Alias:      (*Alias)(t),
+		CreatedAt:  int64(t.CreatedAt),
+		ExpiresAt:  int64(t.ExpiresAt),
+		LastUsedAt: int64(t.LastUsedAt),
+	})
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Challenge is a prize category projects can enter, e.g. a sponsor's challenge.
+// Projects reference their challenges by ID and keep a copy of their names (see Project.ChallengeList).
+type Challenge struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventId     primitive.ObjectID `bson:"event_id" json:"event_id"`
+	Name        string             `bson:"name" json:"name"`
+	Aliases     []string           `bson:"aliases" json:"aliases"` // Other names the challenge is entered under, e.g. in Devpost exports
+	Sponsor     string             `bson:"sponsor" json:"sponsor"`
+	Description string             `bson:"description" json:"description"`
+	Prizes      int                `bson:"prizes" json:"prizes"` // Number of prizes awarded for the challenge
+}
+
+func NewChallenge(name string) *Challenge {
+	return &Challenge{Name: name, Aliases: []string{}, Prizes: 1}
+}
+
+// NormaliseChallengeName lower-cases a challenge name and collapses its whitespace, so that names differing
+// only in case or spacing compare equal
+func NormaliseChallengeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Matches checks whether a name is the name or one of the aliases of the challenge
+func (c *Challenge) Matches(name string) bool {
+	name = NormaliseChallengeName(name)
+	if NormaliseChallengeName(c.Name) == name {
+		return true
+	}
+	for _, alias := range c.Aliases {
+		if NormaliseChallengeName(alias) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the challenge has a name, a valid number of prizes and no name or alias that another
+// challenge already has
+func (c *Challenge) Validate(others []*Challenge) error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("challenge name is required")
+	}
+	if c.Prizes < 0 {
+		return fmt.Errorf("number of prizes cannot be negative")
+	}
+	for _, name := range append([]string{c.Name}, c.Aliases...) {
+		if other := FindChallengeByName(others, name); other != nil && other.Id != c.Id {
+			return fmt.Errorf("'%s' is already used by the challenge '%s'", name, other.Name)
+		}
+	}
+	return nil
+}
+
+// FindChallengeByName finds the challenge with a name or alias, or returns nil
+func FindChallengeByName(challenges []*Challenge, name string) *Challenge {
+	for _, c := range challenges {
+		if c.Matches(name) {
+			return c
+		}
+	}
+	return nil
+}
+
+type ChallengeRequest struct {
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases"`
+	Sponsor     string   `json:"sponsor"`
+	Description string   `json:"description"`
+	Prizes      int      `json:"prizes"`
+}
+
+type MergeChallengesRequest struct {
+	Ids []string `json:"ids"` // Challenges to merge into the challenge in the URL
+}
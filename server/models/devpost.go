@@ -0,0 +1,52 @@
+package models
+
+// DevpostColumns maps each project field to the header of the Devpost CSV export column it is read from.
+// Headers are matched ignoring case, spacing and punctuation.
+type DevpostColumns struct {
+	Name        string `bson:"name" json:"name"`
+	Url         string `bson:"url" json:"url"`
+	Status      string `bson:"status" json:"status"`
+	Description string `bson:"description" json:"description"`
+	TryLink     string `bson:"try_link" json:"try_link"`
+	VideoLink   string `bson:"video_link" json:"video_link"`
+	Challenges  string `bson:"challenges" json:"challenges"`
+	Guild       string `bson:"guild" json:"guild"`
+	Location    string `bson:"location" json:"location"`
+	Email       string `bson:"email" json:"email"`
+}
+
+func DefaultDevpostColumns() DevpostColumns {
+	return DevpostColumns{
+		Name:        "Project Title",
+		Url:         "Submission Url",
+		Status:      "Project Status",
+		Description: "About The Project",
+		TryLink:     "\"Try it out\" Links",
+		VideoLink:   "Video Demo Link",
+		Challenges:  "Opt-In Prizes",
+		Guild:       "Megateam/Guild",
+		Location:    "Table Number",
+		Email:       "Submitter Email",
+	}
+}
+
+// WithDefaults returns the mapping with any unset headers replaced by the default headers
+func (c DevpostColumns) WithDefaults() DevpostColumns {
+	defaults := DefaultDevpostColumns()
+	fill := func(header *string, defaultHeader string) {
+		if *header == "" {
+			*header = defaultHeader
+		}
+	}
+	fill(&c.Name, defaults.Name)
+	fill(&c.Url, defaults.Url)
+	fill(&c.Status, defaults.Status)
+	fill(&c.Description, defaults.Description)
+	fill(&c.TryLink, defaults.TryLink)
+	fill(&c.VideoLink, defaults.VideoLink)
+	fill(&c.Challenges, defaults.Challenges)
+	fill(&c.Guild, defaults.Guild)
+	fill(&c.Location, defaults.Location)
+	fill(&c.Email, defaults.Email)
+	return c
+}
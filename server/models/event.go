@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"server/util"
+)
+
+// Event is a single judging event (e.g. DurHack or a mini-hack). Projects, judges, flags
+// and options all belong to exactly one event through their EventId field.
+type Event struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Default   bool               `bson:"default" json:"default"`
+	Archived  bool               `bson:"archived" json:"archived"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+}
+
+func NewEvent(name string) *Event {
+	return &Event{
+		Name:      name,
+		Default:   false,
+		Archived:  false,
+		CreatedAt: util.Now(),
+	}
+}
+
+// Create custom marshal function to change the format of the primitive.DateTime to a unix timestamp
+func (e *Event) MarshalJSON() ([]byte, error) {
+	type Alias Event
+	return json.Marshal(&struct {
+		*Alias
+		CreatedAt int64 `json:"created_at"`
+	}{
+		Alias:     (*Alias)(e),
+		CreatedAt: int64(e.CreatedAt),
+	})
+}
+
+// Create custom unmarshal function to change the format of the primitive.DateTime from a unix timestamp
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type Alias Event
+	aux := &struct {
+		CreatedAt int64 `json:"created_at"`
+		*Alias
+	}{
+		Alias: (*Alias)(e),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	e.CreatedAt = primitive.DateTime(aux.CreatedAt)
+	return nil
+}
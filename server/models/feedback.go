@@ -0,0 +1,49 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Moderation statuses of a judge's shared notes
+const (
+	FeedbackPending  = "pending"
+	FeedbackApproved = "approved"
+	FeedbackRejected = "rejected"
+)
+
+// FeedbackEntry is a judge's shared notes on a project, as reviewed by admins
+type FeedbackEntry struct {
+	JudgeId     primitive.ObjectID `json:"judge_id"`
+	ProjectId   primitive.ObjectID `json:"project_id"`
+	ProjectName string             `json:"project_name"`
+	Notes       string             `json:"notes"`
+	Feedback    string             `json:"feedback"`
+	Status      string             `json:"status"`
+}
+
+type ModerateFeedbackRequest struct {
+	Status   string  `json:"status"`   // FeedbackApproved, FeedbackRejected or FeedbackPending
+	Feedback *string `json:"feedback"` // The text to share, e.g. with personal remarks removed, or unchanged if left out
+}
+
+// FeedbackLink is a project's feedback token, for admins to send to the team
+type FeedbackLink struct {
+	ProjectId primitive.ObjectID `json:"project_id"`
+	Name      string             `json:"name"`
+	Guild     string             `json:"guild"`
+	Location  string             `json:"location"`
+	Token     string             `json:"token"`
+}
+
+// CategoryFeedback is the average score a project was given in a category
+type CategoryFeedback struct {
+	Category string  `json:"category"`
+	Average  float64 `json:"average"`
+	Count    int     `json:"count"` // Number of judges who scored the category
+}
+
+// ProjectFeedback is the anonymised feedback a team sees through its feedback link
+type ProjectFeedback struct {
+	Name       string             `json:"name"`
+	Judges     int                `json:"judges"` // Number of judges who saw the project
+	Categories []CategoryFeedback `json:"categories"`
+	Notes      []string           `json:"notes"`
+}
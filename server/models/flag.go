@@ -24,16 +24,21 @@ var validReasons = []string{"busy", "absent", "cannot-demo", "too-complex", "off
 // flagging, which is defined by the `flag` field.
 type Flag struct {
 	Id              primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	EventId         primitive.ObjectID  `json:"event_id" bson:"event_id"`
 	ProjectId       *primitive.ObjectID `json:"project_id" bson:"project_id"`
 	JudgeId         *primitive.ObjectID `json:"judge_id" bson:"judge_id"`
 	Time            primitive.DateTime  `json:"time" bson:"time"`
 	ProjectName     string              `json:"project_name" bson:"project_name"`
 	ProjectLocation string              `json:"project_location" bson:"project_location"`
-	JudgeName       string              `json:"judge_name" bson:"judge_name"`
+	JudgeName       string              `json:"judge_name" bson:"-"` // Filled in from the judge's profile when flags are listed
 	Reason          string              `json:"reason" bson:"reason"`
+	Resolved        bool                `json:"resolved" bson:"resolved"`
+	ResolvedAt      primitive.DateTime  `json:"resolved_at" bson:"resolved_at"`
+	ResolvedBy      string              `json:"resolved_by" bson:"resolved_by"` // User ID of the volunteer or admin who resolved the flag
+	ResolvedByName  string              `json:"resolved_by_name" bson:"resolved_by_name"`
 }
 
-func NewFlag(project *Project, judge *Judge, judgeName string, reason string) (*Flag, error) {
+func NewFlag(project *Project, judge *Judge, reason string) (*Flag, error) {
 	// Check if the reason is valid
 	valid := false
 	for _, r := range validReasons {
@@ -48,12 +53,12 @@ func NewFlag(project *Project, judge *Judge, judgeName string, reason string) (*
 
 	// Create the skip object
 	return &Flag{
+		EventId:         project.EventId,
 		ProjectId:       &project.Id,
 		JudgeId:         &judge.Id,
 		Time:            primitive.NewDateTimeFromTime(time.Now()),
 		ProjectName:     project.Name,
 		ProjectLocation: project.GetLocationString(),
-		JudgeName:       judgeName,
 		Reason:          reason,
 	}, nil
 }
@@ -63,10 +68,12 @@ func (s *Flag) MarshalJSON() ([]byte, error) {
 	type Alias Flag
 	return json.Marshal(&struct {
 		*Alias
-		Time int64 `json:"time"`
+		Time       int64 `json:"time"`
+		ResolvedAt int64 `json:"resolved_at"`
 	}{
-		Alias: (*Alias)(s),
-		Time:  int64(s.Time),
+		Alias:      (*Alias)(s),
+		Time:       int64(s.Time),
+		ResolvedAt: int64(s.ResolvedAt),
 	})
 }
 
@@ -74,7 +81,8 @@ func (s *Flag) MarshalJSON() ([]byte, error) {
 func (s *Flag) UnmarshalJSON(data []byte) error {
 	type Alias Flag
 	aux := &struct {
-		Time int64 `json:"time"`
+		Time       int64 `json:"time"`
+		ResolvedAt int64 `json:"resolved_at"`
 		*Alias
 	}{
 		Alias: (*Alias)(s),
@@ -83,5 +91,6 @@ func (s *Flag) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	s.Time = primitive.DateTime(aux.Time)
+	s.ResolvedAt = primitive.DateTime(aux.ResolvedAt)
 	return nil
 }
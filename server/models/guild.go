@@ -0,0 +1,69 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var colourRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Guild is a group of projects judged in the same physical area, e.g. a megateam at DurHack.
+// Projects reference their guild by ID and keep a copy of its name (see Project.Guild).
+type Guild struct {
+	Id      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventId primitive.ObjectID `bson:"event_id" json:"event_id"`
+	Name    string             `bson:"name" json:"name"`
+	Area    string             `bson:"area" json:"area"`     // Where the guild's projects are, e.g. a room
+	Colour  string             `bson:"colour" json:"colour"` // Hex colour used to display the guild, e.g. #ff0000
+	Order   int                `bson:"order" json:"order"`   // Position of the guild when displayed
+}
+
+func NewGuild(name string) *Guild {
+	return &Guild{Name: name}
+}
+
+// Validate checks that the guild has a name no other guild has and a valid colour
+func (g *Guild) Validate(others []*Guild) error {
+	if strings.TrimSpace(g.Name) == "" {
+		return fmt.Errorf("guild name is required")
+	}
+	if g.Colour != "" && !colourRegex.MatchString(g.Colour) {
+		return fmt.Errorf("colour must be a hex colour like #ff0000")
+	}
+	if other := FindGuildByName(others, g.Name); other != nil && other.Id != g.Id {
+		return fmt.Errorf("there is already a guild called '%s'", other.Name)
+	}
+	return nil
+}
+
+// FindGuildByName finds a guild by its name, ignoring case and surrounding spaces, or returns nil
+func FindGuildByName(guilds []*Guild, name string) *Guild {
+	name = strings.TrimSpace(name)
+	for _, g := range guilds {
+		if strings.EqualFold(strings.TrimSpace(g.Name), name) {
+			return g
+		}
+	}
+	return nil
+}
+
+type GuildRequest struct {
+	Name   string `json:"name"`
+	Area   string `json:"area"`
+	Colour string `json:"colour"`
+	Order  int    `json:"order"`
+}
+
+type GuildStats struct {
+	GuildId     *primitive.ObjectID `json:"guild_id"` // nil for the projects without a guild
+	Name        string              `json:"name"`
+	Projects    int64               `json:"projects"`
+	Active      int64               `json:"active"`
+	AvgSeen     float64             `json:"avg_seen"`
+	Flagged     int64               `json:"flagged"` // Projects flagged for any reason other than being busy
+	Busy        int64               `json:"busy"`    // Projects currently being judged
+	Prioritized int64               `json:"prioritized"`
+}
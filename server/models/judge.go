@@ -2,11 +2,14 @@ package models
 
 import (
 	"encoding/json"
+	"strings"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Judge struct {
 	Id              primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	EventId         primitive.ObjectID     `bson:"event_id" json:"event_id"`
 	KeycloakUserId  string                 `bson:"keycloak_user_id" json:"keycloak_user_id"`
 	Active          bool                   `bson:"active" json:"active"`
 	ReadWelcome     bool                   `bson:"read_welcome" json:"read_welcome"`
@@ -16,25 +19,110 @@ type Judge struct {
 	SeenProjects    []JudgedProject        `bson:"seen_projects" json:"seen_projects"`
 	CurrentRankings []primitive.ObjectID   `bson:"current_rankings" json:"current_rankings"`
 	PastRankings    [][]primitive.ObjectID `bson:"past_rankings" json:"past_rankings"`
+	PastRankingsAt  []primitive.DateTime   `bson:"past_rankings_at" json:"past_rankings_at"` // Submission time of each batch in PastRankings
 	LastActivity    primitive.DateTime     `bson:"last_activity" json:"last_activity"`
+	FirstLoginAt    primitive.DateTime     `bson:"first_login_at" json:"first_login_at"` // When the judge first logged in, or 0 if they were added by an admin and haven't yet
+	Profile         JudgeProfile           `bson:"profile" json:"profile"`
+}
+
+// JudgeProfile is a copy of the judge's details from keycloak, so that listing judges doesn't depend on keycloak.
+// It is refreshed whenever the judge logs in and by a periodic sync.
+type JudgeProfile struct {
+	PreferredNames *string            `bson:"preferred_names" json:"preferred_names"`
+	FirstNames     string             `bson:"first_names" json:"first_names"`
+	LastNames      string             `bson:"last_names" json:"last_names"`
+	Email          string             `bson:"email" json:"email"`
+	SyncedAt       primitive.DateTime `bson:"synced_at" json:"synced_at"` // When the profile was last refreshed, or 0 if it never has been
+}
+
+// Names returns the judge's preferred names, or their first names if they have none
+func (p *JudgeProfile) Names() string {
+	if p.PreferredNames != nil && *p.PreferredNames != "" {
+		return *p.PreferredNames
+	}
+	return p.FirstNames
+}
+
+// FullName returns the judge's names followed by their last names, falling back to their email address
+// if the profile has no names
+func (p *JudgeProfile) FullName() string {
+	name := strings.TrimSpace(p.Names() + " " + p.LastNames)
+	if name != "" {
+		return name
+	}
+	if p.Email != "" {
+		return p.Email
+	}
+	return "Unknown judge"
+}
+
+// SameDetails checks whether two profiles have the same details, ignoring when they were synced
+func (p *JudgeProfile) SameDetails(other *JudgeProfile) bool {
+	samePreferred := (p.PreferredNames == nil) == (other.PreferredNames == nil) &&
+		(p.PreferredNames == nil || *p.PreferredNames == *other.PreferredNames)
+	return samePreferred && p.FirstNames == other.FirstNames && p.LastNames == other.LastNames && p.Email == other.Email
+}
+
+// Create custom marshal function to change the format of the primitive.DateTime to a unix timestamp
+func (p *JudgeProfile) MarshalJSON() ([]byte, error) {
+	type Alias JudgeProfile
+	return json.Marshal(&struct {
+		*Alias
+		SyncedAt int64 `json:"synced_at"`
+	}{
+		Alias:    (*Alias)(p),
+		SyncedAt: int64(p.SyncedAt),
+	})
+}
+
+// Create custom unmarshal function to change the format of the primitive.DateTime from a unix timestamp
+func (p *JudgeProfile) UnmarshalJSON(data []byte) error {
+	type Alias JudgeProfile
+	aux := &struct {
+		SyncedAt int64 `json:"synced_at"`
+		*Alias
+	}{
+		Alias: (*Alias)(p),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	p.SyncedAt = primitive.DateTime(aux.SyncedAt)
+	return nil
 }
 
 type JudgedProject struct {
-	ProjectId   primitive.ObjectID `bson:"project_id" json:"project_id"`
-	Categories  map[string]int     `bson:"categories" json:"categories"`
-	Notes       string             `bson:"notes" json:"notes"`
-	Name        string             `bson:"name" json:"name"`
-	Guild       string             `bson:"guild" json:"guild"`
-	Location    string             `bson:"location" json:"location"`
-	Description string             `bson:"description" json:"description"`
+	ProjectId      primitive.ObjectID `bson:"project_id" json:"project_id"`
+	Categories     map[string]int     `bson:"categories" json:"categories"`
+	Notes          string             `bson:"notes" json:"notes"`
+	Name           string             `bson:"name" json:"name"`
+	Guild          string             `bson:"guild" json:"guild"`
+	Location       string             `bson:"location" json:"location"`
+	Description    string             `bson:"description" json:"description"`
+	ShareNotes     bool               `bson:"share_notes" json:"share_notes"`         // Whether the judge agreed to share their notes with the team
+	Feedback       string             `bson:"feedback" json:"feedback"`               // The notes as they will be shared, after moderation
+	FeedbackStatus string             `bson:"feedback_status" json:"feedback_status"` // Moderation status of the shared notes, see FeedbackPending
+}
+
+// SetNotes changes the judge's notes and whether they are shared, sending shared notes back for moderation
+func (jp *JudgedProject) SetNotes(notes string, share bool) {
+	if notes == jp.Notes && share == jp.ShareNotes {
+		return
+	}
+	jp.Notes, jp.ShareNotes = notes, share
+	jp.Feedback, jp.FeedbackStatus = "", ""
+	if share && strings.TrimSpace(notes) != "" {
+		jp.Feedback, jp.FeedbackStatus = notes, FeedbackPending
+	}
 }
 
 func (jp *JudgedProject) GetLocationString() string {
 	return jp.Guild + "|" + jp.Location
 }
 
-func NewJudge(keycloakUserId string) *Judge {
+func NewJudge(eventId primitive.ObjectID, keycloakUserId string) *Judge {
 	return &Judge{
+		EventId:         eventId,
 		KeycloakUserId:  keycloakUserId,
 		Active:          true,
 		ReadWelcome:     false,
@@ -44,8 +132,34 @@ func NewJudge(keycloakUserId string) *Judge {
 		SeenProjects:    []JudgedProject{},
 		CurrentRankings: []primitive.ObjectID{},
 		PastRankings:    [][]primitive.ObjectID{},
+		PastRankingsAt:  []primitive.DateTime{},
 		LastActivity:    primitive.DateTime(0),
+		FirstLoginAt:    primitive.DateTime(0),
+	}
+}
+
+// FillRankingArrays replaces missing ranking arrays with empty ones. MongoDB stores nil slices as null, which
+// can't be pushed onto, and judges from before PastRankingsAt existed don't have it.
+func (j *Judge) FillRankingArrays() {
+	if j.CurrentRankings == nil {
+		j.CurrentRankings = []primitive.ObjectID{}
+	}
+	if j.PastRankings == nil {
+		j.PastRankings = [][]primitive.ObjectID{}
+	}
+	if j.PastRankingsAt == nil {
+		j.PastRankingsAt = []primitive.DateTime{}
+	}
+}
+
+// BatchSubmissionTimes returns the times to add to PastRankingsAt when a batch is submitted at now. Batches
+// submitted before submission times were recorded are given a zero time, keeping the arrays aligned.
+func (j *Judge) BatchSubmissionTimes(now primitive.DateTime) []primitive.DateTime {
+	times := make([]primitive.DateTime, 0, 1)
+	for i := len(j.PastRankingsAt); i < len(j.PastRankings); i++ {
+		times = append(times, primitive.DateTime(0))
 	}
+	return append(times, now)
 }
 
 func JudgeProjectFromProject(project *Project, categories map[string]int) *JudgedProject {
@@ -66,9 +180,11 @@ func (j *Judge) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&struct {
 		*Alias
 		LastActivity int64 `json:"last_activity"`
+		FirstLoginAt int64 `json:"first_login_at"`
 	}{
 		Alias:        (*Alias)(j),
 		LastActivity: int64(j.LastActivity),
+		FirstLoginAt: int64(j.FirstLoginAt),
 	})
 }
 
@@ -77,6 +193,7 @@ func (j *Judge) UnmarshalJSON(data []byte) error {
 	type Alias Judge
 	aux := &struct {
 		LastActivity int64 `json:"last_activity"`
+		FirstLoginAt int64 `json:"first_login_at"`
 		*Alias
 	}{
 		Alias: (*Alias)(j),
@@ -85,5 +202,6 @@ func (j *Judge) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	j.LastActivity = primitive.DateTime(aux.LastActivity)
+	j.FirstLoginAt = primitive.DateTime(aux.FirstLoginAt)
 	return nil
 }
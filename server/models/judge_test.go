@@ -0,0 +1,84 @@
+package models_test
+
+import (
+	"server/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestJudgeProfileFullName(t *testing.T) {
+	preferred := "Sam"
+	profile := models.JudgeProfile{FirstNames: "Samuel", LastNames: "Jones", Email: "sam@example.com"}
+	if profile.FullName() != "Samuel Jones" {
+		t.Errorf("Expected the first and last names, got %s", profile.FullName())
+	}
+
+	profile.PreferredNames = &preferred
+	if profile.FullName() != "Sam Jones" {
+		t.Errorf("Expected the preferred and last names, got %s", profile.FullName())
+	}
+
+	// Profiles without names, e.g. of judges who haven't been synced, fall back to the email
+	profile = models.JudgeProfile{Email: "sam@example.com"}
+	if profile.FullName() != "sam@example.com" {
+		t.Errorf("Expected the email, got %s", profile.FullName())
+	}
+}
+
+func TestJudgeProfileSameDetails(t *testing.T) {
+	a, b := "Sam", "Sam"
+	profile := models.JudgeProfile{PreferredNames: &a, FirstNames: "Samuel", SyncedAt: 1}
+	other := models.JudgeProfile{PreferredNames: &b, FirstNames: "Samuel", SyncedAt: 2}
+	if !profile.SameDetails(&other) {
+		t.Error("Profiles synced at different times should have the same details")
+	}
+
+	other.PreferredNames = nil
+	if profile.SameDetails(&other) {
+		t.Error("Profiles with different preferred names should not have the same details")
+	}
+}
+
+func TestJudgeBatchSubmissionTimesWithNullTimes(t *testing.T) {
+	// A judge from before submission times were recorded, with past_rankings_at stored as null
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+	doc, err := bson.Marshal(bson.M{
+		"past_rankings":    bson.A{bson.A{first}, bson.A{second}},
+		"past_rankings_at": nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var judge models.Judge
+	err = bson.Unmarshal(doc, &judge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Submitting a batch pads the earlier batches with zero times
+	now := primitive.DateTime(1000)
+	times := judge.BatchSubmissionTimes(now)
+	if len(times) != 3 || times[0] != 0 || times[1] != 0 || times[2] != now {
+		t.Errorf("Expected two zero times and then the submission time, got %v", times)
+	}
+
+	// And the arrays are stored as empty rather than null once filled in
+	judge = models.Judge{}
+	judge.FillRankingArrays()
+	doc, err = bson.Marshal(&judge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored bson.M
+	err = bson.Unmarshal(doc, &stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"current_rankings", "past_rankings", "past_rankings_at"} {
+		if _, ok := stored[field].(bson.A); !ok {
+			t.Errorf("Expected %s to be stored as an empty array, got %v", field, stored[field])
+		}
+	}
+}
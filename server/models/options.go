@@ -4,6 +4,7 @@ import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type Options struct {
 	Id               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventId          primitive.ObjectID `bson:"event_id" json:"event_id"`
 	Ref              int64              `bson:"ref" json:"ref"`
 	Clock            ClockState         `bson:"clock" json:"clock"`
 	JudgingTimer     int64              `bson:"judging_timer" json:"judging_timer"`
@@ -11,10 +12,13 @@ type Options struct {
 	Categories       []string           `bson:"categories" json:"categories"`
 	BatchRankingSize int64              `bson:"batch_ranking_size" json:"batch_ranking_size"`
 	JudgingEnded     bool               `bson:"judging_ended" json:"judging_ended"`
+	DevpostColumns   DevpostColumns     `bson:"devpost_columns" json:"devpost_columns"`
+	FeedbackReleased bool               `bson:"feedback_released" json:"feedback_released"` // Whether teams can see their feedback
 }
 
-func NewOptions() *Options {
+func NewOptions(eventId primitive.ObjectID) *Options {
 	return &Options{
+		EventId:          eventId,
 		Ref:              0,
 		JudgingTimer:     300,
 		MinViews:         3,
@@ -22,5 +26,7 @@ func NewOptions() *Options {
 		Categories:       []string{"Creativity/Innovation", "Technical Competence/Execution", "Research/Design", "Presentation"},
 		BatchRankingSize: 8,
 		JudgingEnded:     false,
+		DevpostColumns:   DefaultDevpostColumns(),
+		FeedbackReleased: false,
 	}
 }
@@ -7,18 +7,27 @@ import (
 )
 
 type Project struct {
-	Id            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name          string             `bson:"name" json:"name"`
-	Guild         string             `bson:"guild" json:"guild"`
-	Location      string             `bson:"location" json:"location"`
-	Description   string             `bson:"description" json:"description"`
-	Url           string             `bson:"url" json:"url"`
-	TryLink       string             `bson:"try_link" json:"try_link"`
-	VideoLink     string             `bson:"video_link" json:"video_link"`
-	ChallengeList []string           `bson:"challenge_list" json:"challenge_list"`
-	Seen          int64              `bson:"seen" json:"seen"`
-	Active        bool               `bson:"active" json:"active"`
-	LastActivity  primitive.DateTime `bson:"last_activity" json:"last_activity"`
+	Id            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	EventId       primitive.ObjectID   `bson:"event_id" json:"event_id"`
+	Name          string               `bson:"name" json:"name"`
+	Guild         string               `bson:"guild" json:"guild"` // Name of the guild, kept in sync with the guild itself
+	GuildId       *primitive.ObjectID  `bson:"guild_id" json:"guild_id"`
+	Location      string               `bson:"location" json:"location"`
+	Description   string               `bson:"description" json:"description"`
+	Url           string               `bson:"url" json:"url"`
+	TryLink       string               `bson:"try_link" json:"try_link"`
+	VideoLink     string               `bson:"video_link" json:"video_link"`
+	ChallengeList []string             `bson:"challenge_list" json:"challenge_list"` // Names of the challenges, kept in sync with the challenges themselves
+	ChallengeIds  []primitive.ObjectID `bson:"challenge_ids" json:"challenge_ids"`
+	Seen          int64                `bson:"seen" json:"seen"`
+	Active        bool                 `bson:"active" json:"active"`
+	Absent        bool                 `bson:"absent" json:"absent"` // Marked absent by a volunteer, which also hides the project until it is marked present
+	LastActivity  primitive.DateTime   `bson:"last_activity" json:"last_activity"`
+	Prioritized   bool                 `bson:"prioritized" json:"prioritized"`       // Prioritized projects are picked before others until they are next picked
+	PrioritizedAt primitive.DateTime   `bson:"prioritized_at" json:"prioritized_at"` // When the project was prioritized, to order the priority queue
+	PriorityUntil primitive.DateTime   `bson:"priority_until" json:"priority_until"` // When the priority expires, or 0 if it doesn't
+	FeedbackToken string               `bson:"feedback_token,omitempty" json:"-"`    // Secret token of the team's feedback link, never sent with the project
+	ContactEmails []string             `bson:"contact_emails" json:"-"`              // Email addresses of the team, kept private as judges can see projects
 }
 
 func (p *Project) GetLocationString() string {
@@ -29,6 +38,11 @@ func (p *Project) GetLocationString() string {
 	}
 }
 
+// IsPrioritized checks whether the project is prioritized and its priority hasn't expired
+func (p *Project) IsPrioritized(now primitive.DateTime) bool {
+	return p.Prioritized && (p.PriorityUntil == 0 || now < p.PriorityUntil)
+}
+
 func NewProject(name string, guild string, location string, description string, url string, tryLink string, videoLink string, challengeList []string) *Project {
 	return &Project{
 		Name:          name,
@@ -39,6 +53,7 @@ func NewProject(name string, guild string, location string, description string,
 		TryLink:       tryLink,
 		VideoLink:     videoLink,
 		ChallengeList: challengeList,
+		ChallengeIds:  []primitive.ObjectID{},
 		Seen:          0,
 		Active:        true,
 		LastActivity:  primitive.DateTime(0),
@@ -50,10 +65,14 @@ func (p *Project) MarshalJSON() ([]byte, error) {
 	type Alias Project
 	return json.Marshal(&struct {
 		*Alias
-		LastActivity int64 `json:"last_activity"`
+		LastActivity  int64 `json:"last_activity"`
+		PrioritizedAt int64 `json:"prioritized_at"`
+		PriorityUntil int64 `json:"priority_until"`
 	}{
-		Alias:        (*Alias)(p),
-		LastActivity: int64(p.LastActivity),
+		Alias:         (*Alias)(p),
+		LastActivity:  int64(p.LastActivity),
+		PrioritizedAt: int64(p.PrioritizedAt),
+		PriorityUntil: int64(p.PriorityUntil),
 	})
 }
 
@@ -61,7 +80,9 @@ func (p *Project) MarshalJSON() ([]byte, error) {
 func (p *Project) UnmarshalJSON(data []byte) error {
 	type Alias Project
 	aux := &struct {
-		LastActivity int64 `json:"last_activity"`
+		LastActivity  int64 `json:"last_activity"`
+		PrioritizedAt int64 `json:"prioritized_at"`
+		PriorityUntil int64 `json:"priority_until"`
 		*Alias
 	}{
 		Alias: (*Alias)(p),
@@ -70,6 +91,8 @@ func (p *Project) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	p.LastActivity = primitive.DateTime(aux.LastActivity)
+	p.PrioritizedAt = primitive.DateTime(aux.PrioritizedAt)
+	p.PriorityUntil = primitive.DateTime(aux.PriorityUntil)
 	return nil
 }
 
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"encoding/json"
+	"server/util"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProjectFieldChange is the change of a single field of a project
+type ProjectFieldChange struct {
+	Field string      `bson:"field" json:"field"`
+	Old   interface{} `bson:"old" json:"old"`
+	New   interface{} `bson:"new" json:"new"`
+}
+
+// ProjectChange is an entry in the change history of a project, made when an admin or volunteer edits it
+type ProjectChange struct {
+	Id            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	EventId       primitive.ObjectID   `bson:"event_id" json:"event_id"`
+	ProjectId     primitive.ObjectID   `bson:"project_id" json:"project_id"`
+	ChangedBy     string               `bson:"changed_by" json:"changed_by"`
+	ChangedByName string               `bson:"changed_by_name" json:"changed_by_name"` // Name of the user at the time, as volunteers aren't judges with a cached profile
+	ChangedAt     primitive.DateTime   `bson:"changed_at" json:"changed_at"`
+	Changes       []ProjectFieldChange `bson:"changes" json:"changes"`
+	Propagated    bool                 `bson:"propagated" json:"propagated"` // Whether judges' copies of the project were updated too
+}
+
+func NewProjectChange(project *Project, changedBy string, changedByName string, changes []ProjectFieldChange, propagated bool) *ProjectChange {
+	return &ProjectChange{
+		EventId:       project.EventId,
+		ProjectId:     project.Id,
+		ChangedBy:     changedBy,
+		ChangedByName: changedByName,
+		ChangedAt:     util.Now(),
+		Changes:       changes,
+		Propagated:    propagated,
+	}
+}
+
+// Create custom marshal function to change the format of the primitive.DateTime to a unix timestamp
+func (c *ProjectChange) MarshalJSON() ([]byte, error) {
+	type Alias ProjectChange
+	return json.Marshal(&struct {
+		*Alias
+		ChangedAt int64 `json:"changed_at"`
+	}{
+		Alias:     (*Alias)(c),
+		ChangedAt: int64(c.ChangedAt),
+	})
+}
+
+// Create custom unmarshal function to change the format of the primitive.DateTime from a unix timestamp
+func (c *ProjectChange) UnmarshalJSON(data []byte) error {
+	type Alias ProjectChange
+	aux := &struct {
+		ChangedAt int64 `json:"changed_at"`
+		*Alias
+	}{
+		Alias: (*Alias)(c),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	c.ChangedAt = primitive.DateTime(aux.ChangedAt)
+	return nil
+}
+
+// ProjectPatchRequest is a partial update of a project; fields left out of the request are not changed
+type ProjectPatchRequest struct {
+	Name          *string   `json:"name"`
+	Guild         *string   `json:"guild"`
+	Location      *string   `json:"location"`
+	Description   *string   `json:"description"`
+	Url           *string   `json:"url"`
+	TryLink       *string   `json:"try_link"`
+	VideoLink     *string   `json:"video_link"`
+	ChallengeList *[]string `json:"challenge_list"`
+	Active        *bool     `json:"active"`
+	Propagate     bool      `json:"propagate"` // Also update the copies of the project in judges' seen projects
+}
+
+// Apply applies the patch to a project, returning the changes that were made
+func (r *ProjectPatchRequest) Apply(p *Project) []ProjectFieldChange {
+	changes := make([]ProjectFieldChange, 0)
+	setString := func(field string, dst *string, src *string) {
+		if src != nil && *dst != *src {
+			changes = append(changes, ProjectFieldChange{field, *dst, *src})
+			*dst = *src
+		}
+	}
+	setString("name", &p.Name, r.Name)
+	setString("guild", &p.Guild, r.Guild)
+	setString("location", &p.Location, r.Location)
+	setString("description", &p.Description, r.Description)
+	setString("url", &p.Url, r.Url)
+	setString("try_link", &p.TryLink, r.TryLink)
+	setString("video_link", &p.VideoLink, r.VideoLink)
+	if r.ChallengeList != nil && !slices.Equal(p.ChallengeList, *r.ChallengeList) {
+		changes = append(changes, ProjectFieldChange{"challenge_list", p.ChallengeList, *r.ChallengeList})
+		p.ChallengeList = *r.ChallengeList
+	}
+	if r.Active != nil && p.Active != *r.Active {
+		changes = append(changes, ProjectFieldChange{"active", p.Active, *r.Active})
+		p.Active = *r.Active
+	}
+	// Showing an absent project again means the team is back
+	if p.Active && p.Absent {
+		changes = append(changes, ProjectFieldChange{"absent", true, false})
+		p.Absent = false
+	}
+	return changes
+}
+
+// JudgedFieldsChanged checks whether any of the changes are to fields that judges keep a copy of (see JudgedProject)
+func JudgedFieldsChanged(changes []ProjectFieldChange) bool {
+	for _, c := range changes {
+		switch c.Field {
+		case "name", "guild", "location", "description":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package models_test
+
+import (
+	"server/models"
+	"testing"
+)
+
+func TestProjectPatchApply(t *testing.T) {
+	project := models.NewProject("Arke", "Guild", "12", "A fancy boat", "https://devpost.com/arke", "", "", []string{"Best Hack"})
+
+	name, location, url := "Arke II", "12", "https://devpost.com/arke-ii"
+	active := false
+	req := &models.ProjectPatchRequest{Name: &name, Location: &location, Url: &url, Active: &active}
+	changes := req.Apply(project)
+
+	// The location is unchanged so isn't recorded
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %d: %v", len(changes), changes)
+	}
+	if changes[0].Field != "name" || changes[0].Old != "Arke" || changes[0].New != "Arke II" {
+		t.Errorf("Unexpected name change: %v", changes[0])
+	}
+	if project.Name != "Arke II" || project.Url != url || project.Active {
+		t.Errorf("Patch was not applied to the project: %+v", project)
+	}
+	if !models.JudgedFieldsChanged(changes) {
+		t.Error("Expected a change to a judged field")
+	}
+	if models.JudgedFieldsChanged(changes[1:]) {
+		t.Error("Expected no change to a judged field")
+	}
+}
+
+func TestProjectPatchShowsAbsentProject(t *testing.T) {
+	project := models.NewProject("Arke", "Guild", "12", "A fancy boat", "", "", "", nil)
+	project.Active, project.Absent = false, true
+
+	// Showing an absent project means the team is back
+	active := true
+	changes := (&models.ProjectPatchRequest{Active: &active}).Apply(project)
+	if len(changes) != 2 || changes[1].Field != "absent" || project.Absent {
+		t.Errorf("Expected the project to no longer be absent, got %v and %+v", changes, project)
+	}
+}
@@ -0,0 +1,29 @@
+package models_test
+
+import (
+	"server/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestProjectIsPrioritized(t *testing.T) {
+	project := models.NewProject("Arke", "", "12", "", "", "", "", []string{})
+	now := primitive.DateTime(1700000000000)
+	if project.IsPrioritized(now) {
+		t.Error("New projects should not be prioritized")
+	}
+
+	project.Prioritized = true
+	if !project.IsPrioritized(now) {
+		t.Error("Priority without an expiry should not expire")
+	}
+
+	project.PriorityUntil = now + 1000
+	if !project.IsPrioritized(now) {
+		t.Error("Priority should last until it expires")
+	}
+	if project.IsPrioritized(now + 1000) {
+		t.Error("Priority should have expired")
+	}
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"encoding/json"
+	"server/util"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of results section
+const (
+	ResultsOverall   = "overall"
+	ResultsChallenge = "challenge"
+	ResultsCategory  = "category"
+)
+
+// ResultPlace is a project placed in a results section
+type ResultPlace struct {
+	Place     int                `bson:"place" json:"place"`
+	ProjectId primitive.ObjectID `bson:"project_id" json:"project_id"`
+	Name      string             `bson:"name" json:"name"`
+	Guild     string             `bson:"guild" json:"guild"`
+	Location  string             `bson:"location" json:"location"`
+	Url       string             `bson:"url" json:"url"`
+	Score     float64            `bson:"score" json:"-"` // Only shown to admins, see Results.AdminView
+}
+
+// ResultSection is one list of winners, e.g. the overall top 3 or the winners of a challenge.
+// Places are ordered from first to last.
+type ResultSection struct {
+	Kind        string              `bson:"kind" json:"kind"`
+	Title       string              `bson:"title" json:"title"`
+	ChallengeId *primitive.ObjectID `bson:"challenge_id,omitempty" json:"challenge_id,omitempty"`
+	Category    string              `bson:"category,omitempty" json:"category,omitempty"`
+	Sponsor     string              `bson:"sponsor,omitempty" json:"sponsor,omitempty"`
+	Places      []ResultPlace       `bson:"places" json:"places"`
+}
+
+// Results are the winners of an event, frozen when they are generated so that later changes to the judging
+// data don't change what has been announced. They are revealed one place at a time, going through the
+// sections in order and each section from last place to first.
+type Results struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventId     primitive.ObjectID `bson:"event_id" json:"event_id"`
+	Sections    []ResultSection    `bson:"sections" json:"sections"`
+	Published   bool               `bson:"published" json:"published"`
+	Revealed    int                `bson:"revealed" json:"revealed"` // Number of places revealed so far
+	GeneratedAt primitive.DateTime `bson:"generated_at" json:"generated_at"`
+}
+
+func NewResults(eventId primitive.ObjectID, sections []ResultSection) *Results {
+	return &Results{
+		EventId:     eventId,
+		Sections:    sections,
+		Published:   false,
+		Revealed:    0,
+		GeneratedAt: util.Now(),
+	}
+}
+
+// TotalPlaces returns the number of places in all sections, i.e. the length of the reveal sequence
+func (r *Results) TotalPlaces() int {
+	total := 0
+	for _, section := range r.Sections {
+		total += len(section.Places)
+	}
+	return total
+}
+
+// SetRevealed sets the number of revealed places, keeping it within the reveal sequence
+func (r *Results) SetRevealed(revealed int) {
+	r.Revealed = max(0, min(revealed, r.TotalPlaces()))
+}
+
+// PublicView returns the results with only the revealed places, keeping the sections in which nothing
+// has been revealed yet so that the audience can see what is still to come
+func (r *Results) PublicView() *PublicResults {
+	public := &PublicResults{
+		Sections: make([]ResultSection, len(r.Sections)),
+		Revealed: r.Revealed,
+		Total:    r.TotalPlaces(),
+	}
+	position := 0
+	for i, section := range r.Sections {
+		public.Sections[i] = section
+		public.Sections[i].Places = make([]ResultPlace, 0, len(section.Places))
+
+		// Places are revealed from last to first, so the last place of the section comes first in the sequence
+		for j := range section.Places {
+			if position+len(section.Places)-1-j < r.Revealed {
+				public.Sections[i].Places = append(public.Sections[i].Places, section.Places[j])
+			}
+		}
+		position += len(section.Places)
+	}
+	return public
+}
+
+// NextReveal returns the section and place revealed next, or nil if everything has been revealed
+func (r *Results) NextReveal() (*ResultSection, *ResultPlace) {
+	position := 0
+	for i, section := range r.Sections {
+		if r.Revealed < position+len(section.Places) {
+			j := len(section.Places) - 1 - (r.Revealed - position)
+			return &r.Sections[i], &r.Sections[i].Places[j]
+		}
+		position += len(section.Places)
+	}
+	return nil, nil
+}
+
+type PublicResults struct {
+	Sections []ResultSection `json:"sections"`
+	Revealed int             `json:"revealed"`
+	Total    int             `json:"total"`
+}
+
+// adminPlace and adminSection are places and sections as sent to admins, including the scores
+type adminPlace struct {
+	ResultPlace
+	Score float64 `json:"score"`
+}
+
+type adminSection struct {
+	ResultSection
+	Places []adminPlace `json:"places"`
+}
+
+// AdminView returns the results as JSON including the scores of each place
+func (r *Results) AdminView() ([]byte, error) {
+	sections := make([]adminSection, len(r.Sections))
+	for i, section := range r.Sections {
+		sections[i] = adminSection{ResultSection: section, Places: make([]adminPlace, len(section.Places))}
+		for j, place := range section.Places {
+			sections[i].Places[j] = adminPlace{place, place.Score}
+		}
+	}
+
+	type Alias Results
+	return json.Marshal(&struct {
+		*Alias
+		Sections    []adminSection `json:"sections"`
+		Total       int            `json:"total"`
+		GeneratedAt int64          `json:"generated_at"`
+	}{
+		Alias:       (*Alias)(r),
+		Sections:    sections,
+		Total:       r.TotalPlaces(),
+		GeneratedAt: int64(r.GeneratedAt),
+	})
+}
+
+// Create custom marshal function which includes the scores, as results are only ever sent whole to admins,
+// e.g. in event bundles
+func (r *Results) MarshalJSON() ([]byte, error) {
+	return r.AdminView()
+}
+
+// Create custom unmarshal function to read results written by AdminView
+func (r *Results) UnmarshalJSON(data []byte) error {
+	type Alias Results
+	aux := &struct {
+		Sections    []adminSection `json:"sections"`
+		GeneratedAt int64          `json:"generated_at"`
+		*Alias
+	}{
+		Alias: (*Alias)(r),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Sections = make([]ResultSection, len(aux.Sections))
+	for i, section := range aux.Sections {
+		r.Sections[i] = section.ResultSection
+		r.Sections[i].Places = make([]ResultPlace, len(section.Places))
+		for j, place := range section.Places {
+			r.Sections[i].Places[j] = place.ResultPlace
+			r.Sections[i].Places[j].Score = place.Score
+		}
+	}
+	r.GeneratedAt = primitive.DateTime(aux.GeneratedAt)
+	return nil
+}
+
+type GenerateResultsRequest struct {
+	TopN            int      `json:"top_n"`            // Number of places in the overall results, or 0 for none
+	ChallengeIds    []string `json:"challenge_ids"`    // Challenges to announce winners of, or every challenge if left out
+	ChallengePlaces int      `json:"challenge_places"` // Number of places per challenge, or 0 for none
+	Categories      []string `json:"categories"`       // Judging categories to announce winners of, or every category if left out
+	CategoryPlaces  int      `json:"category_places"`  // Number of places per category, or 0 for none
+}
@@ -0,0 +1,59 @@
+package models_test
+
+import (
+	"server/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestResultsReveal(t *testing.T) {
+	places := func(names ...string) []models.ResultPlace {
+		result := make([]models.ResultPlace, len(names))
+		for i, name := range names {
+			result[i] = models.ResultPlace{Place: i + 1, Name: name}
+		}
+		return result
+	}
+	results := models.NewResults(primitive.NewObjectID(), []models.ResultSection{
+		{Kind: models.ResultsChallenge, Title: "Hardware", Places: places("Nub")},
+		{Kind: models.ResultsOverall, Title: "Overall", Places: places("Arke", "Pip", "Hid")},
+	})
+	if results.TotalPlaces() != 4 {
+		t.Fatalf("Expected 4 places, got %d", results.TotalPlaces())
+	}
+
+	// Nothing is revealed at first, but every section is listed
+	public := results.PublicView()
+	if len(public.Sections) != 2 || len(public.Sections[1].Places) != 0 {
+		t.Error("No places should be revealed before the reveal starts")
+	}
+
+	// Each section is revealed from last place to first
+	expected := []string{"Nub", "Hid", "Pip", "Arke"}
+	for i, name := range expected {
+		_, place := results.NextReveal()
+		if place == nil || place.Name != name {
+			t.Fatalf("Expected %s to be revealed next", name)
+		}
+		results.SetRevealed(i + 1)
+	}
+	if _, place := results.NextReveal(); place != nil {
+		t.Error("Nothing should be left to reveal")
+	}
+
+	results.SetRevealed(3)
+	public = results.PublicView()
+	if len(public.Sections[1].Places) != 2 || public.Sections[1].Places[0].Name != "Pip" {
+		t.Error("Only the last two overall places should be revealed")
+	}
+
+	results.SetRevealed(10)
+	if results.Revealed != 4 {
+		t.Error("Revealing past the last place should reveal every place")
+	}
+	results.SetRevealed(-1)
+	if results.Revealed != 0 {
+		t.Error("Hiding before the first place should hide every place")
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"server/util"
+)
+
+// UserSession records who a session in the session store belongs to, so that admins can list and revoke sessions
+type UserSession struct {
+	Id        primitive.ObjectID `bson:"_id" json:"id"` // Same as the ID of the session in the session store
+	UserId    string             `bson:"user_id" json:"user_id"`
+	Email     string             `bson:"email" json:"email"`
+	Name      string             `bson:"name" json:"name"`
+	UserAgent string             `bson:"user_agent" json:"user_agent"`
+	Ip        string             `bson:"ip" json:"ip"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+	ExpiresAt primitive.DateTime `bson:"expires_at" json:"expires_at"`
+}
+
+func NewUserSession(id primitive.ObjectID, userId string, maxAge time.Duration) *UserSession {
+	return &UserSession{
+		Id:        id,
+		UserId:    userId,
+		CreatedAt: util.Now(),
+		ExpiresAt: primitive.NewDateTimeFromTime(time.Now().Add(maxAge)),
+	}
+}
+
+// Create custom marshal function to change the format of the primitive.DateTime to a unix timestamp
+func (s *UserSession) MarshalJSON() ([]byte, error) {
+	type Alias UserSession
+	return json.Marshal(&struct {
+		*Alias
+		CreatedAt int64 `json:"created_at"`
+		ExpiresAt int64 `json:"expires_at"`
+	}{
+		Alias:     (*Alias)(s),
+		CreatedAt: int64(s.CreatedAt),
+		ExpiresAt: int64(s.ExpiresAt),
+	})
+}
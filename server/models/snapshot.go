@@ -0,0 +1,298 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"server/util"
+)
+
+// EventBundleVersion is the schema version of bundles written by this server.
+// Bump it whenever a change to the models would stop older bundles from restoring correctly.
+// Version 2 added the change history of projects and the results.
+const EventBundleVersion = 2
+
+// EventBundle is the entire state of an event, as stored in a snapshot
+type EventBundle struct {
+	Version    int              `json:"version"`
+	Event      *Event           `json:"event"`
+	Options    *Options         `json:"options"`
+	Projects   []*Project       `json:"projects"`
+	Judges     []*Judge         `json:"judges"`
+	Flags      []*Flag          `json:"flags"`
+	Zones      []*TableZone     `json:"zones"`
+	Guilds     []*Guild         `json:"guilds"`
+	Challenges []*Challenge     `json:"challenges"`
+	History    []*ProjectChange `json:"history"`
+	Results    *Results         `json:"results"` // Nil if no results have been generated
+}
+
+// bundleProject is a project as written to a bundle. Bundles are only ever seen by admins, so they keep the
+// private fields which are left out whenever a project is sent anywhere else.
+type bundleProject struct {
+	*Project
+}
+
+// projectPrivateFields are the fields of a project which are only written to bundles
+type projectPrivateFields struct {
+	FeedbackToken string   `json:"feedback_token,omitempty"`
+	ContactEmails []string `json:"contact_emails,omitempty"`
+}
+
+func (p bundleProject) MarshalJSON() ([]byte, error) {
+	content, err := json.Marshal(p.Project)
+	if err != nil {
+		return nil, err
+	}
+	private, err := json.Marshal(projectPrivateFields{FeedbackToken: p.FeedbackToken, ContactEmails: p.ContactEmails})
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the private fields to the project's own JSON
+	fields := make(map[string]json.RawMessage)
+	err = json.Unmarshal(content, &fields)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(private, &fields)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+func (p *bundleProject) UnmarshalJSON(data []byte) error {
+	p.Project = &Project{}
+	err := json.Unmarshal(data, p.Project)
+	if err != nil {
+		return err
+	}
+	var private projectPrivateFields
+	err = json.Unmarshal(data, &private)
+	if err != nil {
+		return err
+	}
+	p.FeedbackToken = private.FeedbackToken
+	p.ContactEmails = private.ContactEmails
+	return nil
+}
+
+// Create custom marshal function to keep the private fields of projects, which would otherwise be lost when the
+// bundle is restored
+func (b *EventBundle) MarshalJSON() ([]byte, error) {
+	type Alias EventBundle
+	projects := make([]bundleProject, len(b.Projects))
+	for i, project := range b.Projects {
+		projects[i] = bundleProject{project}
+	}
+	return json.Marshal(&struct {
+		*Alias
+		Projects []bundleProject `json:"projects"`
+	}{
+		Alias:    (*Alias)(b),
+		Projects: projects,
+	})
+}
+
+// Create custom unmarshal function to read the private fields of projects
+func (b *EventBundle) UnmarshalJSON(data []byte) error {
+	type Alias EventBundle
+	aux := &struct {
+		Projects []bundleProject `json:"projects"`
+		*Alias
+	}{
+		Alias: (*Alias)(b),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	b.Projects = make([]*Project, len(aux.Projects))
+	for i, project := range aux.Projects {
+		b.Projects[i] = project.Project
+	}
+	return nil
+}
+
+// Snapshot is a stored copy of an event's state that can later be downloaded or restored.
+// The bundle itself is kept gzipped in Data, which is never sent when listing snapshots.
+type Snapshot struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventId     primitive.ObjectID `bson:"event_id" json:"event_id"`
+	Version     int                `bson:"version" json:"version"`
+	Reason      string             `bson:"reason" json:"reason"`
+	CreatedAt   primitive.DateTime `bson:"created_at" json:"created_at"`
+	NumProjects int                `bson:"num_projects" json:"num_projects"`
+	NumJudges   int                `bson:"num_judges" json:"num_judges"`
+	NumFlags    int                `bson:"num_flags" json:"num_flags"`
+	Data        []byte             `bson:"data,omitempty" json:"-"`
+}
+
+func NewSnapshot(bundle *EventBundle, reason string, data []byte) *Snapshot {
+	return &Snapshot{
+		EventId:     bundle.Event.Id,
+		Version:     bundle.Version,
+		Reason:      reason,
+		CreatedAt:   util.Now(),
+		NumProjects: len(bundle.Projects),
+		NumJudges:   len(bundle.Judges),
+		NumFlags:    len(bundle.Flags),
+		Data:        data,
+	}
+}
+
+// EncodeEventBundle serialises a bundle as gzipped JSON
+func EncodeEventBundle(bundle *EventBundle) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	err := json.NewEncoder(w).Encode(bundle)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeEventBundle reads a bundle written by EncodeEventBundle
+func DecodeEventBundle(data []byte) (*EventBundle, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEventBundle(content)
+}
+
+// ParseEventBundle parses a plain JSON bundle, making sure this server understands its schema version
+func ParseEventBundle(content []byte) (*EventBundle, error) {
+	var bundle EventBundle
+	err := json.Unmarshal(content, &bundle)
+	if err != nil {
+		return nil, err
+	}
+	if bundle.Version < 1 || bundle.Version > EventBundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d (this server supports versions 1 to %d)", bundle.Version, EventBundleVersion)
+	}
+	if bundle.Event == nil || bundle.Options == nil {
+		return nil, fmt.Errorf("bundle is missing its event or options")
+	}
+	for _, judge := range bundle.Judges {
+		judge.FillRankingArrays()
+	}
+	return &bundle, nil
+}
+
+// Create custom marshal function to change the format of the primitive.DateTime to a unix timestamp
+func (s *Snapshot) MarshalJSON() ([]byte, error) {
+	type Alias Snapshot
+	return json.Marshal(&struct {
+		*Alias
+		CreatedAt int64 `json:"created_at"`
+	}{
+		Alias:     (*Alias)(s),
+		CreatedAt: int64(s.CreatedAt),
+	})
+}
+
+// RemapIds gives every document in the bundle a new ID, rewriting all references between them.
+// This lets a bundle be imported alongside the event it was exported from without ID collisions.
+func (b *EventBundle) RemapIds() {
+	projectIds := make(map[primitive.ObjectID]primitive.ObjectID)
+	judgeIds := make(map[primitive.ObjectID]primitive.ObjectID)
+	remap := func(ids map[primitive.ObjectID]primitive.ObjectID, id primitive.ObjectID) primitive.ObjectID {
+		if newId, ok := ids[id]; ok {
+			return newId
+		}
+		return id
+	}
+
+	b.Options.Id = primitive.NewObjectID()
+	for _, zone := range b.Zones {
+		zone.Id = primitive.NewObjectID()
+	}
+	guildIds := make(map[primitive.ObjectID]primitive.ObjectID)
+	for _, guild := range b.Guilds {
+		newId := primitive.NewObjectID()
+		guildIds[guild.Id] = newId
+		guild.Id = newId
+	}
+	challengeIds := make(map[primitive.ObjectID]primitive.ObjectID)
+	for _, challenge := range b.Challenges {
+		newId := primitive.NewObjectID()
+		challengeIds[challenge.Id] = newId
+		challenge.Id = newId
+	}
+	for _, project := range b.Projects {
+		newId := primitive.NewObjectID()
+		projectIds[project.Id] = newId
+		project.Id = newId
+
+		for i := range project.ChallengeIds {
+			project.ChallengeIds[i] = remap(challengeIds, project.ChallengeIds[i])
+		}
+
+		if project.GuildId != nil {
+			guildId := remap(guildIds, *project.GuildId)
+			project.GuildId = &guildId
+		}
+	}
+	for _, judge := range b.Judges {
+		newId := primitive.NewObjectID()
+		judgeIds[judge.Id] = newId
+		judge.Id = newId
+
+		if judge.Current != nil {
+			current := remap(projectIds, *judge.Current)
+			judge.Current = &current
+		}
+		for i := range judge.SeenProjects {
+			judge.SeenProjects[i].ProjectId = remap(projectIds, judge.SeenProjects[i].ProjectId)
+		}
+		for i := range judge.CurrentRankings {
+			judge.CurrentRankings[i] = remap(projectIds, judge.CurrentRankings[i])
+		}
+		for _, batch := range judge.PastRankings {
+			for i := range batch {
+				batch[i] = remap(projectIds, batch[i])
+			}
+		}
+	}
+	if b.Results != nil {
+		b.Results.Id = primitive.NewObjectID()
+		for i := range b.Results.Sections {
+			section := &b.Results.Sections[i]
+			if section.ChallengeId != nil {
+				challengeId := remap(challengeIds, *section.ChallengeId)
+				section.ChallengeId = &challengeId
+			}
+			for j := range section.Places {
+				section.Places[j].ProjectId = remap(projectIds, section.Places[j].ProjectId)
+			}
+		}
+	}
+	for _, change := range b.History {
+		change.Id = primitive.NewObjectID()
+		change.ProjectId = remap(projectIds, change.ProjectId)
+	}
+	for _, flag := range b.Flags {
+		flag.Id = primitive.NewObjectID()
+		if flag.ProjectId != nil {
+			projectId := remap(projectIds, *flag.ProjectId)
+			flag.ProjectId = &projectId
+		}
+		if flag.JudgeId != nil {
+			judgeId := remap(judgeIds, *flag.JudgeId)
+			flag.JudgeId = &judgeId
+		}
+	}
+}
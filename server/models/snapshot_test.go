@@ -0,0 +1,216 @@
+package models_test
+
+import (
+	"encoding/json"
+	"server/models"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEventBundleRoundTrip(t *testing.T) {
+	event := models.NewEvent("DurHack")
+	event.Id = primitive.NewObjectID()
+	project := models.NewProject("Arke", "Guild", "12", "A fancy boat", "https://devpost.com/arke", "", "", []string{"Best Hack"})
+	project.Id = primitive.NewObjectID()
+	project.LastActivity = primitive.DateTime(1700000000000)
+	judge := models.NewJudge(event.Id, "keycloak-user")
+	judge.PastRankings = [][]primitive.ObjectID{{project.Id}}
+
+	bundle := &models.EventBundle{
+		Version:  models.EventBundleVersion,
+		Event:    event,
+		Options:  models.NewOptions(event.Id),
+		Projects: []*models.Project{project},
+		Judges:   []*models.Judge{judge},
+		Flags:    []*models.Flag{},
+	}
+
+	data, err := models.EncodeEventBundle(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := models.DecodeEventBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Event.Id != event.Id || decoded.Projects[0].Id != project.Id {
+		t.Errorf("Expected ids to survive a round trip")
+	}
+	if decoded.Projects[0].LastActivity != project.LastActivity {
+		t.Errorf("Expected last activity %v, got %v", project.LastActivity, decoded.Projects[0].LastActivity)
+	}
+	if decoded.Judges[0].PastRankings[0][0] != project.Id {
+		t.Errorf("Expected past rankings to survive a round trip")
+	}
+}
+
+func TestParseEventBundleRejectsNewerVersions(t *testing.T) {
+	_, err := models.ParseEventBundle([]byte(`{"version": 999, "event": {}, "options": {}}`))
+	if err == nil {
+		t.Errorf("Expected an error for an unsupported bundle version")
+	}
+}
+
+func TestEventBundleRemapIds(t *testing.T) {
+	eventId := primitive.NewObjectID()
+	project := models.NewProject("Arke", "", "12", "A fancy boat", "https://devpost.com/arke", "", "", []string{})
+	project.Id = primitive.NewObjectID()
+	judge := models.NewJudge(eventId, "keycloak-user")
+	judge.Id = primitive.NewObjectID()
+	judge.Current = &project.Id
+	judge.SeenProjects = []models.JudgedProject{*models.JudgeProjectFromProject(project, map[string]int{})}
+	judge.PastRankings = [][]primitive.ObjectID{{project.Id}}
+	flag, err := models.NewFlag(project, judge, "absent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldProjectId := project.Id
+
+	bundle := &models.EventBundle{
+		Options:  models.NewOptions(eventId),
+		Projects: []*models.Project{project},
+		Judges:   []*models.Judge{judge},
+		Flags:    []*models.Flag{flag},
+	}
+	bundle.RemapIds()
+
+	if project.Id == oldProjectId {
+		t.Errorf("Expected the project to get a new id")
+	}
+	if *judge.Current != project.Id || judge.SeenProjects[0].ProjectId != project.Id || judge.PastRankings[0][0] != project.Id {
+		t.Errorf("Expected judge references to point at the new project id")
+	}
+	if *flag.ProjectId != project.Id || *flag.JudgeId != judge.Id {
+		t.Errorf("Expected flag references to point at the new ids")
+	}
+}
+
+func TestEventBundleKeepsPrivateProjectFields(t *testing.T) {
+	event := models.NewEvent("DurHack")
+	event.Id = primitive.NewObjectID()
+	project := models.NewProject("Arke", "", "12", "A fancy boat", "", "", "", []string{})
+	project.Id = primitive.NewObjectID()
+	project.FeedbackToken = "secret-token"
+	project.ContactEmails = []string{"team@example.com"}
+
+	// The feedback token is never sent with the project itself
+	content, err := json.Marshal(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "secret-token") || strings.Contains(string(content), "team@example.com") {
+		t.Errorf("Expected the feedback token and contact emails to be left out of the project's JSON")
+	}
+
+	// But bundles keep them, so feedback links and emails to teams still work after a restore
+	bundle := &models.EventBundle{
+		Version:  models.EventBundleVersion,
+		Event:    event,
+		Options:  models.NewOptions(event.Id),
+		Projects: []*models.Project{project},
+	}
+	data, err := models.EncodeEventBundle(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := models.DecodeEventBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := decoded.Projects[0]
+	if len(restored.ContactEmails) != 1 || restored.ContactEmails[0] != "team@example.com" {
+		t.Errorf("Expected the contact emails to survive a round trip, got %v", restored.ContactEmails)
+	}
+	if restored.FeedbackToken != "secret-token" || restored.Id != project.Id || restored.Name != "Arke" {
+		t.Errorf("Expected the project and its feedback token to survive a round trip, got %+v", restored)
+	}
+}
+
+func TestEventBundleKeepsProjectHistory(t *testing.T) {
+	event := models.NewEvent("DurHack")
+	event.Id = primitive.NewObjectID()
+	project := models.NewProject("Arke", "", "12", "A fancy boat", "", "", "", []string{})
+	project.Id = primitive.NewObjectID()
+	changes := []models.ProjectFieldChange{{Field: "location", Old: "12", New: "14"}}
+	change := models.NewProjectChange(project, "admin", "Ada Admin", changes, true)
+	change.Id = primitive.NewObjectID()
+
+	bundle := &models.EventBundle{
+		Version:  models.EventBundleVersion,
+		Event:    event,
+		Options:  models.NewOptions(event.Id),
+		Projects: []*models.Project{project},
+		History:  []*models.ProjectChange{change},
+	}
+	data, err := models.EncodeEventBundle(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := models.DecodeEventBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded.History) != 1 {
+		t.Fatalf("Expected the history to survive a round trip, got %v", decoded.History)
+	}
+	restored := decoded.History[0]
+	if restored.ProjectId != project.Id || restored.ChangedAt != change.ChangedAt || restored.ChangedByName != "Ada Admin" || restored.Changes[0].New != "14" {
+		t.Errorf("Expected the same change back, got %+v", restored)
+	}
+
+	// History follows its project to a new id
+	decoded.RemapIds()
+	if restored.ProjectId != decoded.Projects[0].Id || restored.Id == change.Id {
+		t.Errorf("Expected history to point at the new project id")
+	}
+}
+
+func TestEventBundleKeepsResults(t *testing.T) {
+	event := models.NewEvent("DurHack")
+	event.Id = primitive.NewObjectID()
+	project := models.NewProject("Arke", "", "12", "A fancy boat", "", "", "", []string{})
+	project.Id = primitive.NewObjectID()
+	results := models.NewResults(event.Id, []models.ResultSection{{
+		Kind:   models.ResultsOverall,
+		Title:  "Overall",
+		Places: []models.ResultPlace{{Place: 1, ProjectId: project.Id, Name: "Arke", Score: 4.5}},
+	}})
+	results.Published = true
+	results.Revealed = 1
+
+	bundle := &models.EventBundle{
+		Version:  models.EventBundleVersion,
+		Event:    event,
+		Options:  models.NewOptions(event.Id),
+		Projects: []*models.Project{project},
+		Results:  results,
+	}
+	data, err := models.EncodeEventBundle(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := models.DecodeEventBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Scores are kept even though they aren't public
+	restored := decoded.Results
+	if restored == nil || !restored.Published || restored.Revealed != 1 || restored.GeneratedAt != results.GeneratedAt {
+		t.Fatalf("Expected the results to survive a round trip, got %+v", restored)
+	}
+	place := restored.Sections[0].Places[0]
+	if place.ProjectId != project.Id || place.Score != 4.5 {
+		t.Errorf("Expected the same place back, got %+v", place)
+	}
+
+	// Places follow their project to a new id
+	decoded.RemapIds()
+	if restored.Sections[0].Places[0].ProjectId != decoded.Projects[0].Id {
+		t.Errorf("Expected places to point at the new project id")
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenSet is the encrypted OAuth2 tokens of a user. The tokens are encrypted with a data key of their own,
+// which is encrypted with the key named by KeyId (envelope encryption), so keys can be rotated.
+type TokenSet struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty"`
+	UserId       string             `bson:"user_id"`
+	KeyId        string             `bson:"key_id"`
+	EncryptedKey []byte             `bson:"encrypted_key"`
+	Ciphertext   []byte             `bson:"ciphertext"`
+	ExpiresAt    primitive.DateTime `bson:"expires_at"` // The token set is deleted by a TTL index after this
+
+	// Token sets saved before encryption have their tokens in plain text
+	PlainToken   bson.Raw `bson:"token_set,omitempty"`
+	PlainIdToken string   `bson:"id_token,omitempty"`
+}
+
+// IsPlaintext checks whether the token set was saved before token sets were encrypted
+func (t *TokenSet) IsPlaintext() bool {
+	return t.PlainToken != nil
+}
@@ -1,5 +1,7 @@
 package models
 
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
 type JudgeStats struct {
 	Num       int64   `json:"num"`
 	AvgSeen   float64 `json:"avg_seen"`
@@ -28,6 +30,16 @@ type IdRequest struct {
 	Id string `json:"id"`
 }
 
+type PrioritizeRequest struct {
+	IdRequest
+	Minutes int64 `json:"minutes"` // How long the priority lasts for, or 0 for until the project is next picked
+}
+
+type PriorityQueueEntry struct {
+	Project *Project `json:"project"`
+	Busy    bool     `json:"busy"`
+}
+
 type MultiIdHideRequest struct {
 	Ids  []string `json:"ids"`
 	Hide bool     `json:"hide"`
@@ -38,6 +50,36 @@ type ProjectLocationRequest struct {
 	Location string `json:"location"`
 }
 
+type ProjectStatusRequest struct {
+	Status string `json:"status"` // present, absent or hidden
+}
+
+type LocationRequest struct {
+	Location string `json:"location"`
+}
+
+type CreateApiTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Days   int64    `json:"days"` // How long the token lasts for, 30 days by default
+}
+
 type EditJudgeRequest struct {
 	Notes string `json:"notes"`
 }
+
+// JudgeBatchRanking is a single batch ranking submitted by a judge, as exported for analysis
+type JudgeBatchRanking struct {
+	JudgeId     primitive.ObjectID    `json:"judge_id"`
+	JudgeName   string                `json:"judge_name"`
+	BatchIndex  int                   `json:"batch_index"`
+	SubmittedAt int64                 `json:"submitted_at"` // Zero for batches submitted before submission times were recorded
+	Projects    []RankedJudgedProject `json:"projects"`     // Ordered from first to last place
+}
+
+type RankedJudgedProject struct {
+	ProjectId  primitive.ObjectID `json:"project_id"`
+	Name       string             `json:"name"`
+	Location   string             `json:"location"`
+	Categories map[string]int     `json:"categories"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TableZone is a range of table numbers that projects of a guild are allocated to, e.g. the tables in one room.
+// A guild can have several zones, which are filled in order of their first table.
+type TableZone struct {
+	Id         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventId    primitive.ObjectID `bson:"event_id" json:"event_id"`
+	Name       string             `bson:"name" json:"name"`
+	Guild      string             `bson:"guild" json:"guild"` // Guild whose projects sit in this zone, empty for projects without a guild
+	FirstTable int                `bson:"first_table" json:"first_table"`
+	LastTable  int                `bson:"last_table" json:"last_table"`
+	Capacity   int                `bson:"capacity" json:"capacity"` // Maximum number of projects in the zone, or 0 for one per table
+}
+
+func NewTableZone(name string, guild string, firstTable int, lastTable int, capacity int) *TableZone {
+	return &TableZone{
+		Name:       name,
+		Guild:      guild,
+		FirstTable: firstTable,
+		LastTable:  lastTable,
+		Capacity:   capacity,
+	}
+}
+
+// Size returns the number of projects that can be allocated to the zone
+func (z *TableZone) Size() int {
+	tables := z.LastTable - z.FirstTable + 1
+	if z.Capacity > 0 && z.Capacity < tables {
+		return z.Capacity
+	}
+	return tables
+}
+
+// Contains checks whether a table number is in the zone
+func (z *TableZone) Contains(table int) bool {
+	return table >= z.FirstTable && table <= z.LastTable
+}
+
+// Validate checks that the zone has a name and a valid range, and doesn't overlap another zone of the same guild
+func (z *TableZone) Validate(others []*TableZone) error {
+	if z.Name == "" {
+		return fmt.Errorf("zone name is required")
+	}
+	if z.FirstTable < 1 || z.LastTable < z.FirstTable {
+		return fmt.Errorf("table range must start at 1 or above and end at or after its start")
+	}
+	if z.Capacity < 0 {
+		return fmt.Errorf("capacity cannot be negative")
+	}
+	for _, other := range others {
+		if other.Id == z.Id || other.Guild != z.Guild {
+			continue
+		}
+		if z.FirstTable <= other.LastTable && other.FirstTable <= z.LastTable {
+			return fmt.Errorf("table range overlaps zone '%s' (tables %d to %d)", other.Name, other.FirstTable, other.LastTable)
+		}
+	}
+	return nil
+}
+
+type TableZoneRequest struct {
+	Name       string `json:"name"`
+	Guild      string `json:"guild"`
+	FirstTable int    `json:"first_table"`
+	LastTable  int    `json:"last_table"`
+	Capacity   int    `json:"capacity"`
+}
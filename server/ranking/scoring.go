@@ -6,15 +6,15 @@ import (
 	"server/database"
 )
 
-func GetScoresFromDB(db *mongo.Database) (error, string, []RankedObject) {
+func GetScoresFromDB(db *mongo.Database, eventId primitive.ObjectID) (error, string, []RankedObject) {
 	// Get all the projects
-	projects, err := database.FindAllProjects(db)
+	projects, err := database.FindAllProjects(db, eventId)
 	if err != nil {
 		return err, "error getting projects: ", nil
 	}
 
 	// Get all the judges
-	judges, err := database.FindAllJudges(db)
+	judges, err := database.FindAllJudges(db, eventId)
 	if err != nil {
 		return err, "error getting judges: ", nil
 	}
@@ -29,8 +29,11 @@ func GetAdminStats(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Aggregate the stats
-	stats, err := database.AggregateStats(db)
+	stats, err := database.AggregateStats(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error aggregating stats: " + err.Error()})
 		return
@@ -45,11 +48,14 @@ func GetClock(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the clock from the context
 	clock := ctx.MustGet("clock").(*models.ClockState)
 
 	// Save the options in the database
-	err := database.UpdateClock(db, clock)
+	err := database.UpdateClock(db, event.Id, clock)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving options: " + err.Error()})
 		return
@@ -63,6 +69,9 @@ func PauseClock(ctx *gin.Context) *models.ClockState {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the clock from the context
 	clock := ctx.MustGet("clock").(*models.ClockState)
 
@@ -70,7 +79,7 @@ func PauseClock(ctx *gin.Context) *models.ClockState {
 	clock.Pause()
 
 	// Save the clock in the database
-	err := database.UpdateClock(db, clock)
+	err := database.UpdateClock(db, event.Id, clock)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving clock: " + err.Error()})
 		return nil
@@ -93,8 +102,11 @@ func UnpauseClock(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Check if judging has ended
-	judgingEnded, err := database.GetJudgingEnded(db)
+	judgingEnded, err := database.GetJudgingEnded(db, event.Id)
 	if judgingEnded {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Judging has been ended. Clock cannot be unpaused."})
 		return
@@ -107,7 +119,7 @@ func UnpauseClock(ctx *gin.Context) {
 	clock.Resume()
 
 	// Save the clock in the database
-	err = database.UpdateClock(db, clock)
+	err = database.UpdateClock(db, event.Id, clock)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving clock: " + err.Error()})
 		return
@@ -122,6 +134,9 @@ func ResetClock(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the clock from the context
 	clock := ctx.MustGet("clock").(*models.ClockState)
 
@@ -129,7 +144,7 @@ func ResetClock(ctx *gin.Context) {
 	clock.Reset()
 
 	// Save the clock in the database
-	err := database.UpdateClock(db, clock)
+	err := database.UpdateClock(db, event.Id, clock)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving clock: " + err.Error()})
 		return
@@ -151,18 +166,36 @@ func IsClockPaused(ctx *gin.Context) {
 	}
 }
 
-// POST /admin/reset - ResetDatabase resets the database
+// POST /admin/reset - ResetDatabase deletes all data of the event
 func ResetDatabase(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Keep a snapshot of everything that is about to be deleted
+	err := saveClock(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving clock: " + err.Error()})
+		return
+	}
+	_, err = database.CreateSnapshot(db, event, "reset")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error creating snapshot before reset: " + err.Error()})
+		return
+	}
+
 	// Reset the database
-	err := database.DropAll(db)
+	err = database.DropAll(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error resetting database: " + err.Error()})
 		return
 	}
 
+	// Forget the event's clock and comparisons so that they are rebuilt from the now empty event
+	ctx.MustGet("event_states").(*eventStates).forget(event.Id)
+
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
 }
@@ -172,23 +205,54 @@ func GetFlags(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get all the flags
-	flags, err := database.FindAllFlags(db)
+	flags, err := database.FindAllFlags(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting flags: " + err.Error()})
 		return
 	}
 
+	// Fill in the names of the judges who made the flags
+	if !fillFlagJudgeNames(ctx, flags) {
+		return
+	}
+
 	// Send OK
 	ctx.JSON(http.StatusOK, flags)
 }
 
+// fillFlagJudgeNames fills in the names of the judges who made flags from their cached profiles.
+// Sends an error response and returns false if it can't.
+func fillFlagJudgeNames(ctx *gin.Context, flags []*models.Flag) bool {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	judges, err := database.FindAllJudges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judges: " + err.Error()})
+		return false
+	}
+	judgeNames := getJudgeNames(judges)
+	for _, flag := range flags {
+		if flag.JudgeId != nil {
+			flag.JudgeName = judgeNames[*flag.JudgeId]
+		}
+	}
+	return true
+}
+
 func GetOptions(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the options
-	options, err := database.GetOptions(db)
+	options, err := database.GetOptions(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting options: " + err.Error()})
 		return
@@ -203,14 +267,17 @@ func ExportProjects(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get all the projects
-	projects, err := database.FindAllProjects(db)
+	projects, err := database.FindAllProjects(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects: " + err.Error()})
 		return
 	}
 	// todo: option to not include batches smaller than current batch size in score (judge ending submission period)
-	err, errStr, scores := ranking.GetScoresFromDB(db)
+	err, errStr, scores := ranking.GetScoresFromDB(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": errStr + err.Error()})
 		return
@@ -229,13 +296,16 @@ func ExportProjectsByChallenge(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get all the projects
-	projects, err := database.FindAllProjects(db)
+	projects, err := database.FindAllProjects(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects: " + err.Error()})
 		return
 	}
-	err, errStr, scores := ranking.GetScoresFromDB(db)
+	err, errStr, scores := ranking.GetScoresFromDB(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": errStr + err.Error()})
 		return
@@ -252,20 +322,43 @@ func ExportProjectsByChallenge(ctx *gin.Context) {
 	funcs.AddZipFile("projects", zipData, ctx)
 }
 
-// POST /admin/export/rankings - ExportRankings exports the rankings of each judge as a CSV
+// GET /admin/export/rankings - ExportRankings exports every batch ranking submitted by the judges,
+// as a CSV or, with ?format=json, as JSON
 func ExportRankings(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get all the judges
-	judges, err := database.FindAllJudges(db)
+	judges, err := database.FindAllJudges(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judges: " + err.Error()})
 		return
 	}
 
+	// Get the judges' names
+	judgeNames := getJudgeNames(judges)
+
+	// Get the categories
+	categories, err := database.GetCategories(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting categories: " + err.Error()})
+		return
+	}
+
+	// List the batches
+	batches := funcs.BuildJudgeBatchRankings(judges, judgeNames)
+
+	// Send JSON
+	if ctx.Query("format") == "json" {
+		ctx.JSON(http.StatusOK, batches)
+		return
+	}
+
 	// Create the CSV
-	csvData := funcs.CreateJudgeRankingCSV(judges)
+	csvData := funcs.CreateJudgeRankingCSV(batches, categories)
 
 	// Send CSV
 	funcs.AddCsvData("rankings", csvData, ctx)
@@ -276,8 +369,11 @@ func GetJudgingTimer(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the options
-	options, err := database.GetOptions(db)
+	options, err := database.GetOptions(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting options: " + err.Error()})
 		return
@@ -296,6 +392,9 @@ func SetJudgingTimer(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get request
 	var req SetJudgingTimerRequest
 	err := ctx.BindJSON(&req)
@@ -305,7 +404,7 @@ func SetJudgingTimer(ctx *gin.Context) {
 	}
 
 	// Get the options
-	options, err := database.GetOptions(db)
+	options, err := database.GetOptions(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting options: " + err.Error()})
 		return
@@ -333,6 +432,9 @@ func SetCategories(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the categories
 	var categoriesReq SetCategoriesRequest
 	err := ctx.BindJSON(&categoriesReq)
@@ -342,7 +444,7 @@ func SetCategories(ctx *gin.Context) {
 	}
 
 	// Save the categories in the database
-	err = database.UpdateCategories(db, categoriesReq.Categories)
+	err = database.UpdateCategories(db, event.Id, categoriesReq.Categories)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving categories: " + err.Error()})
 		return
@@ -365,6 +467,9 @@ func SetMinViews(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the views
 	var minViewsReq MinViewsRequest
 	err := ctx.BindJSON(&minViewsReq)
@@ -373,7 +478,7 @@ func SetMinViews(ctx *gin.Context) {
 	}
 
 	// Save the min views in the db
-	err = database.UpdateMinViews(db, minViewsReq.MinViews)
+	err = database.UpdateMinViews(db, event.Id, minViewsReq.MinViews)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving min views: " + err.Error()})
 		return
@@ -388,6 +493,9 @@ func SetRankingBatchSize(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the views
 	var brsReq BatchRankingSizeRequest
 	err := ctx.BindJSON(&brsReq)
@@ -396,7 +504,7 @@ func SetRankingBatchSize(ctx *gin.Context) {
 	}
 
 	// Save the ranking batch size in the db
-	err = database.UpdateBatchRankingSize(db, brsReq.BRS)
+	err = database.UpdateBatchRankingSize(db, event.Id, brsReq.BRS)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving batch ranking size: " + err.Error()})
 		return
@@ -411,7 +519,10 @@ func GetScores(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
-	err, errStr, scores := ranking.GetScoresFromDB(db)
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	err, errStr, scores := ranking.GetScoresFromDB(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": errStr + err.Error()})
 		return
@@ -425,8 +536,11 @@ func GetScores(ctx *gin.Context) {
 func isJudgingEnded(ctx *gin.Context) {
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get judging_ended flag from database
-	judgingEnded, err := database.GetJudgingEnded(db)
+	judgingEnded, err := database.GetJudgingEnded(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judging_ended flag: " + err.Error()})
 		return
@@ -446,6 +560,9 @@ func endJudging(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Pause the clock
 	clock := PauseClock(ctx)
 
@@ -455,7 +572,7 @@ func endJudging(ctx *gin.Context) {
 	}
 
 	// Save the judging_ended flag in the db
-	err := database.SetEndJudging(db)
+	err := database.SetEndJudging(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error setting judging_ended: " + err.Error()})
 		return
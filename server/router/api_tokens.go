@@ -0,0 +1,177 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"server/auth"
+	"server/database"
+	"server/models"
+	"server/util"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// apiTokenPrefix starts every API token, so they are easy to spot in scripts and secret scanners
+const apiTokenPrefix = "jury_"
+
+// hashApiToken hashes an API token for storing. Tokens are long and random, so a fast hash is enough.
+func hashApiToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// bearerToken returns the token in the Authorization header of a request, if it has one
+func bearerToken(ctx *gin.Context) (string, bool) {
+	header := ctx.GetHeader("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// abortInvalidToken answers a request with an API token which doesn't exist or has expired with a JSON 401
+func abortInvalidToken(ctx *gin.Context) {
+	ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API token is invalid, expired or revoked", "code": AuthErrorInvalidToken})
+}
+
+// authenticateApiToken authenticates a request with an API token instead of a session. The token acts as a user
+// who is in no groups, so it can only use admin endpoints which allow its scopes.
+func authenticateApiToken(ctx *gin.Context, token string) {
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	apiToken, err := database.FindApiTokenByHash(db, hashApiToken(token))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error getting API token from database: " + err.Error()})
+		return
+	}
+	now := util.Now()
+	if apiToken == nil || apiToken.IsExpired(now) {
+		abortInvalidToken(ctx)
+		return
+	}
+
+	// Record when the token was last used, at most once a minute to save writes
+	if now.Time().Sub(apiToken.LastUsedAt.Time()) > time.Minute {
+		err = database.SetApiTokenLastUsed(db, apiToken.Id, now)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error updating API token in database: " + err.Error()})
+			return
+		}
+	}
+
+	// Changes made with the token are attributed to it
+	userInfo := &auth.DurHackKeycloakUserInfo{FirstNames: "API token " + apiToken.Name}
+	userInfo.Subject = "api-token:" + apiToken.Id.Hex()
+	ctx.Set("user", userInfo)
+	ctx.Set("api_token", apiToken)
+	ctx.Next()
+}
+
+// POST /api/tokens - CreateApiToken creates an API token with some scopes for scripts to use. The token is only
+// ever sent in this response.
+func CreateApiToken(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the admin from the context
+	user := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo)
+
+	// Get the token's details from the request
+	var req models.CreateApiTokenRequest
+	err := ctx.BindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "token name cannot be empty"})
+		return
+	}
+	if len(req.Scopes) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "token must have at least one scope"})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.IsValidScope(scope) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid scope " + scope + ", expected one of " + strings.Join(models.ApiTokenScopes, ", ")})
+			return
+		}
+	}
+	if req.Days == 0 {
+		req.Days = 30
+	}
+	if req.Days < 1 || req.Days > 365 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "tokens must last between 1 and 365 days"})
+		return
+	}
+
+	// Create the token
+	secret, err := util.NewToken()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error generating token: " + err.Error()})
+		return
+	}
+	token := apiTokenPrefix + secret
+	apiToken := models.NewApiToken(req.Name, req.Scopes, user.Subject, user.GetFullName(), time.Duration(req.Days)*24*time.Hour)
+	apiToken.Prefix = token[:len(apiTokenPrefix)+6]
+	apiToken.Hash = hashApiToken(token)
+	err = database.InsertApiToken(db, apiToken)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error inserting token into database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "token": token, "api_token": apiToken})
+}
+
+// GET /api/tokens - ListApiTokens lists the API tokens which haven't expired
+func ListApiTokens(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the tokens
+	apiTokens, err := database.FindActiveApiTokens(db)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting tokens from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, apiTokens)
+}
+
+// DELETE /api/tokens/:id - RevokeApiToken revokes an API token, e.g. when a script no longer needs it
+func RevokeApiToken(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the token ID
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid token ID: " + err.Error()})
+		return
+	}
+
+	// Revoke it
+	deleted, err := database.DeleteApiToken(db, id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error revoking token: " + err.Error()})
+		return
+	}
+	if !deleted {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
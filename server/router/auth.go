@@ -2,18 +2,23 @@ package router
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
 	"net/url"
-	"slices"
+	"strings"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	mongoOptions "go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/oauth2"
 	"server/auth"
 	"server/config"
+	"server/database"
+	"server/util"
 )
 
 func getOrGenerateCodeVerifier(ctx *gin.Context) (string, error) {
@@ -25,13 +30,42 @@ func getOrGenerateCodeVerifier(ctx *gin.Context) (string, error) {
 	}
 	codeVerifier = oauth2.GenerateVerifier()
 	session.Set("keycloak_code_verifier", codeVerifier)
-	err := session.Save()
+	err := saveSession(ctx)
 	if err != nil {
 		return "", err
 	}
 	return codeVerifier.(string), nil
 }
 
+// beginLogin stores a new state and nonce for the login in the session, along with where to send the user
+// once they have logged in
+func beginLogin(ctx *gin.Context, returnTo string) (string, string, error) {
+	state, err := util.NewToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := util.NewToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	session := sessions.Default(ctx)
+	session.Set("oauth2_state", state)
+	session.Set("oauth2_nonce", nonce)
+	if returnTo != "" {
+		session.Set("login_return_to", returnTo)
+	} else {
+		session.Delete("login_return_to")
+	}
+	err = saveSession(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return state, nonce, nil
+}
+
+// GET /auth/keycloak/login?return_to= - BeginKeycloakOAuth2Flow sends the user to the auth provider to log in.
+// Once logged in they are sent to return_to if it is a path on the client.
 func BeginKeycloakOAuth2Flow() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		codeVerifier, err := getOrGenerateCodeVerifier(ctx)
@@ -39,10 +73,17 @@ func BeginKeycloakOAuth2Flow() gin.HandlerFunc {
 			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
-		authURL := auth.KeycloakOAuth2Config.AuthCodeURL(
-			"",
-			oauth2.S256ChallengeOption(codeVerifier),
-		)
+		state, nonce, err := beginLogin(ctx, util.SafeReturnPath(ctx.Query("return_to"), config.Origin))
+		if err != nil {
+			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		authURL, err := ctx.MustGet("auth_provider").(auth.AuthProvider).LoginUrl(context.Background(), state, nonce, codeVerifier)
+		if err != nil {
+			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+			fmt.Println(err.Error())
+			return
+		}
 		ctx.Redirect(http.StatusFound, authURL)
 	}
 }
@@ -59,67 +100,73 @@ func KeycloakOAuth2FlowCallback() gin.HandlerFunc {
 			return
 		}
 
+		// Check the state matches the one stored when the login began, so that logins can't be forged
+		state, _ := session.Get("oauth2_state").(string)
+		nonce, _ := session.Get("oauth2_nonce").(string)
+		returnTo, _ := session.Get("login_return_to").(string)
+		if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(ctx.Query("state"))) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "login state doesn't match, please try logging in again"})
+			return
+		}
+
 		session.Delete("keycloak_code_verifier")
-		err := session.Save()
+		session.Delete("oauth2_state")
+		session.Delete("oauth2_nonce")
+		session.Delete("login_return_to")
+		err := saveSession(ctx)
 		if err != nil {
 			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 			fmt.Println(err.Error())
 			return
 		}
 
-		oauth2Token, err := auth.KeycloakOAuth2Config.Exchange(
+		// Exchange the code for a login, which the provider verifies locally
+		login, err := ctx.MustGet("auth_provider").(auth.AuthProvider).CompleteLogin(
 			context.Background(),
 			ctx.Query("code"),
-			oauth2.VerifierOption(codeVerifier.(string)),
+			codeVerifier.(string),
+			nonce,
 		)
+		if errors.Is(err, auth.ErrNonceMismatch) {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "login nonce doesn't match, please try logging in again"})
+			return
+		}
 		if err != nil {
 			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 			fmt.Println(err.Error())
 			return
 		}
+		userInfo := login.UserInfo
 
-		userInfo, err := auth.KeycloakOIDCProvider.UserInfo(
-			context.Background(),
-			oauth2.StaticTokenSource(oauth2Token),
-			//keycloakOAuth2Config.TokenSource(context.Background(), oauth2Token),
-		)
+		session.Set("user_id", userInfo.Subject)
+		err = saveSession(ctx)
 		if err != nil {
 			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 			fmt.Println(err.Error())
 			return
 		}
-
-		session.Set("user_id", userInfo.Subject)
-		err = session.Save()
+		err = recordSession(ctx, userInfo)
 		if err != nil {
 			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 			fmt.Println(err.Error())
 			return
 		}
 
-		idToken := oauth2Token.Extra("id_token").(string)
-		db := ctx.MustGet("db").(*mongo.Database)
-		_, err = db.Collection("token_set").UpdateOne(
-			context.Background(),
-			gin.H{"user_id": userInfo.Subject},
-			gin.H{"$set": gin.H{
-				"user_id":   userInfo.Subject,
-				"token_set": oauth2Token,
-				"id_token":  idToken,
-			},
-			},
-			mongoOptions.Update().SetUpsert(true),
-		)
-
+		// Save the tokens encrypted, so the login can be verified again once it drops out of the cache
+		err = saveTokenSet(ctx.MustGet("db").(*mongo.Database), ctx.MustGet("token_cipher").(*auth.TokenCipher), userInfo.Subject, login.Token, login.IdToken)
 		if err != nil {
 			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 			fmt.Println(err.Error())
 			return
 		}
 
+		// Cache the login so the next requests don't need to verify it again
+		ctx.MustGet("logins").(*auth.LoginCache).Set(userInfo.Subject, login)
+
 		ctx.Set("user", userInfo)
-		ctx.Set("user_token_set", oauth2Token)
-		ctx.Set("user_id_token", idToken)
+		ctx.Set("user_token_set", login.Token)
+		ctx.Set("user_id_token", login.IdToken)
+		ctx.Set("login_return_to", returnTo)
 		ctx.Next()
 	}
 }
@@ -127,8 +174,17 @@ func KeycloakOAuth2FlowCallback() gin.HandlerFunc {
 func HandleLoginSuccess() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		userInfo := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo)
+		authProvider := ctx.MustGet("auth_provider").(auth.AuthProvider)
+
+		// Send admins and judges back to where they were before logging in, e.g. a project they scanned
+		returnTo := ctx.GetString("login_return_to")
+		if returnTo != "" && (authProvider.InGroup(userInfo, "/admins") || authProvider.InGroup(userInfo, "/judges")) {
+			ctx.Redirect(http.StatusFound, strings.TrimSuffix(config.Origin, "/")+returnTo)
+			return
+		}
+
 		// Handle admins
-		if slices.Contains(userInfo.Groups, "/admins") {
+		if authProvider.InGroup(userInfo, "/admins") {
 			urlPath, err := url.JoinPath(config.Origin, "/admin")
 			if err != nil {
 				_ = ctx.AbortWithError(http.StatusInternalServerError, err)
@@ -140,7 +196,7 @@ func HandleLoginSuccess() gin.HandlerFunc {
 		}
 
 		// Handle judges
-		if slices.Contains(userInfo.Groups, "/judges") {
+		if authProvider.InGroup(userInfo, "/judges") {
 			urlPath, err := url.JoinPath(config.Origin, "/judge")
 			if err != nil {
 				_ = ctx.AbortWithError(http.StatusInternalServerError, err)
@@ -186,9 +242,25 @@ func HandleLoginSuccess() gin.HandlerFunc {
 
 func Logout() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if _, ok := ctx.Get("api_token"); ok {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "API tokens can't log out, revoke the token instead"})
+			return
+		}
+
 		session := sessions.Default(ctx)
+		if userId, ok := session.Get("user_id").(string); ok {
+			ctx.MustGet("logins").(*auth.LoginCache).Forget(userId)
+		}
+		if id, err := primitive.ObjectIDFromHex(session.ID()); err == nil {
+			err = database.DeleteUserSessionRecord(ctx.MustGet("db").(*mongo.Database), id)
+			if err != nil {
+				_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+				fmt.Println(err.Error())
+				return
+			}
+		}
 		session.Delete("user_id")
-		err := session.Save()
+		err := saveSession(ctx)
 		if err != nil {
 			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 			fmt.Println(err.Error())
@@ -196,36 +268,83 @@ func Logout() gin.HandlerFunc {
 		}
 
 		idToken := ctx.MustGet("user_id_token").(string)
-
-		// See https://github.com/coreos/go-oidc/pull/226#issuecomment-1130411016
+		logoutUrl, err := ctx.MustGet("auth_provider").(auth.AuthProvider).LogoutUrl(context.Background(), idToken, config.Origin)
 		if err != nil {
 			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 			fmt.Println(err.Error())
 			return
 		}
 
-		var claims struct {
-			EndSessionURL string `json:"end_session_endpoint"`
+		ctx.Redirect(http.StatusFound, logoutUrl)
+	}
+}
+
+var localLoginTemplate = template.Must(template.New("local-login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Log in to Jury</title></head>
+<body>
+<h1>Log in to Jury</h1>
+{{if .Error}}<p style="color: red">{{.Error}}</p>{{end}}
+<form method="post">
+<input type="hidden" name="state" value="{{.State}}">
+<input type="hidden" name="nonce" value="{{.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+<p><label>Email <input type="email" name="email" value="{{.Email}}" required autofocus></label></p>
+<p><label>Password <input type="password" name="password" required></label></p>
+<p><button type="submit">Log in</button></p>
+</form>
+</body>
+</html>`))
+
+type localLoginPage struct {
+	State         string
+	Nonce         string
+	CodeChallenge string
+	Email         string
+	Error         string
+}
+
+// LocalLoginForm shows the login form of the local auth provider
+func LocalLoginForm() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+		ctx.Header("Content-Type", "text/html; charset=utf-8")
+		_ = localLoginTemplate.Execute(ctx.Writer, localLoginPage{
+			State:         ctx.Query("state"),
+			Nonce:         ctx.Query("nonce"),
+			CodeChallenge: ctx.Query("code_challenge"),
+		})
+	}
+}
+
+// LocalLogin checks the details from the local login form and sends the user to the login callback with a code
+func LocalLogin(localProvider *auth.LocalProvider) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		page := localLoginPage{
+			State:         ctx.PostForm("state"),
+			Nonce:         ctx.PostForm("nonce"),
+			CodeChallenge: ctx.PostForm("code_challenge"),
+			Email:         ctx.PostForm("email"),
 		}
-		err = auth.KeycloakOIDCProvider.Claims(&claims)
+
+		code, err := localProvider.Authorise(page.Email, ctx.PostForm("password"), page.CodeChallenge, page.Nonce)
 		if err != nil {
-			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
-			fmt.Println(err.Error())
+			page.Error = err.Error()
+			ctx.Status(http.StatusUnauthorized)
+			ctx.Header("Content-Type", "text/html; charset=utf-8")
+			_ = localLoginTemplate.Execute(ctx.Writer, page)
 			return
 		}
-		// ... claims.EndSessionURL is now end session URL to use
-		parsedURL, err := url.Parse(claims.EndSessionURL)
+
+		callbackRawUrl, err := url.JoinPath(config.ApiOrigin, "/api/auth/keycloak/callback")
 		if err != nil {
 			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
 			fmt.Println(err.Error())
 			return
 		}
-
-		queryValues := parsedURL.Query()
-		queryValues.Add("id_token_hint", idToken)
-		queryValues.Add("post_logout_redirect_uri", config.Origin)
-		parsedURL.RawQuery = queryValues.Encode()
-
-		ctx.Redirect(http.StatusFound, parsedURL.String())
+		query := url.Values{}
+		query.Set("code", code)
+		query.Set("state", page.State)
+		ctx.Redirect(http.StatusFound, callbackRawUrl+"?"+query.Encode())
 	}
 }
@@ -0,0 +1,350 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"server/database"
+	"server/funcs"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// linkChallenges points projects at the challenges named in their challenge lists, creating any challenges that
+// don't exist yet and replacing aliases with the challenges' names. Sends an error response and returns false if it can't.
+func linkChallenges(ctx *gin.Context, projects []*models.Project) bool {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	challenges, err := database.FindChallenges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting challenges from database: " + err.Error()})
+		return false
+	}
+
+	for _, project := range projects {
+		project.ChallengeList = funcs.CanonicaliseChallengeList(challenges, project.ChallengeList)
+		project.ChallengeIds = make([]primitive.ObjectID, len(project.ChallengeList))
+		for i, name := range project.ChallengeList {
+			// Create the challenge if it doesn't exist
+			challenge := models.FindChallengeByName(challenges, name)
+			if challenge == nil {
+				challenge = models.NewChallenge(name)
+				err = database.InsertChallenge(db, event.Id, challenge)
+				if err != nil {
+					ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error inserting challenge into database: " + err.Error()})
+					return false
+				}
+				challenges = append(challenges, challenge)
+			}
+			project.ChallengeIds[i] = challenge.Id
+		}
+	}
+	return true
+}
+
+// GET /admin/challenges - ListChallenges lists the challenges of the event
+func ListChallenges(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the challenges from the database
+	challenges, err := database.FindChallenges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting challenges from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, challenges)
+}
+
+// bindChallenge reads the details of a challenge from the request and validates them against the other
+// challenges of the event, sending an error response if they are invalid
+func bindChallenge(ctx *gin.Context, challenge *models.Challenge) bool {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the challenge from the request
+	var challengeReq models.ChallengeRequest
+	err := ctx.BindJSON(&challengeReq)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return false
+	}
+	challenge.Name = strings.Join(strings.Fields(challengeReq.Name), " ")
+	challenge.Aliases = funcs.CanonicaliseChallengeList(nil, challengeReq.Aliases)
+	challenge.Sponsor, challenge.Description, challenge.Prizes = challengeReq.Sponsor, challengeReq.Description, challengeReq.Prizes
+
+	// Validate the challenge
+	challenges, err := database.FindChallenges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting challenges from database: " + err.Error()})
+		return false
+	}
+	err = challenge.Validate(challenges)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid challenge: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// POST /admin/challenges - CreateChallenge creates a challenge
+func CreateChallenge(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the challenge from the request
+	challenge := models.NewChallenge("")
+	if !bindChallenge(ctx, challenge) {
+		return
+	}
+
+	// Insert the challenge into the database
+	err := database.InsertChallenge(db, event.Id, challenge)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error inserting challenge into database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, challenge)
+}
+
+// findChallengeFromParam gets the challenge of the event with the ID in the URL, sending an error response if there is none
+func findChallengeFromParam(ctx *gin.Context) *models.Challenge {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Convert challenge ID string to ObjectID
+	challengeObjectId, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid challenge ID"})
+		return nil
+	}
+
+	// Get the challenge from the database
+	challenge, err := database.FindChallengeById(db, event.Id, &challengeObjectId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting challenge from database: " + err.Error()})
+		return nil
+	}
+	if challenge == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "challenge not found"})
+		return nil
+	}
+	return challenge
+}
+
+// PUT /admin/challenges/:id - EditChallenge edits a challenge. Renaming a challenge renames it on its entrants.
+func EditChallenge(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the challenge
+	challenge := findChallengeFromParam(ctx)
+	if challenge == nil {
+		return
+	}
+	oldName := challenge.Name
+
+	// Get the new details of the challenge from the request
+	if !bindChallenge(ctx, challenge) {
+		return
+	}
+
+	// Save the challenge in the database
+	err := database.UpdateChallenge(db, challenge, oldName)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating challenge in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, challenge)
+}
+
+// DELETE /admin/challenges/:id - DeleteChallenge deletes a challenge that no project has entered
+func DeleteChallenge(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the challenge
+	challenge := findChallengeFromParam(ctx)
+	if challenge == nil {
+		return
+	}
+
+	// Make sure no project entered the challenge
+	count, err := database.CountChallengeEntrants(db, &challenge.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error counting challenge entrants: " + err.Error()})
+		return
+	}
+	if count > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "projects have entered this challenge, merge it into another challenge instead"})
+		return
+	}
+
+	// Delete the challenge from the database
+	err = database.DeleteChallenge(db, event.Id, &challenge.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error deleting challenge from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
+
+// GET /admin/challenges/:id/entrants - ListChallengeEntrants lists the projects that entered a challenge
+func ListChallengeEntrants(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the challenge
+	challenge := findChallengeFromParam(ctx)
+	if challenge == nil {
+		return
+	}
+
+	// Get the entrants from the database
+	projects, err := database.FindChallengeEntrants(db, &challenge.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting challenge entrants from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, projects)
+}
+
+// POST /admin/challenges/:id/merge - MergeChallenges merges other challenges into a challenge. Their names and
+// aliases become aliases of the challenge, and their entrants enter the challenge instead.
+func MergeChallenges(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the challenge to merge into
+	target := findChallengeFromParam(ctx)
+	if target == nil {
+		return
+	}
+
+	// Get the challenges to merge from the request
+	var mergeReq models.MergeChallengesRequest
+	err := ctx.BindJSON(&mergeReq)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+	challenges, err := database.FindChallenges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting challenges from database: " + err.Error()})
+		return
+	}
+	names := make(map[primitive.ObjectID]string, len(challenges))
+	byId := make(map[primitive.ObjectID]*models.Challenge, len(challenges))
+	for _, c := range challenges {
+		names[c.Id] = c.Name
+		byId[c.Id] = c
+	}
+	merged := make(map[primitive.ObjectID]bool)
+	mergedIds := make([]primitive.ObjectID, 0, len(mergeReq.Ids))
+	for _, id := range mergeReq.Ids {
+		objectId, err := primitive.ObjectIDFromHex(id)
+		if err != nil || byId[objectId] == nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid challenge ID: " + id})
+			return
+		}
+		if objectId == target.Id || merged[objectId] {
+			continue
+		}
+		merged[objectId] = true
+		mergedIds = append(mergedIds, objectId)
+
+		// Keep the merged challenge's names as aliases
+		target.Aliases = append(target.Aliases, byId[objectId].Name)
+		target.Aliases = append(target.Aliases, byId[objectId].Aliases...)
+	}
+	if len(mergedIds) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "no challenges to merge"})
+		return
+	}
+	target.Aliases = funcs.CanonicaliseChallengeList(nil, target.Aliases)
+
+	// Move the entrants of the merged challenges
+	var entrants []*models.Project
+	seen := make(map[primitive.ObjectID]bool)
+	for _, id := range mergedIds {
+		projects, err := database.FindChallengeEntrants(db, &id)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting challenge entrants from database: " + err.Error()})
+			return
+		}
+		for _, project := range projects {
+			if !seen[project.Id] {
+				seen[project.Id] = true
+				entrants = append(entrants, project)
+			}
+		}
+	}
+	changed := funcs.MergeChallengeEntries(entrants, target.Id, merged, names)
+
+	// Save the changes in the database
+	err = database.MergeChallenges(db, target, mergedIds, changed)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error merging challenges in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "challenge": target, "moved": len(changed)})
+}
+
+// POST /admin/challenges/sync - SyncChallenges creates a challenge for every challenge name entered by a project
+// and links each project to its challenges, e.g. for projects added before challenges existed
+func SyncChallenges(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the projects from the database
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+
+	// Link the projects to their challenges
+	if !linkChallenges(ctx, projects) {
+		return
+	}
+	err = database.UpdateProjectChallenges(db, context.Background(), projects)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project challenges in database: " + err.Error()})
+		return
+	}
+
+	// Send the challenges
+	ListChallenges(ctx)
+}
@@ -0,0 +1,279 @@
+package router
+
+import (
+	"net/http"
+	"sync"
+
+	"server/database"
+	"server/judging"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// eventState is the in-memory state kept for each event
+type eventState struct {
+	clock *models.ClockState
+	comps *judging.Comparisons
+}
+
+// eventStates lazily loads and caches the in-memory state of every event
+type eventStates struct {
+	db     *mongo.Database
+	mutex  sync.Mutex
+	states map[primitive.ObjectID]*eventState
+}
+
+func newEventStates(db *mongo.Database) *eventStates {
+	return &eventStates{
+		db:     db,
+		states: make(map[primitive.ObjectID]*eventState),
+	}
+}
+
+// get returns the state of an event, loading it from the database on first use
+func (s *eventStates) get(eventId primitive.ObjectID) (*eventState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if state, ok := s.states[eventId]; ok {
+		return state, nil
+	}
+
+	// Get the clock state from the database
+	clock, err := getClockFromDb(s.db, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the comparisons object
+	comps, err := judging.LoadComparisons(s.db, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &eventState{clock: clock, comps: comps}
+	s.states[eventId] = state
+	return state, nil
+}
+
+// forget drops the cached state of an event so that it is reloaded from the database on next use
+func (s *eventStates) forget(eventId primitive.ObjectID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.states, eventId)
+}
+
+// getClockFromDb gets the clock state of an event from the database
+// and on init will pause the clock
+// todo: fix the clock just pausing itself and jumping forward in time (see upstream - likely a concurrency issue)
+func getClockFromDb(db *mongo.Database, eventId primitive.ObjectID) (*models.ClockState, error) {
+	// Get the clock state from the database
+	options, err := database.GetOptions(db, eventId)
+	if err != nil {
+		return nil, err
+	}
+	clock := options.Clock
+
+	// Pause the clock
+	clock.Pause()
+
+	// Update the clock in the database
+	err = database.UpdateClock(db, eventId, &clock)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clock, nil
+}
+
+// UseEvent is a middleware that resolves the event from the :eventId path parameter and adds it,
+// along with its clock and comparisons, to the context
+func UseEvent(states *eventStates) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		eventObjectId, err := primitive.ObjectIDFromHex(ctx.Param("eventId"))
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+			return
+		}
+
+		event, err := database.FindEventById(states.db, &eventObjectId)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error finding event in database: " + err.Error()})
+			return
+		}
+
+		setEvent(ctx, states, event)
+	}
+}
+
+// UseDefaultEvent is a middleware that adds the default event, along with its clock and comparisons, to the context
+func UseDefaultEvent(states *eventStates) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		event, err := database.FindDefaultEvent(states.db)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error finding default event in database: " + err.Error()})
+			return
+		}
+
+		setEvent(ctx, states, event)
+	}
+}
+
+func setEvent(ctx *gin.Context, states *eventStates, event *models.Event) {
+	if event == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "event not found"})
+		return
+	}
+
+	state, err := states.get(event.Id)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error loading event state: " + err.Error()})
+		return
+	}
+
+	ctx.Set("event", event)
+	ctx.Set("clock", state.clock)
+	ctx.Set("comps", state.comps)
+	ctx.Next()
+}
+
+// GET /events - ListEvents lists all events
+func ListEvents(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the events from the database
+	events, err := database.FindAllEvents(db)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting events from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, events)
+}
+
+type EventRequest struct {
+	Name     string `json:"name"`
+	Archived bool   `json:"archived"`
+}
+
+// POST /events - CreateEvent creates a new, empty event
+func CreateEvent(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event details from the request
+	var eventReq EventRequest
+	err := ctx.BindJSON(&eventReq)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+	if eventReq.Name == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "event name is required"})
+		return
+	}
+
+	// Insert the event
+	event := models.NewEvent(eventReq.Name)
+	err = database.InsertEvent(db, event)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error inserting event into database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, event)
+}
+
+// PUT /events/:eventId - EditEvent renames and archives/unarchives an event
+func EditEvent(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event details from the request
+	var eventReq EventRequest
+	err := ctx.BindJSON(&eventReq)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+	if eventReq.Name == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "event name is required"})
+		return
+	}
+
+	// Convert event ID string to ObjectID
+	eventObjectId, err := primitive.ObjectIDFromHex(ctx.Param("eventId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+		return
+	}
+
+	// The default event must stay live
+	event, err := database.FindEventById(db, &eventObjectId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error finding event in database: " + err.Error()})
+		return
+	}
+	if event == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+		return
+	}
+	if event.Default && eventReq.Archived {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "the default event cannot be archived"})
+		return
+	}
+
+	// Update the event
+	err = database.UpdateEventDetails(db, &eventObjectId, eventReq.Name, eventReq.Archived)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating event in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
+
+// POST /events/:eventId/default - SetDefaultEvent makes an event the one served by the non-namespaced routes
+func SetDefaultEvent(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Convert event ID string to ObjectID
+	eventObjectId, err := primitive.ObjectIDFromHex(ctx.Param("eventId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+		return
+	}
+
+	// Archived events can't be judged, so they can't be the default either
+	event, err := database.FindEventById(db, &eventObjectId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error finding event in database: " + err.Error()})
+		return
+	}
+	if event == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+		return
+	}
+	if event.Archived {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "an archived event cannot be the default event"})
+		return
+	}
+
+	// Update the events
+	err = database.SetDefaultEvent(db, &eventObjectId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating events in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
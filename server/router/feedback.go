@@ -0,0 +1,260 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"server/database"
+	"server/funcs"
+	"server/models"
+	"server/util"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GET /admin/feedback - ListFeedback lists the notes judges have shared with teams. Pass ?status= to only list
+// pending, approved or rejected notes.
+func ListFeedback(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the judges and projects from the database
+	judges, err := database.FindAllJudges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judges from database: " + err.Error()})
+		return
+	}
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, funcs.ListFeedback(judges, projects, ctx.Query("status")))
+}
+
+// PUT /admin/feedback/:judge/:project - ModerateFeedback approves or rejects a judge's shared notes on a project,
+// optionally editing the text that the team will see
+func ModerateFeedback(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Convert the IDs to ObjectIDs
+	judgeId, err := primitive.ObjectIDFromHex(ctx.Param("judge"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid judge ID"})
+		return
+	}
+	projectId, err := primitive.ObjectIDFromHex(ctx.Param("project"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	// Get the request
+	var req models.ModerateFeedbackRequest
+	err = ctx.BindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+	if req.Status != models.FeedbackPending && req.Status != models.FeedbackApproved && req.Status != models.FeedbackRejected {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid feedback status: " + req.Status})
+		return
+	}
+	if req.Feedback != nil {
+		trimmed := strings.TrimSpace(*req.Feedback)
+		req.Feedback = &trimmed
+		if trimmed == "" && req.Status == models.FeedbackApproved {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "approved feedback can't be empty"})
+			return
+		}
+	}
+
+	// Save the moderation in the database
+	found, err := database.ModerateFeedback(db, event.Id, &judgeId, &projectId, req.Status, req.Feedback)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating feedback in database: " + err.Error()})
+		return
+	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "judge has not shared notes on this project"})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
+
+// feedbackLinks lists the feedback tokens of the projects that have one
+func feedbackLinks(projects []*models.Project) []models.FeedbackLink {
+	links := make([]models.FeedbackLink, 0, len(projects))
+	for _, project := range projects {
+		if project.FeedbackToken != "" {
+			links = append(links, models.FeedbackLink{
+				ProjectId: project.Id,
+				Name:      project.Name,
+				Guild:     project.Guild,
+				Location:  project.Location,
+				Token:     project.FeedbackToken,
+			})
+		}
+	}
+	return links
+}
+
+// GET /admin/feedback/links - ListFeedbackLinks lists the feedback tokens of the projects, to send to the teams
+func ListFeedbackLinks(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the projects from the database
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, feedbackLinks(projects))
+}
+
+// POST /admin/feedback/links - CreateFeedbackLinks gives every project without a feedback token a new one.
+// Existing tokens are kept so that links already sent to teams keep working.
+func CreateFeedbackLinks(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the projects from the database
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+
+	// Generate the missing tokens
+	changed := make([]*models.Project, 0)
+	for _, project := range projects {
+		if project.FeedbackToken != "" {
+			continue
+		}
+		project.FeedbackToken, err = util.NewToken()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error generating feedback token: " + err.Error()})
+			return
+		}
+		changed = append(changed, project)
+	}
+
+	// Save the tokens in the database
+	err = database.SetProjectFeedbackTokens(db, changed)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving feedback tokens in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "created": len(changed), "links": feedbackLinks(projects)})
+}
+
+type ReleaseFeedbackRequest struct {
+	Released bool `json:"released"`
+}
+
+// POST /admin/feedback/release - ReleaseFeedback lets teams see their feedback, or hides it again.
+// Feedback can only be released once judging has ended.
+func ReleaseFeedback(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the request
+	var req ReleaseFeedbackRequest
+	err := ctx.BindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+
+	// Make sure judging has ended
+	if req.Released {
+		judgingEnded, err := database.GetJudgingEnded(db, event.Id)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judging_ended flag: " + err.Error()})
+			return
+		}
+		if !judgingEnded {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "feedback can't be released until judging has ended"})
+			return
+		}
+	}
+
+	// Save the flag in the database
+	err = database.SetFeedbackReleased(db, event.Id, req.Released)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating options in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
+
+// GET /feedback/:token - GetProjectFeedback returns the anonymised feedback of the project with the token:
+// its average category scores and the judges' approved notes
+func GetProjectFeedback(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Make sure feedback has been released
+	options, err := database.GetOptions(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting options from database: " + err.Error()})
+		return
+	}
+	if !options.FeedbackReleased || !options.JudgingEnded {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "feedback has not been released yet"})
+		return
+	}
+
+	// Get the project with the token
+	project, err := database.FindProjectByFeedbackToken(db, event.Id, ctx.Param("token"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting project from database: " + err.Error()})
+		return
+	}
+	if project == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "invalid feedback link"})
+		return
+	}
+
+	// Get the judges from the database
+	judges, err := database.FindAllJudges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judges from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, funcs.BuildProjectFeedback(project, judges, options.Categories))
+}
@@ -0,0 +1,327 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"server/database"
+	"server/funcs"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// canonicaliseGuildNames changes the guild names of projects to the names of the existing guilds they match,
+// so that differences in case or spacing aren't treated as different guilds
+func canonicaliseGuildNames(guilds []*models.Guild, projects []*models.Project) {
+	for _, project := range projects {
+		if guild := models.FindGuildByName(guilds, project.Guild); guild != nil {
+			project.Guild = guild.Name
+		}
+	}
+}
+
+// linkGuilds points projects at the guilds named by their guild field, creating any guilds that don't exist yet.
+// Sends an error response and returns false if it can't.
+func linkGuilds(ctx *gin.Context, projects []*models.Project) bool {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	guilds, err := database.FindGuilds(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting guilds from database: " + err.Error()})
+		return false
+	}
+
+	for _, project := range projects {
+		project.Guild = strings.TrimSpace(project.Guild)
+		if project.Guild == "" {
+			project.GuildId = nil
+			continue
+		}
+
+		// Create the guild if it doesn't exist
+		guild := models.FindGuildByName(guilds, project.Guild)
+		if guild == nil {
+			guild = models.NewGuild(project.Guild)
+			guild.Order = len(guilds)
+			err = database.InsertGuild(db, event.Id, guild)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error inserting guild into database: " + err.Error()})
+				return false
+			}
+			guilds = append(guilds, guild)
+		}
+
+		project.Guild = guild.Name
+		project.GuildId = &guild.Id
+	}
+	return true
+}
+
+// GET /admin/guilds - ListGuilds lists the guilds of the event in display order
+func ListGuilds(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the guilds from the database
+	guilds, err := database.FindGuilds(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting guilds from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, guilds)
+}
+
+// bindGuild reads the details of a guild from the request and validates them against the other guilds of the
+// event, sending an error response if they are invalid
+func bindGuild(ctx *gin.Context, guild *models.Guild) bool {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the guild from the request
+	var guildReq models.GuildRequest
+	err := ctx.BindJSON(&guildReq)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return false
+	}
+	guild.Name, guild.Area, guild.Colour, guild.Order = strings.TrimSpace(guildReq.Name), guildReq.Area, guildReq.Colour, guildReq.Order
+
+	// Validate the guild
+	guilds, err := database.FindGuilds(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting guilds from database: " + err.Error()})
+		return false
+	}
+	err = guild.Validate(guilds)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid guild: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// POST /admin/guilds - CreateGuild creates a guild
+func CreateGuild(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the guild from the request
+	guild := &models.Guild{}
+	if !bindGuild(ctx, guild) {
+		return
+	}
+
+	// Insert the guild into the database
+	err := database.InsertGuild(db, event.Id, guild)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error inserting guild into database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, guild)
+}
+
+// findGuildFromParam gets the guild of the event with the ID in the URL, sending an error response if there is none
+func findGuildFromParam(ctx *gin.Context) *models.Guild {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Convert guild ID string to ObjectID
+	guildObjectId, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid guild ID"})
+		return nil
+	}
+
+	// Get the guild from the database
+	guild, err := database.FindGuildById(db, event.Id, &guildObjectId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting guild from database: " + err.Error()})
+		return nil
+	}
+	if guild == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "guild not found"})
+		return nil
+	}
+	return guild
+}
+
+// PUT /admin/guilds/:id - EditGuild edits a guild. Renaming a guild renames it on its projects and table zones.
+func EditGuild(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the guild
+	guild := findGuildFromParam(ctx)
+	if guild == nil {
+		return
+	}
+	oldName := guild.Name
+
+	// Get the new details of the guild from the request
+	if !bindGuild(ctx, guild) {
+		return
+	}
+
+	// Save the guild in the database
+	err := database.UpdateGuild(db, guild, oldName)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating guild in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, guild)
+}
+
+// DELETE /admin/guilds/:id - DeleteGuild deletes a guild that has no projects
+func DeleteGuild(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the guild
+	guild := findGuildFromParam(ctx)
+	if guild == nil {
+		return
+	}
+
+	// Make sure the guild has no projects
+	count, err := database.CountGuildProjects(db, &guild.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error counting guild projects: " + err.Error()})
+		return
+	}
+	if count > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "guild still has projects, move or delete them first"})
+		return
+	}
+
+	// Delete the guild from the database
+	err = database.DeleteGuild(db, event.Id, &guild.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error deleting guild from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
+
+// POST /admin/guilds/sync - SyncGuilds creates a guild for every guild name used by a project and links each
+// project to its guild, e.g. for projects added before guilds existed
+func SyncGuilds(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the projects from the database
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+
+	// Link the projects to their guilds
+	if !linkGuilds(ctx, projects) {
+		return
+	}
+	err = database.UpdateProjectGuildIds(db, projects)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project guilds in database: " + err.Error()})
+		return
+	}
+
+	// Send the guilds
+	ListGuilds(ctx)
+}
+
+// GET /admin/guilds/stats - GuildStats returns the judging progress of each guild
+func GuildStats(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the guilds, projects and flags from the database
+	guilds, err := database.FindGuilds(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting guilds from database: " + err.Error()})
+		return
+	}
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+	flags, err := database.FindAllFlags(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting flags from database: " + err.Error()})
+		return
+	}
+	var busyProjects []*primitive.ObjectID
+	err = database.WithTransaction(db, func(sc mongo.SessionContext) (interface{}, error) {
+		busyProjects, err = database.FindBusyProjects(db, sc, event.Id)
+		return nil, err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting busy projects from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, funcs.AggregateGuildStats(guilds, projects, flags, busyProjects))
+}
+
+type HideGuildRequest struct {
+	Hide bool `json:"hide"`
+}
+
+// POST /admin/guilds/:id/hide - HideGuildProjects hides or unhides every project in a guild,
+// e.g. when its room closes early
+func HideGuildProjects(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the guild
+	guild := findGuildFromParam(ctx)
+	if guild == nil {
+		return
+	}
+
+	// Get the request
+	var req HideGuildRequest
+	err := ctx.BindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+
+	// Update the projects in the database
+	changed, err := database.SetGuildProjectsHidden(db, &guild.Id, req.Hide)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating projects in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "changed": changed})
+}
@@ -2,11 +2,13 @@ package router
 
 import (
 	"log"
-	"net/url"
+	"net/http"
+	"strings"
 
+	"server/auth"
 	"server/config"
 	"server/database"
-	"server/judging"
+	"server/mail"
 	"server/models"
 
 	"github.com/gin-contrib/cors"
@@ -28,24 +30,59 @@ func NewRouter(db *mongo.Database) *gin.Engine {
 		log.Fatalf("error setting gin router's trusted proxies: %s\n", err.Error())
 	}
 
-	// Get the clock state from the database
-	clock := getClockFromDb(db)
+	// Load the clock and comparisons of every event up front
+	states := newEventStates(db)
+	events, err := database.FindAllEvents(db)
+	if err != nil {
+		log.Fatalf("error loading events from the database: %s\n", err.Error())
+	}
+	for _, event := range events {
+		_, err = states.get(event.Id)
+		if err != nil {
+			log.Fatalf("error loading event %s from the database: %s\n", event.Id.Hex(), err.Error())
+		}
+	}
 
-	// Create the comparisons object
-	comps, err := judging.LoadComparisons(db)
+	// Set up sending emails
+	mailer, err := mail.NewTransportFromEnv()
 	if err != nil {
-		log.Fatalf("error loading projects from the database: %s\n", err.Error())
+		log.Fatalf("error setting up email: %s\n", err.Error())
 	}
 
+	// Set up the auth provider
+	authProvider, err := auth.NewProviderFromEnv()
+	if err != nil {
+		log.Fatalf("error setting up auth provider: %s\n", err.Error())
+	}
+
+	// Set up encrypting stored tokens, and encrypt any token sets left in plain text or with an old key
+	tokenCipher, err := tokenCipherFromEnv()
+	if err != nil {
+		log.Fatalf("error loading token encryption keys: %s\n", err.Error())
+	}
+	migrated, purged, err := migrateTokenSets(db, tokenCipher)
+	if err != nil {
+		log.Fatalf("error migrating token sets: %s\n", err.Error())
+	}
+	if migrated > 0 || purged > 0 {
+		log.Printf("encrypted %d token sets with the current key, deleted %d which couldn't be decrypted\n", migrated, purged)
+	}
+
+	// Keep the cached judge profiles up to date with the auth provider
+	startJudgeProfileSync(db, authProvider)
+
 	// Add shared variables to router
 	router.Use(useVar("db", db))
-	router.Use(useVar("clock", &clock))
-	router.Use(useVar("comps", comps))
+	router.Use(useVar("event_states", states))
+	router.Use(useVar("mailer", mailer))
+	router.Use(useVar("logins", auth.NewLoginCache()))
+	router.Use(useVar("auth_provider", authProvider))
+	router.Use(useVar("token_cipher", tokenCipher))
 
 	// CORS
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{config.Origin},
-		AllowMethods:     []string{"GET", "POST", "DELETE", "OPTIONS", "PUT"},
+		AllowMethods:     []string{"GET", "POST", "DELETE", "OPTIONS", "PUT", "PATCH"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Cache-Control", "X-Requested-With"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -53,26 +90,39 @@ func NewRouter(db *mongo.Database) *gin.Engine {
 	}))
 
 	// Sessions
-	sessionCollection := db.Collection("sessions")
-	store := sessionsMongodriver.NewStore(sessionCollection, 60*60, true, []byte("secret"))
-	parsedUrl, err := url.Parse(config.ApiOrigin)
+	sessionKeys, err := sessionKeysFromEnv()
 	if err != nil {
-		log.Fatalf("error parsing host url: %s\n", err.Error())
+		log.Fatalf("error loading session secrets: %s\n", err.Error())
 	}
-	store.Options(sessions.Options{
-		HttpOnly: true,
-		Secure:   false,
-		Domain:   parsedUrl.Host,
-		Path:     "/",
-	})
+	sessionOptions, err := sessionOptionsFromEnv()
+	if err != nil {
+		log.Fatalf("error loading session options: %s\n", err.Error())
+	}
+	store := sessionsMongodriver.NewStore(db.Collection("sessions"), sessionOptions.MaxAge, false, sessionKeys...)
+	store.Options(sessionOptions)
 	router.Use(sessions.Sessions("durhack-jury-session", store))
+	router.Use(useVar("session_options", sessionOptions))
+
+	// Expire sessions and their records once they are no longer valid
+	err = database.EnsureTTLIndex(db, "sessions", "modified", int32(sessionOptions.MaxAge))
+	if err != nil {
+		log.Fatalf("error creating sessions index: %s\n", err.Error())
+	}
+	err = database.EnsureTTLIndex(db, "user_sessions", "expires_at", 0)
+	if err != nil {
+		log.Fatalf("error creating user sessions index: %s\n", err.Error())
+	}
+	err = database.EnsureTTLIndex(db, "token_set", "expires_at", 0)
+	if err != nil {
+		log.Fatalf("error creating token sets index: %s\n", err.Error())
+	}
+	err = database.EnsureApiTokenIndexes(db)
+	if err != nil {
+		log.Fatalf("error creating API tokens indexes: %s\n", err.Error())
+	}
 
-	// Create router groups for judge and admins
 	// todo: document routing behaviour r.e. login and auth
 	authenticatedRouter := router.Group("", Authenticate())
-	// todo: maybe add an additional role for volunteers who can sign-out groups when hackers leave or update a group's location
-	judgeRouter := authenticatedRouter.Group("/api", AuthoriseJudge())
-	adminRouter := authenticatedRouter.Group("/api", AuthoriseAdmin())
 	defaultRouter := router.Group("/api")
 
 	// todo: improved error handling middleware: https://stackoverflow.com/questions/69948784/how-to-handle-errors-in-gin-middleware/69948929#69948929
@@ -82,14 +132,80 @@ func NewRouter(db *mongo.Database) *gin.Engine {
 	defaultRouter.GET("/auth/keycloak/callback", KeycloakOAuth2FlowCallback(), HandleLoginSuccess())
 	authenticatedRouter.GET("/api/auth/keycloak/logout", Logout())
 
+	// The local provider serves its own login form, which sends users back to the same callback as keycloak
+	if localProvider, ok := authProvider.(*auth.LocalProvider); ok {
+		defaultRouter.GET("/auth/local/login", LocalLoginForm())
+		defaultRouter.POST("/auth/local/login", LocalLogin(localProvider))
+	}
+
+	// Session management routes
+	sessionsRouter := authenticatedRouter.Group("/api", AuthoriseAdmin())
+	sessionsRouter.GET("/sessions", ListSessions)
+	sessionsRouter.DELETE("/sessions/:id", RevokeSession)
+	sessionsRouter.DELETE("/users/:userId/sessions", RevokeUserSessions)
+
+	// API token routes, which can only be used by admins who are logged in
+	tokensRouter := authenticatedRouter.Group("/api/tokens", AuthoriseAdmin())
+	tokensRouter.GET("", ListApiTokens)
+	tokensRouter.POST("", CreateApiToken)
+	tokensRouter.DELETE("/:id", RevokeApiToken)
+
+	// Event management routes
+	eventsRouter := authenticatedRouter.Group("/api/events", AuthoriseAdmin())
+	eventsRouter.GET("", ListEvents)
+	eventsRouter.POST("", CreateEvent)
+	eventsRouter.PUT("/:eventId", EditEvent)
+	eventsRouter.POST("/:eventId/default", SetDefaultEvent)
+
+	// Event routes are served for a specific event under /api/events/:eventId,
+	// and for the default event directly under /api
+	addEventRoutes(router.Group("/api/events/:eventId", UseEvent(states)))
+	addEventRoutes(router.Group("/api", UseDefaultEvent(states)))
+
+	// Serve frontend static files
+	router.Use(static.Serve("/assets", static.LocalFile("./public/assets", true)))
+	router.StaticFile("/favicon.ico", "./public/favicon.ico")
+	router.LoadHTMLFiles("./public/index.html")
+
+	// Add no route handler
+	router.NoRoute(func(ctx *gin.Context) {
+		// API requests always get JSON back, as the client can't do anything with the page
+		if ctx.Request.URL.Path == "/api" || strings.HasPrefix(ctx.Request.URL.Path, "/api/") {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "no such endpoint: " + ctx.Request.Method + " " + ctx.Request.URL.Path})
+			return
+		}
+		ctx.HTML(200, "index.html", nil)
+	})
+
+	return router
+}
+
+// addEventRoutes adds all routes that act on a single event to a router group which resolves that event
+func addEventRoutes(eventRouter *gin.RouterGroup) {
+	// Create router groups for judge and admins
+	authenticatedRouter := eventRouter.Group("", Authenticate())
+	judgeRouter := authenticatedRouter.Group("", AuthoriseJudge())
+	adminRouter := authenticatedRouter.Group("", AuthoriseAdmin())
+	// Admin routes which scripts can also use with an API token that has the scope
+	projectsReadRouter := authenticatedRouter.Group("", AuthoriseAdmin(models.ScopeProjectsRead))
+	projectsWriteRouter := authenticatedRouter.Group("", AuthoriseAdmin(models.ScopeProjectsWrite))
+	judgesReadRouter := authenticatedRouter.Group("", AuthoriseAdmin(models.ScopeJudgesRead))
+	judgesWriteRouter := authenticatedRouter.Group("", AuthoriseAdmin(models.ScopeJudgesWrite))
+	exportRouter := authenticatedRouter.Group("", AuthoriseAdmin(models.ScopeExportRead))
+	importRouter := authenticatedRouter.Group("", AuthoriseAdmin(models.ScopeImportWrite))
+	volunteerRouter := authenticatedRouter.Group("/volunteer", AuthoriseVolunteer())
+	defaultRouter := eventRouter
+
 	// Add routes
 	judgeRouter.GET("/judge", GetJudge)
 	judgeRouter.POST("/judge/auth", JudgeAuthenticated)
 	judgeRouter.GET("/judge/welcome", CheckJudgeReadWelcome)
 	judgeRouter.POST("/judge/welcome", SetJudgeReadWelcome)
-	adminRouter.GET("/judge/list", ListJudges)
-	adminRouter.GET("/judge/stats", JudgeStats)
-	adminRouter.DELETE("/judge/:id", DeleteJudge)
+	judgesReadRouter.GET("/judge/list", ListJudges)
+	judgesReadRouter.GET("/judge/stats", JudgeStats)
+	judgesWriteRouter.DELETE("/judge/:id", DeleteJudge)
+	judgesWriteRouter.POST("/judge/csv", AddJudgesCsv)
+	judgesWriteRouter.POST("/judge/profiles/sync", SyncJudgeProfiles)
 	judgeRouter.GET("/judge/projects", GetJudgeProjects)
 	judgeRouter.POST("/judge/next", GetNextJudgeProject)
 	judgeRouter.POST("/judge/skip", JudgeSkip)
@@ -99,16 +215,24 @@ func NewRouter(db *mongo.Database) *gin.Engine {
 	judgeRouter.PUT("/judge/score", JudgeUpdateScore)
 	judgeRouter.POST("/judge/break", JudgeBreak)
 
-	adminRouter.POST("/project/devpost", AddDevpostCsv)
-	adminRouter.POST("/project/new", AddProject)
-	adminRouter.GET("/project/list", ListProjects)
+	projectsWriteRouter.POST("/project/devpost", AddDevpostCsv)
+	projectsWriteRouter.POST("/project/devpost/preview", PreviewDevpostCsv)
+	projectsReadRouter.GET("/admin/devpost-columns", GetDevpostColumns)
+	projectsWriteRouter.POST("/admin/devpost-columns", SetDevpostColumns)
+	projectsWriteRouter.POST("/project/new", AddProject)
+	projectsReadRouter.GET("/project/list", ListProjects)
 	defaultRouter.GET("/project/list/public", ListPublicProjects)
-	adminRouter.POST("/project/csv", AddProjectsCsv)
+	defaultRouter.GET("/results/public", GetPublicResults)
+	defaultRouter.GET("/feedback/:token", GetProjectFeedback)
+	projectsWriteRouter.POST("/project/csv", AddProjectsCsv)
+	projectsReadRouter.GET("/project/csv/template", GetProjectCsvTemplate)
 	judgeRouter.GET("/project/:id", GetProject)
 	judgeRouter.GET("/project/count", GetProjectCount)
 	judgeRouter.GET("/judge/project/:id", GetJudgedProject)
-	adminRouter.DELETE("/project/:id", DeleteProject)
-	adminRouter.GET("/project/stats", ProjectStats)
+	projectsWriteRouter.DELETE("/project/:id", DeleteProject)
+	projectsWriteRouter.PATCH("/project/:id", EditProject)
+	projectsReadRouter.GET("/project/:id/history", GetProjectHistory)
+	projectsReadRouter.GET("/project/stats", ProjectStats)
 
 	adminRouter.GET("/admin/stats", GetAdminStats)
 	adminRouter.GET("/admin/score", GetScores)
@@ -118,21 +242,63 @@ func NewRouter(db *mongo.Database) *gin.Engine {
 	adminRouter.POST("/admin/clock/reset", ResetClock)
 	adminRouter.POST("/admin/auth", AdminAuthenticated)
 	adminRouter.POST("/admin/reset", ResetDatabase)
-	adminRouter.POST("/judge/hide", HideJudge)
-	adminRouter.POST("/judge/unhide", UnhideJudge)
-	adminRouter.POST("/project/hide", HideProject)
-	adminRouter.POST("/project/hide-unhide-many", HideUnhideManyProjects)
-	adminRouter.POST("/project/unhide", UnhideProject)
-	adminRouter.POST("/project/prioritize", PrioritizeProject)
-	adminRouter.POST("/project/unprioritize", UnprioritizeProject)
-	adminRouter.POST("/project/update-location", UpdateProjectLocation) // should really be a PATCH I think :(
-	adminRouter.PUT("/judge/:id", EditJudge)
+	adminRouter.GET("/admin/snapshots", ListSnapshots)
+	adminRouter.POST("/admin/snapshots", CreateSnapshot)
+	adminRouter.GET("/admin/snapshots/:id", DownloadSnapshot)
+	adminRouter.POST("/admin/snapshots/:id/restore", RestoreSnapshot)
+	judgesWriteRouter.POST("/judge/hide", HideJudge)
+	judgesWriteRouter.POST("/judge/unhide", UnhideJudge)
+	projectsWriteRouter.POST("/project/hide", HideProject)
+	projectsWriteRouter.POST("/project/hide-unhide-many", HideUnhideManyProjects)
+	projectsWriteRouter.POST("/project/unhide", UnhideProject)
+	projectsWriteRouter.POST("/project/prioritize", PrioritizeProject)
+	projectsWriteRouter.POST("/project/unprioritize", UnprioritizeProject)
+	projectsReadRouter.GET("/project/priority-queue", GetPriorityQueue)
+	projectsReadRouter.GET("/project/tables/collisions", GetTableCollisions)
+	projectsWriteRouter.POST("/project/tables/assign", AssignTables)
+	projectsWriteRouter.POST("/project/tables/renumber", RenumberTables)
+	adminRouter.GET("/admin/guilds", ListGuilds)
+	adminRouter.POST("/admin/guilds", CreateGuild)
+	adminRouter.GET("/admin/guilds/stats", GuildStats)
+	adminRouter.POST("/admin/guilds/sync", SyncGuilds)
+	adminRouter.PUT("/admin/guilds/:id", EditGuild)
+	adminRouter.DELETE("/admin/guilds/:id", DeleteGuild)
+	adminRouter.POST("/admin/guilds/:id/hide", HideGuildProjects)
+	adminRouter.GET("/admin/challenges", ListChallenges)
+	adminRouter.POST("/admin/challenges", CreateChallenge)
+	adminRouter.POST("/admin/challenges/sync", SyncChallenges)
+	adminRouter.PUT("/admin/challenges/:id", EditChallenge)
+	adminRouter.DELETE("/admin/challenges/:id", DeleteChallenge)
+	adminRouter.GET("/admin/challenges/:id/entrants", ListChallengeEntrants)
+	adminRouter.POST("/admin/challenges/:id/merge", MergeChallenges)
+	adminRouter.GET("/admin/zones", ListTableZones)
+	adminRouter.POST("/admin/zones", CreateTableZone)
+	adminRouter.PUT("/admin/zones/:id", EditTableZone)
+	adminRouter.DELETE("/admin/zones/:id", DeleteTableZone)
+	adminRouter.GET("/admin/results", GetResults)
+	adminRouter.POST("/admin/results", GenerateResults)
+	adminRouter.POST("/admin/results/publish", PublishResults)
+	adminRouter.POST("/admin/results/reveal/next", RevealNextResult)
+	adminRouter.POST("/admin/results/reveal/previous", HidePreviousResult)
+	adminRouter.POST("/admin/results/reveal/all", RevealAllResults)
+	adminRouter.POST("/admin/results/reveal/reset", ResetResultsReveal)
+	adminRouter.POST("/admin/mail/judge-welcome", SendJudgeWelcome)
+	adminRouter.GET("/admin/feedback", ListFeedback)
+	adminRouter.GET("/admin/feedback/links", ListFeedbackLinks)
+	adminRouter.POST("/admin/feedback/links", CreateFeedbackLinks)
+	adminRouter.POST("/admin/feedback/links/email", EmailFeedbackLinks)
+	adminRouter.POST("/admin/feedback/release", ReleaseFeedback)
+	adminRouter.PUT("/admin/feedback/:judge/:project", ModerateFeedback)
+	projectsWriteRouter.POST("/project/update-location", UpdateProjectLocation) // should really be a PATCH I think :(
+	judgesWriteRouter.PUT("/judge/:id", EditJudge)
 	defaultRouter.GET("/admin/started", IsClockPaused)
 	adminRouter.GET("/admin/flags", GetFlags)
 	adminRouter.GET("/admin/options", GetOptions)
-	adminRouter.GET("/admin/export/projects", ExportProjects)
-	adminRouter.GET("/admin/export/challenges", ExportProjectsByChallenge)
-	adminRouter.GET("/admin/export/rankings", ExportRankings)
+	exportRouter.GET("/admin/export/projects", ExportProjects)
+	exportRouter.GET("/admin/export/challenges", ExportProjectsByChallenge)
+	exportRouter.GET("/admin/export/rankings", ExportRankings)
+	exportRouter.GET("/admin/export/event", ExportEvent)
+	importRouter.POST("/admin/import/event", ImportEvent)
 	judgeRouter.GET("/admin/timer", GetJudgingTimer)
 	adminRouter.POST("/admin/timer", SetJudgingTimer)
 	adminRouter.POST("/admin/min-views", SetMinViews)
@@ -147,17 +313,12 @@ func NewRouter(db *mongo.Database) *gin.Engine {
 	defaultRouter.GET("/check-judging-over", isJudgingEnded)
 	adminRouter.POST("/admin/end-judging", endJudging)
 
-	// Serve frontend static files
-	router.Use(static.Serve("/assets", static.LocalFile("./public/assets", true)))
-	router.StaticFile("/favicon.ico", "./public/favicon.ico")
-	router.LoadHTMLFiles("./public/index.html")
-
-	// Add no route handler
-	router.NoRoute(func(ctx *gin.Context) {
-		ctx.HTML(200, "index.html", nil)
-	})
-
-	return router
+	volunteerRouter.POST("/auth", VolunteerAuthenticated)
+	volunteerRouter.GET("/projects", ListProjects)
+	volunteerRouter.POST("/project/:id/status", SetProjectStatus)
+	volunteerRouter.PUT("/project/:id/location", MoveProject)
+	volunteerRouter.GET("/flags", ListAbsentFlags)
+	volunteerRouter.POST("/flags/:id/resolve", ResolveAbsentFlag)
 }
 
 // useVar is a middleware that adds a variable to the context
@@ -167,26 +328,3 @@ func useVar(key string, v any) gin.HandlerFunc {
 		ctx.Next()
 	}
 }
-
-// getClockFromDb gets the clock state from the database
-// and on init will pause the clock
-// todo: fix the clock just pausing itself and jumping forward in time (see upstream - likely a concurrency issue)
-func getClockFromDb(db *mongo.Database) models.ClockState {
-	// Get the clock state from the database
-	options, err := database.GetOptions(db)
-	if err != nil {
-		log.Fatalln("error getting options: " + err.Error())
-	}
-	clock := options.Clock
-
-	// Pause the clock
-	clock.Pause()
-
-	// Update the clock in the database
-	err = database.UpdateClock(db, &clock)
-	if err != nil {
-		log.Fatalln("error updating clock: " + err.Error())
-	}
-
-	return clock
-}
@@ -1,16 +1,12 @@
 package router
 
 import (
-	"context"
 	"encoding/json"
-	"github.com/Nerzal/gocloak/v13"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/sync/errgroup"
 	"net/http"
 	"server/auth"
-	"server/config"
 	"server/database"
 	"server/judging"
 	"server/models"
@@ -99,72 +95,38 @@ func ListJudges(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get judges from database
-	judges, err := database.FindAllJudges(db)
+	judges, err := database.FindAllJudges(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error finding judges in database: " + err.Error()})
 		return
 	}
 
-	keycloakAdminClient := auth.KeycloakAdminClient
-
+	// Add the names from each judge's cached profile, so that listing judges doesn't depend on keycloak
 	judgesWithKeycloak := make([]*judgeWithKeycloak, len(judges))
-	errGroup, _ := errgroup.WithContext(context.Background())
 	for i, judge := range judges {
-		errGroup.Go(func() error {
-			judgeKeycloakInfo, err := getJudgeKeycloakInfo(keycloakAdminClient, judge.KeycloakUserId)
-			if err != nil {
-				return err
-			}
-			judgesWithKeycloak[i] = &judgeWithKeycloak{
-				*judge,
-				judgeKeycloakInfo.PreferredNames,
-				judgeKeycloakInfo.FirstNames,
-				judgeKeycloakInfo.LastNames,
-			}
-			return nil
-		})
-	}
-	err = errGroup.Wait()
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judge info from keycloak: " + err.Error()})
-		return
+		judgesWithKeycloak[i] = &judgeWithKeycloak{
+			*judge,
+			judge.Profile.PreferredNames,
+			judge.Profile.FirstNames,
+			judge.Profile.LastNames,
+		}
 	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, judgesWithKeycloak)
 }
 
-type judgeKeycloak struct {
-	PreferredNames *string
-	FirstNames     string
-	LastNames      string
-}
-
-func getJudgeKeycloakInfo(adminClient *gocloak.GoCloak, userID string) (*judgeKeycloak, error) {
-	accessToken, err := auth.GetKeycloakAdminClientAccessToken(context.Background())
-	if err != nil {
-		return nil, err
-	}
-
-	user, err := adminClient.GetUserByID(context.Background(), *accessToken, config.KeycloakRealm, userID)
-	if err != nil {
-		return nil, err
+// getJudgeNames gets the full name of every judge from their cached profile
+func getJudgeNames(judges []*models.Judge) map[primitive.ObjectID]string {
+	judgeNames := make(map[primitive.ObjectID]string, len(judges))
+	for _, judge := range judges {
+		judgeNames[judge.Id] = judge.Profile.FullName()
 	}
-	userAttributes := *user.Attributes
-	preferredNamesAttribute, preferredNamesAttributeExist := userAttributes["preferredNames"]
-	var preferredNames *string
-	if preferredNamesAttributeExist {
-		preferredNames = &preferredNamesAttribute[0]
-	} else {
-		preferredNames = nil
-	}
-	attributes := judgeKeycloak{
-		preferredNames,
-		userAttributes["firstNames"][0],
-		userAttributes["lastNames"][0],
-	}
-	return &attributes, nil
+	return judgeNames
 }
 
 // GET /judge/stats - Endpoint to get stats about the judges
@@ -172,8 +134,11 @@ func JudgeStats(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Aggregate judge stats
-	stats, err := database.AggregateJudgeStats(db)
+	stats, err := database.AggregateJudgeStats(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error aggregating judge stats: " + err.Error()})
 		return
@@ -188,6 +153,9 @@ func DeleteJudge(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the judge ID from the URL
 	judgeId := ctx.Param("id")
 
@@ -199,11 +167,15 @@ func DeleteJudge(ctx *gin.Context) {
 	}
 
 	// Delete judge from database
-	err = database.DeleteJudgeById(db, judgeObjectId)
+	found, err := database.DeleteJudgeById(db, event.Id, judgeObjectId)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error deleting judge from database: " + err.Error()})
 		return
 	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "judge not found"})
+		return
+	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
@@ -214,8 +186,11 @@ func GetNextJudgeProject(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// If judging is over, return an empty object (prevents any picking of new projects)
-	judgingEnded, err := database.GetJudgingEnded(db)
+	judgingEnded, err := database.GetJudgingEnded(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judging_ended flag: " + err.Error()})
 		return
@@ -340,9 +315,6 @@ func JudgeSkip(ctx *gin.Context) {
 	// Get the judge from the context
 	judge := ctx.MustGet("judge").(*models.Judge)
 
-	// Get the user info from the context to save the judge name
-	judgeName := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo).GetNames()
-
 	// Get the comparisons object
 	comps := ctx.MustGet("comps").(*judging.Comparisons)
 
@@ -355,15 +327,18 @@ func JudgeSkip(ctx *gin.Context) {
 	}
 
 	// Skip the project
-	// todo: don't include judge name here, instead, on the admin side, get the judge name via keycloak using the keycloak user id
-	err = judging.SkipCurrentProject(db, judge, judgeName, comps, skipReq.Reason, true)
+	skippedId := judge.Current
+	err = judging.SkipCurrentProject(db, judge, comps, skipReq.Reason, true)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	// todo: automatically purge projects that are absent and/or notify project members
-	//  (using devpost's personal information email field) that they have been missed by a judge
-	//  and that they need to 're-activate' themselves - hide them in the meantime
+	// todo: automatically purge projects that are absent and/or ask them to 're-activate' themselves - hide them in the meantime
+
+	// Let the team know they were missed by a judge
+	if skipReq.Reason == "absent" && skippedId != nil {
+		notifyAbsentTeam(ctx, skippedId)
+	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
@@ -374,6 +349,9 @@ func HideJudge(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get ID from body
 	var idReq models.IdRequest
 	err := ctx.BindJSON(&idReq)
@@ -390,11 +368,15 @@ func HideJudge(ctx *gin.Context) {
 	}
 
 	// Hide judge in database
-	err = database.SetJudgeHidden(db, &judgeObjectId, true)
+	found, err := database.SetJudgeHidden(db, event.Id, &judgeObjectId, true)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error hiding judge in database: " + err.Error()})
 		return
 	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "judge not found"})
+		return
+	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
@@ -405,6 +387,9 @@ func UnhideJudge(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get ID from body
 	var idReq models.IdRequest
 	err := ctx.BindJSON(&idReq)
@@ -422,11 +407,15 @@ func UnhideJudge(ctx *gin.Context) {
 	}
 
 	// Unhide judge in database
-	err = database.SetJudgeHidden(db, &judgeObjectId, false)
+	found, err := database.SetJudgeHidden(db, event.Id, &judgeObjectId, false)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error unhiding judge in database: " + err.Error()})
 		return
 	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "judge not found"})
+		return
+	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
@@ -437,6 +426,9 @@ func EditJudge(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the body content
 	var judgeReq models.EditJudgeRequest
 	err := ctx.BindJSON(&judgeReq)
@@ -456,11 +448,15 @@ func EditJudge(ctx *gin.Context) {
 	}
 
 	// Edit judge in database
-	err = database.UpdateJudgeBasicInfo(db, &judgeObjectId, &judgeReq)
+	found, err := database.UpdateJudgeBasicInfo(db, event.Id, &judgeObjectId, &judgeReq)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error editing judge in database: " + err.Error()})
 		return
 	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "judge not found"})
+		return
+	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
@@ -547,6 +543,9 @@ func JudgeSubmitBatchRanking(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the judge from the context
 	judge := ctx.MustGet("judge").(*models.Judge)
 
@@ -559,12 +558,12 @@ func JudgeSubmitBatchRanking(ctx *gin.Context) {
 	}
 
 	// Validate batch ranking size
-	brs, err := database.GetBatchRankingSize(db)
+	brs, err := database.GetBatchRankingSize(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting batch ranking size: " + err.Error()})
 		return
 	}
-	judgingOver, err := database.GetJudgingEnded(db)
+	judgingOver, err := database.GetJudgingEnded(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judging_ended flag: " + err.Error()})
 		return
@@ -600,9 +599,6 @@ func JudgeBreak(ctx *gin.Context) {
 	// Get the judge from the context
 	judge := ctx.MustGet("judge").(*models.Judge)
 
-	// Get the user info from the context to save the judge name
-	judgeName := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo).GetNames()
-
 	// Get the comparisons from the context
 	comps := ctx.MustGet("comps").(*judging.Comparisons)
 
@@ -613,7 +609,7 @@ func JudgeBreak(ctx *gin.Context) {
 	}
 
 	// Basically skip the project for the judge
-	err := judging.SkipCurrentProject(db, judge, judgeName, comps, "break", false)
+	err := judging.SkipCurrentProject(db, judge, comps, "break", false)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error skipping project: " + err.Error()})
 		return
@@ -628,8 +624,11 @@ func GetCategories(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get categories from database
-	categories, err := database.GetCategories(db)
+	categories, err := database.GetCategories(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting categories: " + err.Error()})
 		return
@@ -644,8 +643,11 @@ func GetRankingBatchSize(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get ranking batch size from database
-	brs, err := database.GetBatchRankingSize(db)
+	brs, err := database.GetBatchRankingSize(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting ranking batch size: " + err.Error()})
 		return
@@ -715,9 +717,10 @@ func JudgeUpdateScore(ctx *gin.Context) {
 type UpdateNotesRequest struct {
 	Notes   string             `json:"notes"`
 	Project primitive.ObjectID `json:"project"`
+	Share   *bool              `json:"share"` // Whether the notes can be shared with the team, or unchanged if left out
 }
 
-// POST /judge/notes - Update the notes of a judge
+// POST /judge/notes - Update the notes of a judge and whether they are shared with the team
 func JudgeUpdateNotes(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
@@ -749,8 +752,12 @@ func JudgeUpdateNotes(ctx *gin.Context) {
 		return
 	}
 
-	// Update that specific index of the seen projects array
-	judge.SeenProjects[index].Notes = scoreReq.Notes
+	// Update that specific index of the seen projects array, sending shared notes back for moderation
+	share := judge.SeenProjects[index].ShareNotes
+	if scoreReq.Share != nil {
+		share = *scoreReq.Share
+	}
+	judge.SeenProjects[index].SetNotes(scoreReq.Notes, share)
 
 	// Update the judge's object for the project
 	err = database.UpdateJudgeSeenProjects(db, judge)
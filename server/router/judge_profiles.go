@@ -0,0 +1,94 @@
+package router
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"server/auth"
+	"server/config"
+	"server/database"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ProfileSyncFailure struct {
+	KeycloakUserId string `json:"keycloak_user_id"`
+	Error          string `json:"error"`
+}
+
+// syncJudgeProfiles refreshes the cached profile of every judge from the auth provider.
+// Judges whose profile can't be fetched keep their current profile.
+func syncJudgeProfiles(db *mongo.Database, authProvider auth.AuthProvider) (int64, []ProfileSyncFailure, error) {
+	userIds, err := database.FindJudgeKeycloakUserIds(db)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var updated int64
+	failed := make([]ProfileSyncFailure, 0)
+	for _, userId := range userIds {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		profile, err := authProvider.GetUserProfile(ctx, userId)
+		cancel()
+		if err != nil {
+			failed = append(failed, ProfileSyncFailure{userId, "error getting user: " + err.Error()})
+			continue
+		}
+		count, err := database.UpdateJudgeProfiles(db, userId, profile)
+		if err != nil {
+			failed = append(failed, ProfileSyncFailure{userId, "error updating judges in database: " + err.Error()})
+			continue
+		}
+		updated += count
+	}
+	return updated, failed, nil
+}
+
+// startJudgeProfileSync syncs the judge profiles in the background every JUDGE_PROFILE_SYNC_INTERVAL
+// (15 minutes by default). An interval of 0 turns the sync off.
+func startJudgeProfileSync(db *mongo.Database, authProvider auth.AuthProvider) {
+	intervalEnv := config.GetOptEnv("JUDGE_PROFILE_SYNC_INTERVAL", "")
+	if intervalEnv == "" {
+		intervalEnv = "15m"
+	}
+	interval, err := time.ParseDuration(intervalEnv)
+	if err != nil {
+		log.Fatalf("error parsing JUDGE_PROFILE_SYNC_INTERVAL: %s\n", err.Error())
+	}
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			updated, failed, err := syncJudgeProfiles(db, authProvider)
+			if err != nil {
+				log.Printf("error syncing judge profiles: %s\n", err.Error())
+				continue
+			}
+			if len(failed) > 0 {
+				log.Printf("synced %d judge profiles, %d users failed (first error: %s)\n", updated, len(failed), failed[0].Error)
+			}
+		}
+	}()
+}
+
+// POST /judge/profiles/sync - SyncJudgeProfiles refreshes the cached profile of every judge from the auth provider now
+func SyncJudgeProfiles(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Sync the profiles
+	updated, failed, err := syncJudgeProfiles(db, ctx.MustGet("auth_provider").(auth.AuthProvider))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judges from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "updated": updated, "failed": failed})
+}
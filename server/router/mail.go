@@ -0,0 +1,198 @@
+package router
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"server/config"
+	"server/database"
+	"server/mail"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mailTimeout is how long sending a single email may take
+const mailTimeout = 30 * time.Second
+
+// sendMail sends a message with the configured transport
+func sendMail(ctx *gin.Context, msg *mail.Message) error {
+	mailer := ctx.MustGet("mailer").(mail.Transport)
+	sendCtx, cancel := context.WithTimeout(ctx.Request.Context(), mailTimeout)
+	defer cancel()
+	return mailer.Send(sendCtx, msg)
+}
+
+// sendMailInBackground sends a message without holding up the request, logging it if it can't be sent
+func sendMailInBackground(ctx *gin.Context, msg *mail.Message) {
+	mailer := ctx.MustGet("mailer").(mail.Transport)
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := mailer.Send(sendCtx, msg); err != nil {
+			log.Printf("error sending email '%s': %s\n", msg.Subject, err.Error())
+		}
+	}()
+}
+
+// notifyAbsentTeam emails a project's team that a judge couldn't find them, if the team has contact emails
+func notifyAbsentTeam(ctx *gin.Context, projectId *primitive.ObjectID) {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	project, err := database.FindProjectById(db, projectId)
+	if err != nil {
+		log.Printf("error getting absent project %s from database: %s\n", projectId.Hex(), err.Error())
+		return
+	}
+	// The project may have been deleted, or belong to another event, since the judge skipped it
+	if project == nil || project.EventId != event.Id || len(project.ContactEmails) == 0 {
+		return
+	}
+
+	msg, err := mail.AbsentNotice(project.ContactEmails, mail.AbsentData{EventName: event.Name, ProjectName: project.Name, Location: project.Location})
+	if err != nil {
+		log.Printf("error creating absent notice: %s\n", err.Error())
+		return
+	}
+	sendMailInBackground(ctx, msg)
+}
+
+// judgeWelcome creates the welcome message with judging instructions for a judge
+func judgeWelcome(event *models.Event, email string, name string) (*mail.Message, error) {
+	return mail.JudgeWelcome([]string{email}, mail.JudgeWelcomeData{
+		EventName:  event.Name,
+		Name:       name,
+		JudgingUrl: config.Origin + "/judge",
+	})
+}
+
+// MailFailure is a recipient that an email couldn't be sent to
+type MailFailure struct {
+	Id    primitive.ObjectID `json:"id"`
+	Name  string             `json:"name"`
+	Error string             `json:"error"`
+}
+
+type JudgeWelcomeRequest struct {
+	JudgeIds []string `json:"judge_ids"` // Judges to send the welcome to, or every active judge if left out
+}
+
+// POST /admin/mail/judge-welcome - SendJudgeWelcome emails judges a welcome with judging instructions
+func SendJudgeWelcome(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the request
+	var req JudgeWelcomeRequest
+	err := ctx.BindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+
+	// Get the judges to send the welcome to
+	judges, err := database.FindAllJudges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judges from database: " + err.Error()})
+		return
+	}
+	selected := make(map[string]bool, len(req.JudgeIds))
+	for _, id := range req.JudgeIds {
+		selected[id] = true
+	}
+
+	// Send each judge the welcome
+	sent := 0
+	failed := make([]MailFailure, 0)
+	for _, judge := range judges {
+		if (req.JudgeIds == nil && !judge.Active) || (req.JudgeIds != nil && !selected[judge.Id.Hex()]) {
+			continue
+		}
+		name := judge.Profile.FullName()
+		if judge.Profile.Email == "" {
+			failed = append(failed, MailFailure{judge.Id, name, "judge has no email address"})
+			continue
+		}
+		msg, err := judgeWelcome(event, judge.Profile.Email, name)
+		if err == nil {
+			err = sendMail(ctx, msg)
+		}
+		if err != nil {
+			failed = append(failed, MailFailure{judge.Id, name, err.Error()})
+			continue
+		}
+		sent++
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "sent": sent, "failed": failed})
+}
+
+// feedbackUrl returns the link to the feedback page of the project with the token. Links always name their event,
+// so they keep working after another event is made the default.
+func feedbackUrl(event *models.Event, token string) string {
+	return config.Origin + "/events/" + event.Id.Hex() + "/feedback/" + token
+}
+
+// POST /admin/feedback/links/email - EmailFeedbackLinks emails every team with a feedback link and contact
+// emails the link to their feedback. Feedback must have been released.
+func EmailFeedbackLinks(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Make sure feedback has been released
+	options, err := database.GetOptions(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting options from database: " + err.Error()})
+		return
+	}
+	if !options.FeedbackReleased {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "feedback must be released before the links are sent"})
+		return
+	}
+
+	// Get the projects from the database
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+
+	// Send each team their link
+	sent := 0
+	skipped := make([]string, 0)
+	failed := make([]MailFailure, 0)
+	for _, project := range projects {
+		if project.FeedbackToken == "" || len(project.ContactEmails) == 0 {
+			skipped = append(skipped, project.Name)
+			continue
+		}
+		msg, err := mail.FeedbackLink(project.ContactEmails, mail.FeedbackLinkData{
+			EventName:   event.Name,
+			ProjectName: project.Name,
+			Link:        feedbackUrl(event, project.FeedbackToken),
+		})
+		if err == nil {
+			err = sendMail(ctx, msg)
+		}
+		if err != nil {
+			failed = append(failed, MailFailure{project.Id, project.Name, err.Error()})
+			continue
+		}
+		sent++
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "sent": sent, "skipped": skipped, "failed": failed})
+}
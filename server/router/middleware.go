@@ -2,21 +2,53 @@ package router
 
 import (
 	"context"
+	"fmt"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/oauth2"
 	"net/http"
 	"server/auth"
+	"server/config"
 	"server/database"
 	"server/models"
 	"server/util"
+	"strings"
+)
 
-	"slices"
+// Codes sent with 401 and 403 responses, so the client can tell why a request was refused
+const (
+	AuthErrorNotLoggedIn    = "not_logged_in"
+	AuthErrorSessionExpired = "session_expired"
+	AuthErrorForbidden      = "forbidden"
+	AuthErrorInvalidToken   = "invalid_token"
 )
 
+// abortUnauthorised answers a request from a user who isn't logged in with a JSON 401. The response says where
+// to log in, both in the body and in a WWW-Authenticate header, so the client can log in again without
+// leaving the page and losing the judge's work.
+func abortUnauthorised(ctx *gin.Context) {
+	code, message := AuthErrorNotLoggedIn, "not logged in"
+	if ctx.GetBool("session_expired") {
+		code, message = AuthErrorSessionExpired, "session has expired, please log in again"
+	}
+	loginUrl := strings.TrimSuffix(config.ApiOrigin, "/") + "/api/auth/keycloak/login"
+	ctx.Header("WWW-Authenticate", fmt.Sprintf(`Login url="%s", error="%s"`, loginUrl, code))
+	ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message, "code": code, "login_url": loginUrl})
+}
+
+// abortForbidden answers a request from a user who is logged in but isn't allowed to make it with a JSON 403
+func abortForbidden(ctx *gin.Context, message string) {
+	ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": message, "code": AuthErrorForbidden})
+}
+
 func Authenticate() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		// Scripts authenticate with an API token instead of a session
+		if token, ok := bearerToken(ctx); ok {
+			authenticateApiToken(ctx, token)
+			return
+		}
+
 		session := sessions.Default(ctx)
 		userId := session.Get("user_id")
 		switch userId.(type) {
@@ -29,93 +61,90 @@ func Authenticate() gin.HandlerFunc {
 
 		deleteSessionCookieAndNext := func() {
 			session.Delete("user_id")
-			err := session.Save()
+			err := saveSession(ctx)
 			if err != nil {
-				_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error saving session: " + err.Error()})
 				return
 			}
+			ctx.Set("session_expired", true)
 			ctx.Next()
 		}
 
-		db := ctx.MustGet("db").(*mongo.Database)
-		var tokenSet struct {
-			UserId  string       `bson:"user_id"`
-			Token   oauth2.Token `bson:"token_set"`
-			IdToken string       `bson:"id_token"`
-		}
-		err := db.Collection("token_set").FindOne(
-			context.Background(),
-			gin.H{"user_id": userId},
-		).Decode(&tokenSet)
-		if err != nil {
-			deleteSessionCookieAndNext()
-			return
-		}
-
-		idToken := tokenSet.IdToken
-		newToken, err := auth.KeycloakOAuth2Config.TokenSource(context.Background(), &tokenSet.Token).Token()
-		if util.IsNetworkError(err) {
-			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
-			return
-		}
-		if err != nil {
-			deleteSessionCookieAndNext()
-			return
-		}
-
-		if newToken.AccessToken != tokenSet.Token.AccessToken {
-			idToken = newToken.Extra("id_token").(string)
-			_, err = db.Collection("token_set").UpdateOne(
-				context.Background(),
-				gin.H{"user_id": userId},
-				gin.H{"$set": gin.H{
-					"token_set": newToken,
-					"id_token":  idToken,
-				}},
+		// Use the cached login if its tokens haven't expired yet
+		logins := ctx.MustGet("logins").(*auth.LoginCache)
+		login := logins.Get(userId.(string))
+		if login == nil {
+			var err error
+			login, err = loadLogin(
+				ctx.MustGet("db").(*mongo.Database),
+				ctx.MustGet("auth_provider").(auth.AuthProvider),
+				ctx.MustGet("token_cipher").(*auth.TokenCipher),
+				userId.(string),
 			)
+			if util.IsNetworkError(err) {
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error contacting the auth provider: " + err.Error()})
+				return
+			}
 			if err != nil {
-				_ = ctx.AbortWithError(http.StatusInternalServerError, err)
+				deleteSessionCookieAndNext()
 				return
 			}
+			logins.Set(userId.(string), login)
 		}
 
-		userInfo, err := auth.KeycloakOIDCProvider.UserInfo(
-			context.Background(),
-			oauth2.StaticTokenSource(newToken),
-		)
-		if util.IsNetworkError(err) {
-			_ = ctx.AbortWithError(http.StatusInternalServerError, err)
-			return
-		}
+		ctx.Set("user", login.UserInfo)
+		ctx.Set("user_token_set", login.Token)
+		ctx.Set("user_id_token", login.IdToken)
+		ctx.Next()
+	}
+}
+
+// loadLogin loads the token set of a user from the database and has the auth provider verify it,
+// saving the new tokens if it had to refresh them
+func loadLogin(db *mongo.Database, authProvider auth.AuthProvider, tokenCipher *auth.TokenCipher, userId string) (*auth.Login, error) {
+	token, idToken, err := loadTokenSet(db, tokenCipher, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	login, err := authProvider.RefreshLogin(context.Background(), token, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if login.Token.AccessToken != token.AccessToken {
+		err = saveTokenSet(db, tokenCipher, userId, login.Token, login.IdToken)
 		if err != nil {
-			deleteSessionCookieAndNext()
-			return
+			return nil, err
 		}
-
-		ctx.Set("user", userInfo)
-		ctx.Set("user_token_set", newToken)
-		ctx.Set("user_id_token", idToken)
-		ctx.Next()
 	}
+	return login, nil
 }
 
 func AuthoriseJudge() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		maybeUserInfo, exists := ctx.Get("user")
 		if !exists {
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			abortUnauthorised(ctx)
 			return
 		}
 		claims := maybeUserInfo.(*auth.DurHackKeycloakUserInfo)
-		if !slices.Contains(claims.Groups, "/judges") {
-			ctx.AbortWithStatus(http.StatusForbidden)
+		if !ctx.MustGet("auth_provider").(auth.AuthProvider).InGroup(claims, "/judges") {
+			abortForbidden(ctx, "you must be a judge to do this")
+			return
+		}
+
+		// Archived events can no longer be judged
+		event := ctx.MustGet("event").(*models.Event)
+		if event.Archived {
+			abortForbidden(ctx, "event is archived")
 			return
 		}
 
 		// Get the database from the context
 		db := ctx.MustGet("db").(*mongo.Database)
 		userInfo := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo)
-		judge := models.NewJudge(userInfo.Subject)
+		judge := models.NewJudge(event.Id, userInfo.Subject)
 
 		// Insert the judge into the database
 		err := database.GetOrCreateJudge(db, judge)
@@ -124,21 +153,82 @@ func AuthoriseJudge() gin.HandlerFunc {
 			return
 		}
 
+		// Record the first login, so admins can see which imported judges haven't logged in
+		if judge.FirstLoginAt == 0 {
+			err = database.SetJudgeFirstLogin(db, judge)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// Refresh the judge's cached profile if their details have changed since it was last synced
+		profile := userInfo.GetProfile(&judge.Profile)
+		if judge.Profile.SyncedAt == 0 || !judge.Profile.SameDetails(profile) {
+			profile.SyncedAt = util.Now()
+			err = database.UpdateJudgeProfile(db, judge, profile)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
 		ctx.Set("judge", judge)
 		ctx.Next()
 	}
 }
 
-func AuthoriseAdmin() gin.HandlerFunc {
+// AuthoriseVolunteer only lets volunteers and admins through. Volunteers run the floor, so they can mark teams
+// present or absent and move them, without being given admin rights.
+func AuthoriseVolunteer() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		maybeUserInfo, exists := ctx.Get("user")
 		if !exists {
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+			abortUnauthorised(ctx)
+			return
+		}
+		claims := maybeUserInfo.(*auth.DurHackKeycloakUserInfo)
+		authProvider := ctx.MustGet("auth_provider").(auth.AuthProvider)
+		if !authProvider.InGroup(claims, "/volunteers") && !authProvider.InGroup(claims, "/admins") {
+			abortForbidden(ctx, "you must be a volunteer to do this")
+			return
+		}
+
+		// Archived events are over, so there is nothing left to run
+		event := ctx.MustGet("event").(*models.Event)
+		if event.Archived {
+			abortForbidden(ctx, "event is archived")
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// AuthoriseAdmin only lets admins through. API tokens are let through if they have all the given scopes,
+// so endpoints without scopes can only be used by admins who are logged in.
+func AuthoriseAdmin(scopes ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if maybeApiToken, exists := ctx.Get("api_token"); exists {
+			if len(scopes) == 0 {
+				abortForbidden(ctx, "API tokens can't be used for this")
+				return
+			}
+			if !maybeApiToken.(*models.ApiToken).HasScopes(scopes...) {
+				abortForbidden(ctx, "API token needs the "+strings.Join(scopes, ", ")+" scope to do this")
+				return
+			}
+			ctx.Next()
+			return
+		}
+
+		maybeUserInfo, exists := ctx.Get("user")
+		if !exists {
+			abortUnauthorised(ctx)
 			return
 		}
 		claims := maybeUserInfo.(*auth.DurHackKeycloakUserInfo)
-		if !slices.Contains(claims.Groups, "/admins") {
-			ctx.AbortWithStatus(http.StatusForbidden)
+		if !ctx.MustGet("auth_provider").(auth.AuthProvider).InGroup(claims, "/admins") {
+			abortForbidden(ctx, "you must be an admin to do this")
 			return
 		}
 		ctx.Next()
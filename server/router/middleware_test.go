@@ -0,0 +1,125 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/auth"
+	"server/models"
+	"server/router"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthoriseAdminAnswersJson(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider, err := auth.NewLocalProvider(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	judge := &auth.DurHackKeycloakUserInfo{Groups: []string{"/judges"}}
+
+	request := func(user *auth.DurHackKeycloakUserInfo) (*httptest.ResponseRecorder, map[string]string) {
+		engine := gin.New()
+		engine.Use(func(ctx *gin.Context) {
+			ctx.Set("auth_provider", provider)
+			if user != nil {
+				ctx.Set("user", user)
+			}
+		})
+		engine.GET("/api/admin", router.AuthoriseAdmin(), func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+		})
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/admin", nil))
+		var body map[string]string
+		_ = json.Unmarshal(recorder.Body.Bytes(), &body)
+		return recorder, body
+	}
+
+	// Users who aren't logged in are told where to log in
+	recorder, body := request(nil)
+	if recorder.Code != http.StatusUnauthorized || body["code"] != router.AuthErrorNotLoggedIn || body["login_url"] == "" {
+		t.Errorf("Expected a JSON 401 with a login URL, got %d %v", recorder.Code, body)
+	}
+	if recorder.Header().Get("WWW-Authenticate") == "" {
+		t.Error("Expected a WWW-Authenticate header")
+	}
+
+	// Judges aren't admins
+	recorder, body = request(judge)
+	if recorder.Code != http.StatusForbidden || body["code"] != router.AuthErrorForbidden {
+		t.Errorf("Expected a JSON 403, got %d %v", recorder.Code, body)
+	}
+}
+
+func TestAuthoriseVolunteer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider, err := auth.NewLocalProvider(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(groups []string, archived bool) int {
+		engine := gin.New()
+		engine.Use(func(ctx *gin.Context) {
+			ctx.Set("auth_provider", provider)
+			ctx.Set("event", &models.Event{Archived: archived})
+			ctx.Set("user", &auth.DurHackKeycloakUserInfo{Groups: groups})
+		})
+		engine.GET("/api/volunteer/projects", router.AuthoriseVolunteer(), func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+		})
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/volunteer/projects", nil))
+		return recorder.Code
+	}
+
+	// Volunteers and admins can run the floor, but judges can't
+	if code := request([]string{"/volunteers"}, false); code != http.StatusOK {
+		t.Errorf("Expected volunteers to be let through, got %d", code)
+	}
+	if code := request([]string{"/admins"}, false); code != http.StatusOK {
+		t.Errorf("Expected admins to be let through, got %d", code)
+	}
+	if code := request([]string{"/judges"}, false); code != http.StatusForbidden {
+		t.Errorf("Expected judges to be forbidden, got %d", code)
+	}
+
+	// Nothing can be changed once the event is archived
+	if code := request([]string{"/volunteers"}, true); code != http.StatusForbidden {
+		t.Errorf("Expected archived events to be forbidden, got %d", code)
+	}
+}
+
+func TestAuthoriseAdminChecksApiTokenScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	apiToken := &models.ApiToken{Name: "import script", Scopes: []string{models.ScopeProjectsWrite}}
+
+	request := func(scopes ...string) int {
+		engine := gin.New()
+		engine.Use(func(ctx *gin.Context) {
+			ctx.Set("api_token", apiToken)
+			ctx.Set("user", &auth.DurHackKeycloakUserInfo{})
+		})
+		engine.POST("/api/project/new", router.AuthoriseAdmin(scopes...), func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+		})
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/project/new", nil))
+		return recorder.Code
+	}
+
+	if code := request(models.ScopeProjectsWrite); code != http.StatusOK {
+		t.Errorf("Expected a token with the scope to be let through, got %d", code)
+	}
+	if code := request(models.ScopeExportRead); code != http.StatusForbidden {
+		t.Errorf("Expected a token without the scope to be forbidden, got %d", code)
+	}
+
+	// Endpoints without scopes are only for admins who are logged in
+	if code := request(); code != http.StatusForbidden {
+		t.Errorf("Expected tokens to be forbidden from unscoped endpoints, got %d", code)
+	}
+}
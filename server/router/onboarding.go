@@ -0,0 +1,122 @@
+package router
+
+import (
+	"context"
+	"net/http"
+
+	"server/auth"
+	"server/database"
+	"server/funcs"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// importJudge finds or creates the account of a judge from a CSV file, adds it to the judges group
+// and creates the judge in the event, filling in the row with the outcome
+func importJudge(ctx *gin.Context, judgeRow *funcs.JudgeCsvRow, row *funcs.JudgeImportRow) {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+	authProvider := ctx.MustGet("auth_provider").(auth.AuthProvider)
+	fail := func(message string, err error) {
+		row.Status = funcs.ImportErrored
+		row.Error = message + err.Error()
+	}
+
+	// Find or create the account
+	userId, created, err := authProvider.FindOrCreateUser(context.Background(), judgeRow.Email, judgeRow.FirstNames, judgeRow.LastNames)
+	if err != nil {
+		fail("error finding or creating user: ", err)
+		return
+	}
+	row.KeycloakUserId, row.UserCreated = userId, created
+	err = authProvider.AddUserToGroup(context.Background(), userId, "/judges")
+	if err != nil {
+		fail("error adding user to the judges group: ", err)
+		return
+	}
+
+	// Create the judge, or update the notes of an existing one
+	judge, err := database.FindJudgeByKeycloakUserId(db, event.Id, userId)
+	if err != nil {
+		fail("error getting judge from database: ", err)
+		return
+	}
+	switch {
+	case judge == nil:
+		judge = models.NewJudge(event.Id, userId)
+		judge.Notes = judgeRow.Notes
+		// Use the names from the CSV until the profile is synced from keycloak
+		judge.Profile = models.JudgeProfile{FirstNames: judgeRow.FirstNames, LastNames: judgeRow.LastNames, Email: judgeRow.Email}
+		err = database.InsertJudge(db, judge)
+		if err != nil {
+			fail("error inserting judge into database: ", err)
+			return
+		}
+		row.Status = funcs.ImportCreated
+	case judgeRow.Notes != "" && judgeRow.Notes != judge.Notes:
+		_, err = database.UpdateJudgeBasicInfo(db, event.Id, &judge.Id, &models.EditJudgeRequest{Notes: judgeRow.Notes})
+		if err != nil {
+			fail("error updating judge in database: ", err)
+			return
+		}
+		row.Status = funcs.ImportUpdated
+	default:
+		row.Status = funcs.ImportUnchanged
+	}
+	row.JudgeId = &judge.Id
+}
+
+// POST /judge/csv - AddJudgesCsv onboards the judges in a CSV file of names, emails and notes. Keycloak accounts
+// are created for judges who don't have one and added to the judges group, and each judge is created in the
+// event so that admins can see who hasn't logged in yet. Set welcome=true to email new judges a welcome.
+func AddJudgesCsv(ctx *gin.Context) {
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the CSV file from the request
+	content, ok := readCsvFile(ctx)
+	if !ok {
+		return
+	}
+
+	// Get the hasHeader and welcome parameters from the request
+	hasHeader := ctx.PostForm("hasHeader") == "true"
+	welcome := ctx.PostForm("welcome") == "true"
+
+	// Parse the CSV file, leaving out invalid rows
+	judgeRows, rowErrors, err := funcs.ParseJudgeCsv(content, hasHeader)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error parsing CSV file: " + err.Error()})
+		return
+	}
+	if len(judgeRows) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "CSV file contains no valid judges", "row_errors": rowErrors})
+		return
+	}
+
+	// Import each judge, carrying on past judges that fail
+	report := make([]*funcs.JudgeImportRow, len(judgeRows))
+	counts := map[string]int{funcs.ImportCreated: 0, funcs.ImportUpdated: 0, funcs.ImportUnchanged: 0, funcs.ImportErrored: 0}
+	for i, judgeRow := range judgeRows {
+		row := &funcs.JudgeImportRow{Line: judgeRow.Line, Name: judgeRow.Name, Email: judgeRow.Email}
+		report[i] = row
+		importJudge(ctx, judgeRow, row)
+		counts[row.Status]++
+
+		// Welcome the new judges
+		if welcome && row.Status == funcs.ImportCreated {
+			msg, err := judgeWelcome(event, judgeRow.Email, judgeRow.Name)
+			if err == nil {
+				err = sendMail(ctx, msg)
+			}
+			if err != nil {
+				row.Warning = "error sending welcome email: " + err.Error()
+			}
+		}
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "row_errors": rowErrors, "report": report, "counts": counts})
+}
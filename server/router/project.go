@@ -1,9 +1,13 @@
 package router
 
 import (
+	"io"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
+	"server/auth"
 	"server/database"
 	"server/funcs"
 	"server/models"
@@ -13,44 +17,206 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// POST /project/devpost - AddDevpostCsv adds a csv export from devpost to the database
-func AddDevpostCsv(ctx *gin.Context) {
-	// Get the database from the context
-	db := ctx.MustGet("db").(*mongo.Database)
-
+// readCsvFile reads the "csv" file from a multipart form, sending an error response if it can't
+func readCsvFile(ctx *gin.Context) (string, bool) {
 	// Get the CSV file from the request
 	file, err := ctx.FormFile("csv")
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading CSV file from request: " + err.Error()})
-		return
+		return "", false
 	}
 
 	// Open the file
 	f, err := file.Open()
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error opening CSV file: " + err.Error()})
-		return
+		return "", false
 	}
+	defer f.Close()
 
 	// Read the file
-	content := make([]byte, file.Size)
-	_, err = f.Read(content)
+	content, err := io.ReadAll(f)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error reading CSV file: " + err.Error()})
-		return
+		return "", false
+	}
+	return string(content), true
+}
+
+// parseDevpostCsvRequest parses the Devpost CSV in the request using the event's column mapping,
+// sending an error response if it can't
+func parseDevpostCsvRequest(ctx *gin.Context) ([]*funcs.CsvProject, []funcs.CsvIssue, bool) {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	content, ok := readCsvFile(ctx)
+	if !ok {
+		return nil, nil, false
+	}
+
+	// Get the column mapping
+	options, err := database.GetOptions(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting options: " + err.Error()})
+		return nil, nil, false
 	}
 
 	// Parse the CSV file
-	projects, err := funcs.ParseDevpostCSV(string(content))
+	projects, warnings, err := funcs.ParseDevpostCSV(content, options.DevpostColumns)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error parsing CSV file: " + err.Error()})
+		return nil, nil, false
+	}
+	return projects, warnings, true
+}
+
+// importProjects matches the projects parsed from a CSV file against the projects of the event, using the
+// "matchBy" ("url" or "name") and "hideMissing" form fields, and applies the changes unless dryRun is set.
+// Sends an error response and returns nil if it can't.
+func importProjects(ctx *gin.Context, projects []*funcs.CsvProject, dryRun bool) *funcs.ProjectImportPlan {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the import options from the request
+	matchBy := ctx.DefaultPostForm("matchBy", funcs.MatchByUrl)
+	if matchBy != funcs.MatchByUrl && matchBy != funcs.MatchByName {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "matchBy must be 'url' or 'name'"})
+		return nil
+	}
+	hideMissing := ctx.PostForm("hideMissing") == "true"
+
+	// Match against the existing projects
+	existing, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return nil
+	}
+	guilds, err := database.FindGuilds(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting guilds from database: " + err.Error()})
+		return nil
+	}
+	incoming := make([]*models.Project, len(projects))
+	for i, p := range projects {
+		incoming[i] = p.Project
+	}
+	canonicaliseGuildNames(guilds, incoming)
+	challenges, err := database.FindChallenges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting challenges from database: " + err.Error()})
+		return nil
+	}
+	for _, p := range incoming {
+		p.ChallengeList = funcs.CanonicaliseChallengeList(challenges, p.ChallengeList)
+	}
+	plan := funcs.PlanProjectImport(existing, projects, matchBy, hideMissing)
+
+	// Give new projects without a location a table in their guild's zones
+	_, issues, ok := allocateTables(ctx, plan.Create)
+	if !ok {
+		return nil
+	}
+	plan.AddTableIssues(issues)
+	if dryRun {
+		return plan
+	}
+
+	// Link the new and updated projects to their guilds and challenges and apply the changes to the database
+	changed := append(slices.Clone(plan.Create), plan.Update...)
+	if !linkGuilds(ctx, changed) || !linkChallenges(ctx, changed) {
+		return nil
+	}
+	err = database.ApplyProjectImport(db, event.Id, plan.Create, plan.Update, plan.Hide)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error importing projects into database: " + err.Error()})
+		return nil
+	}
+	return plan
+}
+
+// POST /project/devpost - AddDevpostCsv imports a csv export from devpost, creating new projects and updating
+// the details of projects that were already imported
+func AddDevpostCsv(ctx *gin.Context) {
+	// Parse the CSV file
+	csvProjects, warnings, ok := parseDevpostCsvRequest(ctx)
+	if !ok {
+		return
+	}
+	if len(csvProjects) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "CSV file contains no submitted projects", "warnings": warnings})
+		return
+	}
+
+	// Import the projects
+	plan := importProjects(ctx, csvProjects, false)
+	if plan == nil {
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "warnings": warnings, "report": plan.Rows, "counts": plan.Counts()})
+}
+
+// POST /project/devpost/preview - PreviewDevpostCsv parses a csv export from devpost without changing anything,
+// returning the parsed projects, any warnings and the report of what importing it would do
+func PreviewDevpostCsv(ctx *gin.Context) {
+	// Parse the CSV file
+	projects, warnings, ok := parseDevpostCsvRequest(ctx)
+	if !ok {
+		return
+	}
+
+	// Work out what importing the projects would do
+	plan := importProjects(ctx, projects, true)
+	if plan == nil {
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"projects": projects, "warnings": warnings, "report": plan.Rows, "counts": plan.Counts()})
+}
+
+// GET /admin/devpost-columns - GetDevpostColumns returns the headers of the Devpost CSV columns that are imported
+func GetDevpostColumns(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the options
+	options, err := database.GetOptions(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting options: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, options.DevpostColumns.WithDefaults())
+}
+
+// POST /admin/devpost-columns - SetDevpostColumns sets the headers of the Devpost CSV columns that are imported.
+// Any header left empty is reset to its default.
+func SetDevpostColumns(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the column mapping from the request
+	var columns models.DevpostColumns
+	err := ctx.BindJSON(&columns)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error parsing request: " + err.Error()})
 		return
 	}
+	columns = columns.WithDefaults()
 
-	// Insert projects into the database
-	err = database.InsertProjects(db, projects)
+	// Save the column mapping in the database
+	err = database.UpdateDevpostColumns(db, event.Id, &columns)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error inserting judges into database: " + err.Error()})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving devpost columns: " + err.Error()})
 		return
 	}
 
@@ -74,6 +240,9 @@ func AddProject(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the projectReq from the request
 	var projectReq AddProjectRequest
 	err := ctx.BindJSON(&projectReq)
@@ -83,8 +252,8 @@ func AddProject(ctx *gin.Context) {
 	}
 
 	// Make sure name, description, and url are defined
-	if projectReq.Name == "" || projectReq.Description == "" || projectReq.Url == "" || projectReq.Location == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "name, description, url and a location are required"})
+	if projectReq.Name == "" || projectReq.Description == "" || projectReq.Url == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "name, description and url are required"})
 		return
 	}
 
@@ -100,12 +269,23 @@ func AddProject(ctx *gin.Context) {
 	// Create the project
 	project := models.NewProject(projectReq.Name, projectReq.Guild, projectReq.Location, projectReq.Description, projectReq.Url, projectReq.TryLink, projectReq.VideoLink, challengeList)
 
-	// Insert project and update the next table num field in options
-	err = database.WithTransaction(db, func(ctx mongo.SessionContext) (interface{}, error) {
-		// Insert project
-		err := database.InsertProject(db, project)
-		return nil, err
-	})
+	// Link the project to its guild and challenges
+	if !linkGuilds(ctx, []*models.Project{project}) || !linkChallenges(ctx, []*models.Project{project}) {
+		return
+	}
+
+	// Give the project a table in its guild's zones if no location was given
+	_, issues, ok := allocateTables(ctx, []*models.Project{project})
+	if !ok {
+		return
+	}
+	if len(issues) > 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "no location given and no free table to assign: " + issues[0].Message})
+		return
+	}
+
+	// Insert project
+	err = database.InsertProject(db, event.Id, project)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error inserting project into database: " + err.Error()})
 		return
@@ -120,8 +300,11 @@ func ListProjects(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the projects from the database
-	projects, err := database.FindAllProjects(db)
+	projects, err := database.FindAllProjects(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
 		return
@@ -146,8 +329,11 @@ func ListPublicProjects(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the projects from the database
-	projects, err := database.FindAllProjects(db)
+	projects, err := database.FindAllProjects(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
 		return
@@ -172,52 +358,46 @@ func ListPublicProjects(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, publicProjects)
 }
 
-// POST /project/csv - Endpoint to add projects from a CSV file
+// POST /project/csv - Endpoint to import projects from a CSV file, updating projects that were already imported
 func AddProjectsCsv(ctx *gin.Context) {
-	// Get the database from the context
-	db := ctx.MustGet("db").(*mongo.Database)
-
 	// Get the CSV file from the request
-	file, err := ctx.FormFile("csv")
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading CSV file from request: " + err.Error()})
+	content, ok := readCsvFile(ctx)
+	if !ok {
 		return
 	}
 
 	// Get the hasHeader parameter from the request
 	hasHeader := ctx.PostForm("hasHeader") == "true"
 
-	// Open the file
-	f, err := file.Open()
+	// Parse the CSV file, leaving out invalid rows
+	projects, rowErrors, err := funcs.ParseProjectCsv(content, hasHeader)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error opening CSV file: " + err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error parsing CSV file: " + err.Error()})
 		return
 	}
-
-	// Read the file
-	content := make([]byte, file.Size)
-	_, err = f.Read(content)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error reading CSV file: " + err.Error()})
+	if len(projects) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "CSV file contains no valid projects", "row_errors": rowErrors})
 		return
 	}
 
-	// Parse the CSV file
-	projects, err := funcs.ParseProjectCsv(string(content), hasHeader)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error parsing CSV file: " + err.Error()})
+	// Import the valid projects
+	plan := importProjects(ctx, projects, false)
+	if plan == nil {
 		return
 	}
 
-	// Insert projects into the database
-	err = database.InsertProjects(db, projects)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error inserting projects into database: " + err.Error()})
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "row_errors": rowErrors, "report": plan.Rows, "counts": plan.Counts()})
+}
+
+// GET /project/csv/template - GetProjectCsvTemplate downloads a template for the project CSV format,
+// or describes its columns if format=json
+func GetProjectCsvTemplate(ctx *gin.Context) {
+	if ctx.Query("format") == "json" {
+		ctx.JSON(http.StatusOK, gin.H{"columns": funcs.ProjectCsvColumns})
 		return
 	}
-
-	// Send OK
-	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+	funcs.AddCsvData("projects-template", funcs.CreateProjectCsvTemplate(), ctx)
 }
 
 // DELETE /project/:id - DeleteProject deletes a project from the database
@@ -225,6 +405,9 @@ func DeleteProject(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the id from the request
 	id := ctx.Param("id")
 
@@ -236,11 +419,15 @@ func DeleteProject(ctx *gin.Context) {
 	}
 
 	// Delete the project from the database
-	err = database.DeleteProjectById(db, projectObjectId)
+	found, err := database.DeleteProjectById(db, event.Id, projectObjectId)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error deleting project from database: " + err.Error()})
 		return
 	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
 	// todo: also remove project from all judges' current lists so that they don't get an error
 
 	// Send OK
@@ -252,8 +439,11 @@ func ProjectStats(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Aggregate project stats
-	stats, err := database.AggregateProjectStats(db)
+	stats, err := database.AggregateProjectStats(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error aggregating project stats: " + err.Error()})
 		return
@@ -268,6 +458,9 @@ func GetProject(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the id from the request
 	id := ctx.Param("id")
 
@@ -284,6 +477,10 @@ func GetProject(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting project from database: " + err.Error()})
 		return
 	}
+	if project == nil || project.EventId != event.Id {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, project)
@@ -294,8 +491,11 @@ func GetProjectCount(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get the project from the database
-	count, err := database.CountProjectDocuments(db)
+	count, err := database.CountProjectDocuments(db, event.Id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting project count from database: " + err.Error()})
 		return
@@ -310,6 +510,9 @@ func HideProject(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get ID from body
 	var idReq models.IdRequest
 	err := ctx.BindJSON(&idReq)
@@ -327,11 +530,15 @@ func HideProject(ctx *gin.Context) {
 	}
 
 	// Update the project in the database
-	err = database.SetProjectHidden(db, &projectObjectId, true)
+	found, err := database.SetProjectHidden(db, event.Id, &projectObjectId, true)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project in database: " + err.Error()})
 		return
 	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
@@ -342,6 +549,9 @@ func HideUnhideManyProjects(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get ID from body
 	var multiHideReq models.MultiIdHideRequest
 	err := ctx.BindJSON(&multiHideReq)
@@ -352,7 +562,7 @@ func HideUnhideManyProjects(ctx *gin.Context) {
 	ids := multiHideReq.Ids
 
 	// Convert project ID strings to ObjectIDs
-	projectObjectIds := make([]primitive.ObjectID, len(ids))
+	projectObjectIds := make([]primitive.ObjectID, 0, len(ids))
 	for _, id := range ids {
 		projectObjectId, err := primitive.ObjectIDFromHex(id)
 		if err != nil {
@@ -363,7 +573,7 @@ func HideUnhideManyProjects(ctx *gin.Context) {
 	}
 
 	// Update the project in the database
-	err = database.SetProjectsHidden(db, &projectObjectIds, multiHideReq.Hide)
+	err = database.SetProjectsHidden(db, event.Id, &projectObjectIds, multiHideReq.Hide)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating projects in database: " + err.Error()})
 		return
@@ -378,6 +588,9 @@ func UnhideProject(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get ID from body
 	var idReq models.IdRequest
 	err := ctx.BindJSON(&idReq)
@@ -395,29 +608,45 @@ func UnhideProject(ctx *gin.Context) {
 	}
 
 	// Update the project in the database
-	err = database.SetProjectHidden(db, &projectObjectId, false)
+	found, err := database.SetProjectHidden(db, event.Id, &projectObjectId, false)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project in database: " + err.Error()})
 		return
 	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
 }
 
-// POST /project/prioritize - PrioritizeProject prioritizes a project
+// POST /project/prioritize - PrioritizeProject prioritizes a project so that it is picked before other projects,
+// either until it is next picked or for a number of minutes
 func PrioritizeProject(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get ID from body
-	var idReq models.IdRequest
-	err := ctx.BindJSON(&idReq)
+	var prioritizeReq models.PrioritizeRequest
+	err := ctx.BindJSON(&prioritizeReq)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
 		return
 	}
-	id := idReq.Id
+	id := prioritizeReq.Id
+	if prioritizeReq.Minutes < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "minutes cannot be negative"})
+		return
+	}
+	var until primitive.DateTime
+	if prioritizeReq.Minutes > 0 {
+		until = primitive.NewDateTimeFromTime(time.Now().Add(time.Duration(prioritizeReq.Minutes) * time.Minute))
+	}
 
 	// Convert project ID string to ObjectID
 	projectObjectId, err := primitive.ObjectIDFromHex(id)
@@ -427,11 +656,15 @@ func PrioritizeProject(ctx *gin.Context) {
 	}
 
 	// Update the project in the database
-	err = database.SetProjectPrioritized(db, &projectObjectId, true)
+	found, err := database.SetProjectPrioritized(db, event.Id, &projectObjectId, true, until)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project in database: " + err.Error()})
 		return
 	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
@@ -442,6 +675,9 @@ func UnprioritizeProject(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get ID from body
 	var idReq models.IdRequest
 	err := ctx.BindJSON(&idReq)
@@ -459,20 +695,30 @@ func UnprioritizeProject(ctx *gin.Context) {
 	}
 
 	// Update the project in the database
-	err = database.SetProjectPrioritized(db, &projectObjectId, false)
+	found, err := database.SetProjectPrioritized(db, event.Id, &projectObjectId, false, 0)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project in database: " + err.Error()})
 		return
 	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
 
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
 }
 
+// POST /project/update-location - UpdateProjectLocation moves a project to another table, updating judges' copies
+// of it and recording the move in its history
 func UpdateProjectLocation(ctx *gin.Context) {
 	// Get the database from the context
 	db := ctx.MustGet("db").(*mongo.Database)
 
+	// Get the admin and event from the context
+	user := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo)
+	event := ctx.MustGet("event").(*models.Event)
+
 	// Get ID from body
 	var projLocReq models.ProjectLocationRequest
 
@@ -490,8 +736,30 @@ func UpdateProjectLocation(ctx *gin.Context) {
 		return
 	}
 
-	// Update the project in the database
-	err = database.UpdateProjectLocationValue(db, &projectObjectId, projLocReq.Location)
+	// Get the project from the database
+	project, err := database.FindProjectById(db, &projectObjectId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting project from database: " + err.Error()})
+		return
+	}
+	if project == nil || project.EventId != event.Id {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+	location := strings.TrimSpace(projLocReq.Location)
+	if location == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "location cannot be empty"})
+		return
+	}
+	if location == project.Location {
+		ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+		return
+	}
+
+	// Move the project
+	changes := []models.ProjectFieldChange{{Field: "location", Old: project.Location, New: location}}
+	history := []*models.ProjectChange{models.NewProjectChange(project, user.Subject, user.GetFullName(), changes, true)}
+	err = database.UpdateProjectLocations(db, event.Id, map[primitive.ObjectID]string{project.Id: location}, history)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project location in database: " + err.Error()})
 		return
@@ -500,3 +768,159 @@ func UpdateProjectLocation(ctx *gin.Context) {
 	// Send OK
 	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
 }
+
+// findEventProjectFromParam gets the project with the ID in the URL, sending an error response if it
+// isn't a project of the event
+func findEventProjectFromParam(ctx *gin.Context) *models.Project {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Convert project ID string to ObjectID
+	projectObjectId, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return nil
+	}
+
+	// Get the project from the database
+	project, err := database.FindProjectById(db, &projectObjectId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting project from database: " + err.Error()})
+		return nil
+	}
+	if project == nil || project.EventId != event.Id {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return nil
+	}
+	return project
+}
+
+// PATCH /project/:id - EditProject edits any of the fields of a project, recording the change in its history.
+// If propagate is set, judges' copies of the project's name, guild, location and description are updated too.
+func EditProject(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the admin from the context
+	user := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo)
+
+	// Get the project
+	project := findEventProjectFromParam(ctx)
+	if project == nil {
+		return
+	}
+
+	// Get the changes from the request
+	var req models.ProjectPatchRequest
+	err := ctx.BindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "project name cannot be empty"})
+		return
+	}
+
+	// Link the project to the guild it is moving to
+	guildProject := &models.Project{GuildId: project.GuildId}
+	if req.Guild != nil {
+		guildProject.Guild = *req.Guild
+		if !linkGuilds(ctx, []*models.Project{guildProject}) {
+			return
+		}
+		req.Guild = &guildProject.Guild
+	}
+
+	// Link the project to the challenges it is entering
+	challengeProject := &models.Project{ChallengeIds: project.ChallengeIds}
+	if req.ChallengeList != nil {
+		challengeProject.ChallengeList = *req.ChallengeList
+		if !linkChallenges(ctx, []*models.Project{challengeProject}) {
+			return
+		}
+		req.ChallengeList = &challengeProject.ChallengeList
+	}
+
+	// Apply the changes, doing nothing if nothing changed
+	changes := req.Apply(project)
+	project.GuildId = guildProject.GuildId
+	project.ChallengeIds = challengeProject.ChallengeIds
+	if len(changes) == 0 {
+		ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "project": project, "changes": changes})
+		return
+	}
+	propagate := req.Propagate && models.JudgedFieldsChanged(changes)
+	change := models.NewProjectChange(project, user.Subject, user.GetFullName(), changes, propagate)
+
+	// Save the project and its history in the database
+	err = database.UpdateProjectDetails(db, project, change, propagate)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "project": project, "changes": changes})
+}
+
+// GET /project/:id/history - GetProjectHistory returns the changes made to a project by admins, most recent first
+func GetProjectHistory(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the project
+	project := findEventProjectFromParam(ctx)
+	if project == nil {
+		return
+	}
+
+	// Get the history from the database
+	history, err := database.FindProjectHistory(db, &project.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting project history from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, history)
+}
+
+// GET /project/priority-queue - GetPriorityQueue returns the prioritized projects in the order they were
+// prioritized, along with whether each is currently being judged
+func GetPriorityQueue(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the prioritized projects and the projects being judged from the database
+	projects, err := database.FindPrioritizedProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting prioritized projects from database: " + err.Error()})
+		return
+	}
+	var busyProjects []*primitive.ObjectID
+	err = database.WithTransaction(db, func(sc mongo.SessionContext) (interface{}, error) {
+		busyProjects, err = database.FindBusyProjects(db, sc, event.Id)
+		return nil, err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting busy projects from database: " + err.Error()})
+		return
+	}
+	busy := make(map[primitive.ObjectID]bool)
+	for _, id := range busyProjects {
+		busy[*id] = true
+	}
+
+	// Build the queue
+	queue := make([]models.PriorityQueueEntry, len(projects))
+	for i, project := range projects {
+		queue[i] = models.PriorityQueueEntry{Project: project, Busy: busy[project.Id]}
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, queue)
+}
@@ -0,0 +1,248 @@
+package router
+
+import (
+	"net/http"
+
+	"server/database"
+	"server/funcs"
+	"server/models"
+	"server/ranking"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// sendAdminResults sends the results including the scores of each place
+func sendAdminResults(ctx *gin.Context, results *models.Results) {
+	body, err := results.AdminView()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error encoding results: " + err.Error()})
+		return
+	}
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// findResults gets the results of the event, sending an error response if they haven't been generated
+func findResults(ctx *gin.Context) *models.Results {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	results, err := database.FindResults(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting results from database: " + err.Error()})
+		return nil
+	}
+	if results == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "results have not been generated"})
+		return nil
+	}
+	return results
+}
+
+// GET /admin/results - GetResults returns the generated results with their scores and reveal progress
+func GetResults(ctx *gin.Context) {
+	// Get the results
+	results := findResults(ctx)
+	if results == nil {
+		return
+	}
+
+	// Send OK
+	sendAdminResults(ctx, results)
+}
+
+// POST /admin/results - GenerateResults works out the winners from the current judging data and saves them
+// unpublished, replacing any previous results. Results can't be regenerated while they are published.
+func GenerateResults(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the request
+	var req models.GenerateResultsRequest
+	err := ctx.BindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+
+	// Make sure the current results aren't published
+	existing, err := database.FindResults(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting results from database: " + err.Error()})
+		return
+	}
+	if existing != nil && existing.Published {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "results are published, unpublish them before regenerating"})
+		return
+	}
+
+	// Get the judging data from the database
+	options, err := database.GetOptions(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting options from database: " + err.Error()})
+		return
+	}
+	if req.Categories == nil {
+		req.Categories = options.Categories
+	}
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+	challenges, err := database.FindChallenges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting challenges from database: " + err.Error()})
+		return
+	}
+	judges, err := database.FindAllJudges(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judges from database: " + err.Error()})
+		return
+	}
+	err, errStr, scores := ranking.GetScoresFromDB(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": errStr + err.Error()})
+		return
+	}
+
+	// Work out the winners
+	sections, err := funcs.BuildResults(&req, projects, challenges, judges, scores)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid results request: " + err.Error()})
+		return
+	}
+
+	// Save the results
+	results := models.NewResults(event.Id, sections)
+	err = database.SaveResults(db, results)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving results in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	sendAdminResults(ctx, results)
+}
+
+type PublishResultsRequest struct {
+	Published bool `json:"published"`
+}
+
+// POST /admin/results/publish - PublishResults publishes or unpublishes the results. Results can only be
+// published once judging has ended.
+func PublishResults(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the request
+	var req PublishResultsRequest
+	err := ctx.BindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+
+	// Get the results
+	results := findResults(ctx)
+	if results == nil {
+		return
+	}
+
+	// Make sure judging has ended
+	if req.Published {
+		judgingEnded, err := database.GetJudgingEnded(db, event.Id)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judging_ended flag: " + err.Error()})
+			return
+		}
+		if !judgingEnded {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "results can't be published until judging has ended"})
+			return
+		}
+	}
+
+	// Save the results
+	results.Published = req.Published
+	err = database.UpdateResultsReveal(db, results)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating results in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	sendAdminResults(ctx, results)
+}
+
+// revealResults returns a handler that changes how many places of the results are revealed. The new count is
+// worked out from the current one and the total number of places.
+func revealResults(reveal func(revealed int, total int) int) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Get the database from the context
+		db := ctx.MustGet("db").(*mongo.Database)
+
+		// Get the results
+		results := findResults(ctx)
+		if results == nil {
+			return
+		}
+
+		// Change the reveal and save it
+		results.SetRevealed(reveal(results.Revealed, results.TotalPlaces()))
+		err := database.UpdateResultsReveal(db, results)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating results in database: " + err.Error()})
+			return
+		}
+
+		// Send the place revealed next so the host knows what is coming
+		section, place := results.NextReveal()
+		ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "results": results.PublicView(), "next_section": section, "next_place": place})
+	}
+}
+
+// POST /admin/results/reveal/next - RevealNextResult reveals the next place
+var RevealNextResult = revealResults(func(revealed int, total int) int { return revealed + 1 })
+
+// POST /admin/results/reveal/previous - HidePreviousResult hides the last revealed place again
+var HidePreviousResult = revealResults(func(revealed int, total int) int { return revealed - 1 })
+
+// POST /admin/results/reveal/all - RevealAllResults reveals every place
+var RevealAllResults = revealResults(func(revealed int, total int) int { return total })
+
+// POST /admin/results/reveal/reset - ResetResultsReveal hides every place again
+var ResetResultsReveal = revealResults(func(revealed int, total int) int { return 0 })
+
+// GET /results/public - GetPublicResults returns the revealed places of the published results
+func GetPublicResults(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Only show results once judging has ended and they have been published
+	judgingEnded, err := database.GetJudgingEnded(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting judging_ended flag: " + err.Error()})
+		return
+	}
+	results, err := database.FindResults(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting results from database: " + err.Error()})
+		return
+	}
+	if !judgingEnded || results == nil || !results.Published {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "results have not been published"})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, results.PublicView())
+}
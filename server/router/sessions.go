@@ -0,0 +1,190 @@
+package router
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"server/auth"
+	"server/config"
+	"server/database"
+	"server/models"
+	"server/util"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// sessionKeysFromEnv returns the keys to sign session cookies with from SESSION_SECRETS, a comma separated
+// list of secrets of at least 32 characters. Cookies are signed with the first secret, and the others are
+// still accepted so that secrets can be rotated without logging everyone out.
+func sessionKeysFromEnv() ([][]byte, error) {
+	secrets := strings.Split(config.GetOptEnv("SESSION_SECRETS", ""), ",")
+	keyPairs := make([][]byte, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		secret = strings.TrimSpace(secret)
+		if secret == "" {
+			continue
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("each of SESSION_SECRETS must be at least 32 characters long")
+		}
+		// Sessions are stored in the database, so the cookie only needs signing and not encrypting
+		keyPairs = append(keyPairs, []byte(secret), nil)
+	}
+
+	if len(keyPairs) == 0 {
+		if gin.Mode() == gin.ReleaseMode {
+			return nil, errors.New("SESSION_SECRETS must be set in release mode")
+		}
+		secret, err := util.NewToken()
+		if err != nil {
+			return nil, err
+		}
+		log.Println("SESSION_SECRETS is not set, so a random secret is being used and sessions won't survive a restart")
+		keyPairs = append(keyPairs, []byte(secret), nil)
+	}
+	return keyPairs, nil
+}
+
+// sessionOptionsFromEnv returns the options of session cookies. Cookies are only sent over HTTPS when the API
+// origin is HTTPS, and last for SESSION_MAX_AGE (1 hour by default). SESSION_SAME_SITE can be lax (the
+// default), strict or none, which is only allowed over HTTPS.
+func sessionOptionsFromEnv() (sessions.Options, error) {
+	parsedUrl, err := url.Parse(config.ApiOrigin)
+	if err != nil {
+		return sessions.Options{}, err
+	}
+	secure := parsedUrl.Scheme == "https"
+
+	maxAgeEnv := config.GetOptEnv("SESSION_MAX_AGE", "")
+	if maxAgeEnv == "" {
+		maxAgeEnv = "1h"
+	}
+	maxAge, err := time.ParseDuration(maxAgeEnv)
+	if err != nil || maxAge < time.Minute {
+		return sessions.Options{}, errors.New("SESSION_MAX_AGE must be a duration of at least 1m")
+	}
+
+	var sameSite http.SameSite
+	switch strings.ToLower(config.GetOptEnv("SESSION_SAME_SITE", "")) {
+	case "", "lax":
+		sameSite = http.SameSiteLaxMode
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		if !secure {
+			return sessions.Options{}, errors.New("SESSION_SAME_SITE can only be none when API_ORIGIN is https")
+		}
+		sameSite = http.SameSiteNoneMode
+	default:
+		return sessions.Options{}, errors.New("SESSION_SAME_SITE must be lax, strict or none")
+	}
+
+	return sessions.Options{
+		Path:     "/",
+		Domain:   parsedUrl.Host,
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	}, nil
+}
+
+// saveSession saves the session of the request. The session store doesn't pass all options on to new
+// sessions, so they are set every time the session is saved.
+func saveSession(ctx *gin.Context) error {
+	session := sessions.Default(ctx)
+	session.Options(ctx.MustGet("session_options").(sessions.Options))
+	return session.Save()
+}
+
+// recordSession records who the session of the request belongs to, so that admins can revoke it
+func recordSession(ctx *gin.Context, userInfo *auth.DurHackKeycloakUserInfo) error {
+	id, err := primitive.ObjectIDFromHex(sessions.Default(ctx).ID())
+	if err != nil {
+		return err
+	}
+	maxAge := time.Duration(ctx.MustGet("session_options").(sessions.Options).MaxAge) * time.Second
+	userSession := models.NewUserSession(id, userInfo.Subject, maxAge)
+	userSession.Email = userInfo.Email
+	userSession.Name = userInfo.GetFullName()
+	userSession.UserAgent = ctx.Request.UserAgent()
+	userSession.Ip = ctx.ClientIP()
+	return database.SaveUserSession(ctx.MustGet("db").(*mongo.Database), userSession)
+}
+
+// GET /api/sessions - ListSessions lists the sessions which haven't expired
+func ListSessions(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the sessions
+	userSessions, err := database.FindActiveUserSessions(db)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting sessions from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, userSessions)
+}
+
+// DELETE /api/sessions/:id - RevokeSession logs out a session, e.g. of a judge whose phone has been lost.
+// The user's stored tokens are deleted too if they have no other sessions.
+func RevokeSession(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the session
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid session ID: " + err.Error()})
+		return
+	}
+	userSession, err := database.FindUserSession(db, id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting session from database: " + err.Error()})
+		return
+	}
+	if userSession == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	// Revoke it
+	tokenSetDeleted, err := database.RevokeUserSession(db, userSession)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error revoking session: " + err.Error()})
+		return
+	}
+	if tokenSetDeleted {
+		ctx.MustGet("logins").(*auth.LoginCache).Forget(userSession.UserId)
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "token_set_deleted": tokenSetDeleted})
+}
+
+// DELETE /api/users/:userId/sessions - RevokeUserSessions logs out every session of a user and deletes their
+// stored tokens
+func RevokeUserSessions(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Revoke the sessions
+	userId := ctx.Param("userId")
+	revoked, err := database.RevokeUserSessions(db, userId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error revoking sessions: " + err.Error()})
+		return
+	}
+	ctx.MustGet("logins").(*auth.LoginCache).Forget(userId)
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "revoked": revoked})
+}
@@ -0,0 +1,262 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"server/database"
+	"server/funcs"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// saveClock writes the in-memory clock of the event to the database so that snapshots include it
+func saveClock(ctx *gin.Context) error {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+	clock := ctx.MustGet("clock").(*models.ClockState)
+	return database.UpdateClock(db, event.Id, clock)
+}
+
+// GET /admin/snapshots - ListSnapshots lists all snapshots of the event
+func ListSnapshots(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the snapshots from the database
+	snapshots, err := database.FindSnapshots(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting snapshots from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, snapshots)
+}
+
+// POST /admin/snapshots - CreateSnapshot takes a snapshot of the event
+func CreateSnapshot(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Take the snapshot
+	err := saveClock(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving clock: " + err.Error()})
+		return
+	}
+	snapshot, err := database.CreateSnapshot(db, event, "manual")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error creating snapshot: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, snapshot)
+}
+
+// findSnapshotFromParam gets the snapshot referenced by the :id path parameter, sending an error response if it can't
+func findSnapshotFromParam(ctx *gin.Context) *models.Snapshot {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Convert snapshot ID string to ObjectID
+	snapshotObjectId, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid snapshot ID"})
+		return nil
+	}
+
+	// Get the snapshot from the database
+	snapshot, err := database.FindSnapshotById(db, event.Id, &snapshotObjectId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting snapshot from database: " + err.Error()})
+		return nil
+	}
+	if snapshot == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "snapshot not found"})
+		return nil
+	}
+	return snapshot
+}
+
+// GET /admin/snapshots/:id - DownloadSnapshot sends a snapshot as a JSON bundle
+func DownloadSnapshot(ctx *gin.Context) {
+	snapshot := findSnapshotFromParam(ctx)
+	if snapshot == nil {
+		return
+	}
+
+	// Decode the bundle
+	bundle, err := models.DecodeEventBundle(snapshot.Data)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error decoding snapshot: " + err.Error()})
+		return
+	}
+	content, err := json.Marshal(bundle)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error encoding snapshot: " + err.Error()})
+		return
+	}
+
+	// Send JSON file
+	funcs.AddJsonFile(fmt.Sprintf("snapshot-%s", snapshot.Id.Hex()), content, ctx)
+}
+
+// POST /admin/snapshots/:id/restore - RestoreSnapshot replaces the state of the event with a snapshot.
+// The current state is snapshotted first, so a restore can itself be undone.
+func RestoreSnapshot(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	snapshot := findSnapshotFromParam(ctx)
+	if snapshot == nil {
+		return
+	}
+
+	// Decode the bundle
+	bundle, err := models.DecodeEventBundle(snapshot.Data)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error decoding snapshot: " + err.Error()})
+		return
+	}
+
+	// Keep a snapshot of the state being replaced
+	err = saveClock(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving clock: " + err.Error()})
+		return
+	}
+	_, err = database.CreateSnapshot(db, event, "restore")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error creating snapshot before restore: " + err.Error()})
+		return
+	}
+
+	// Restore the bundle
+	err = database.RestoreEventBundle(db, event.Id, bundle)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error restoring snapshot: " + err.Error()})
+		return
+	}
+
+	// Forget the event's clock and comparisons so that they are rebuilt from the restored state
+	ctx.MustGet("event_states").(*eventStates).forget(event.Id)
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
+
+// GET /admin/export/event - ExportEvent exports the entire state of the event as a JSON bundle
+func ExportEvent(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Build the bundle
+	err := saveClock(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving clock: " + err.Error()})
+		return
+	}
+	bundle, err := database.BuildEventBundle(db, event)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error building event bundle: " + err.Error()})
+		return
+	}
+	content, err := json.Marshal(bundle)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error encoding event bundle: " + err.Error()})
+		return
+	}
+
+	// Send JSON file
+	funcs.AddJsonFile("event", content, ctx)
+}
+
+// POST /admin/import/event - ImportEvent replaces the state of the event with an uploaded JSON bundle.
+// Bundles exported from a different event get new IDs unless the keepIds form field is "true".
+func ImportEvent(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the bundle file from the request
+	file, err := ctx.FormFile("bundle")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading bundle file from request: " + err.Error()})
+		return
+	}
+	keepIds := ctx.PostForm("keepIds") == "true"
+
+	// Open and read the file
+	f, err := file.Open()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error opening bundle file: " + err.Error()})
+		return
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error reading bundle file: " + err.Error()})
+		return
+	}
+
+	// Parse the bundle, checking its schema version
+	bundle, err := models.ParseEventBundle(content)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error parsing bundle file: " + err.Error()})
+		return
+	}
+	if bundle.Event.Id != event.Id && !keepIds {
+		bundle.RemapIds()
+	}
+
+	// Keep a snapshot of the state being replaced
+	err = saveClock(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error saving clock: " + err.Error()})
+		return
+	}
+	_, err = database.CreateSnapshot(db, event, "import")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error creating snapshot before import: " + err.Error()})
+		return
+	}
+
+	// Import the bundle
+	err = database.RestoreEventBundle(db, event.Id, bundle)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error importing bundle: " + err.Error()})
+		return
+	}
+
+	// Forget the event's clock and comparisons so that they are rebuilt from the imported state
+	ctx.MustGet("event_states").(*eventStates).forget(event.Id)
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{
+		"yes_no":   1,
+		"projects": len(bundle.Projects),
+		"judges":   len(bundle.Judges),
+		"flags":    len(bundle.Flags),
+	})
+}
@@ -0,0 +1,298 @@
+package router
+
+import (
+	"net/http"
+
+	"server/auth"
+	"server/database"
+	"server/funcs"
+	"server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GET /admin/zones - ListTableZones lists the table zones of the event
+func ListTableZones(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the zones from the database
+	zones, err := database.FindTableZones(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting table zones from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, zones)
+}
+
+// bindTableZone reads a zone from the request and validates it against the other zones of the event,
+// sending an error response if it is invalid
+func bindTableZone(ctx *gin.Context, zone *models.TableZone) bool {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the zone from the request
+	var zoneReq models.TableZoneRequest
+	err := ctx.BindJSON(&zoneReq)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return false
+	}
+	zone.Name, zone.Guild, zone.FirstTable, zone.LastTable, zone.Capacity =
+		zoneReq.Name, zoneReq.Guild, zoneReq.FirstTable, zoneReq.LastTable, zoneReq.Capacity
+
+	// Validate the zone
+	zones, err := database.FindTableZones(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting table zones from database: " + err.Error()})
+		return false
+	}
+	err = zone.Validate(zones)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid table zone: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// POST /admin/zones - CreateTableZone creates a table zone
+func CreateTableZone(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the zone from the request
+	zone := &models.TableZone{}
+	if !bindTableZone(ctx, zone) {
+		return
+	}
+
+	// Insert the zone into the database
+	err := database.InsertTableZone(db, event.Id, zone)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error inserting table zone into database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, zone)
+}
+
+// findTableZoneFromParam gets the zone of the event with the ID in the URL, sending an error response if there is none
+func findTableZoneFromParam(ctx *gin.Context) *models.TableZone {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Convert zone ID string to ObjectID
+	zoneObjectId, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid table zone ID"})
+		return nil
+	}
+
+	// Get the zone from the database
+	zone, err := database.FindTableZoneById(db, event.Id, &zoneObjectId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting table zone from database: " + err.Error()})
+		return nil
+	}
+	if zone == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "table zone not found"})
+		return nil
+	}
+	return zone
+}
+
+// PUT /admin/zones/:id - EditTableZone edits a table zone. Projects already at tables are not moved.
+func EditTableZone(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the zone
+	zone := findTableZoneFromParam(ctx)
+	if zone == nil {
+		return
+	}
+
+	// Get the new details of the zone from the request
+	if !bindTableZone(ctx, zone) {
+		return
+	}
+
+	// Save the zone in the database
+	err := database.UpdateTableZone(db, zone)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating table zone in database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, zone)
+}
+
+// DELETE /admin/zones/:id - DeleteTableZone deletes a table zone. Projects already at its tables are not moved.
+func DeleteTableZone(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the zone
+	zone := findTableZoneFromParam(ctx)
+	if zone == nil {
+		return
+	}
+
+	// Delete the zone from the database
+	err := database.DeleteTableZone(db, event.Id, &zone.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error deleting table zone from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
+
+// GET /project/tables/collisions - GetTableCollisions lists the locations shared by more than one active project
+func GetTableCollisions(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the projects from the database
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, funcs.FindTableCollisions(projects))
+}
+
+// allocateTables gives the projects without a location a table in their guild's zones.
+// Sends an error response and returns false if the zones or projects can't be read.
+func allocateTables(ctx *gin.Context, projects []*models.Project) ([]funcs.TableAssignment, []funcs.TableIssue, bool) {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+
+	zones, err := database.FindTableZones(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting table zones from database: " + err.Error()})
+		return nil, nil, false
+	}
+	existing, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return nil, nil, false
+	}
+
+	assignments, issues := funcs.AllocateTables(zones, existing, projects)
+	return assignments, issues, true
+}
+
+// saveTableAssignments moves the projects to their assigned tables, recording the moves in their history.
+// Sends an error response and returns false if it can't.
+func saveTableAssignments(ctx *gin.Context, assignments []funcs.TableAssignment) bool {
+	db := ctx.MustGet("db").(*mongo.Database)
+	event := ctx.MustGet("event").(*models.Event)
+	user := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo)
+
+	locations := make(map[primitive.ObjectID]string, len(assignments))
+	history := make([]*models.ProjectChange, 0, len(assignments))
+	for _, a := range assignments {
+		locations[a.ProjectId] = a.Location
+		project := &models.Project{Id: a.ProjectId, EventId: event.Id}
+		changes := []models.ProjectFieldChange{{Field: "location", Old: a.OldLocation, New: a.Location}}
+		history = append(history, models.NewProjectChange(project, user.Subject, user.GetFullName(), changes, true))
+	}
+
+	err := database.UpdateProjectLocations(db, event.Id, locations, history)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project locations in database: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// POST /project/tables/assign - AssignTables gives every project without a location a free table in its guild's zones
+func AssignTables(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the projects from the database
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+
+	// Allocate tables and save them
+	assignments, issues, ok := allocateTables(ctx, projects)
+	if !ok || !saveTableAssignments(ctx, assignments) {
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "assignments": assignments, "issues": issues})
+}
+
+type RenumberTablesRequest struct {
+	Guild  *string `json:"guild"`   // Only renumber this guild, or every guild with zones if left out
+	DryRun bool    `json:"dry_run"` // Return the new table numbers without saving them
+}
+
+// POST /project/tables/renumber - RenumberTables gives the projects of each guild consecutive table numbers
+// through the guild's zones, keeping their current order
+func RenumberTables(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the request
+	var req RenumberTablesRequest
+	err := ctx.BindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+
+	// Get the zones and projects from the database
+	zones, err := database.FindTableZones(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting table zones from database: " + err.Error()})
+		return
+	}
+	projects, err := database.FindAllProjects(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting projects from database: " + err.Error()})
+		return
+	}
+
+	// Work out the new table numbers, saving them unless this is a dry run
+	assignments, issues := funcs.RenumberTables(zones, projects, req.Guild)
+	if !req.DryRun && !saveTableAssignments(ctx, assignments) {
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "assignments": assignments, "issues": issues})
+}
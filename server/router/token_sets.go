@@ -0,0 +1,155 @@
+package router
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"server/auth"
+	"server/config"
+	"server/database"
+	"server/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/oauth2"
+)
+
+// tokenSetFallbackLifetime is how long token sets are kept when it isn't known when their refresh token expires
+const tokenSetFallbackLifetime = 30 * 24 * time.Hour
+
+var errNoTokenSet = errors.New("user has no stored token set")
+
+// errTokenSetPurged is returned when a token set couldn't be decrypted and was deleted, e.g. because its key
+// was removed from TOKEN_ENCRYPTION_KEYS. The user has to log in again.
+var errTokenSetPurged = errors.New("token set couldn't be decrypted and was deleted")
+
+// tokenCipherFromEnv returns the cipher for stored token sets from TOKEN_ENCRYPTION_KEYS, a comma separated list
+// of base64 encoded 32 byte keys. New token sets are encrypted with the first key, and the others can still
+// decrypt token sets so that keys can be rotated.
+func tokenCipherFromEnv() (*auth.TokenCipher, error) {
+	keys := make([][]byte, 0)
+	for _, encodedKey := range strings.Split(config.GetOptEnv("TOKEN_ENCRYPTION_KEYS", ""), ",") {
+		encodedKey = strings.TrimSpace(encodedKey)
+		if encodedKey == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("each of TOKEN_ENCRYPTION_KEYS must be 32 bytes encoded in base64, e.g. from `openssl rand -base64 32`")
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		if gin.Mode() == gin.ReleaseMode {
+			return nil, errors.New("TOKEN_ENCRYPTION_KEYS must be set in release mode")
+		}
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("TOKEN_ENCRYPTION_KEYS is not set, so a random key is being used and users will have to log in again after a restart")
+		keys = append(keys, key)
+	}
+	return auth.NewTokenCipher(keys)
+}
+
+// saveTokenSet encrypts and saves the tokens of a user, replacing their previous token set
+func saveTokenSet(db *mongo.Database, tokenCipher *auth.TokenCipher, userId string, token *oauth2.Token, idToken string) error {
+	tokenSet, err := tokenCipher.Seal(userId, token, idToken, auth.TokenSetExpiry(token, tokenSetFallbackLifetime))
+	if err != nil {
+		return err
+	}
+	return database.SaveTokenSet(db, tokenSet)
+}
+
+// loadTokenSet loads and decrypts the tokens of a user
+func loadTokenSet(db *mongo.Database, tokenCipher *auth.TokenCipher, userId string) (*oauth2.Token, string, error) {
+	tokenSet, err := database.FindTokenSet(db, userId)
+	if err != nil {
+		return nil, "", err
+	}
+	if tokenSet == nil {
+		return nil, "", errNoTokenSet
+	}
+	token, idToken, _, err := openTokenSet(db, tokenCipher, tokenSet)
+	return token, idToken, err
+}
+
+// openTokenSet decrypts a token set. Token sets saved in plain text or encrypted with an old key are encrypted
+// again with the current key, and token sets which can't be decrypted are deleted. Returns whether the token
+// set was encrypted again.
+func openTokenSet(db *mongo.Database, tokenCipher *auth.TokenCipher, tokenSet *models.TokenSet) (*oauth2.Token, string, bool, error) {
+	// Encrypt token sets saved before token sets were encrypted
+	if tokenSet.IsPlaintext() {
+		var token oauth2.Token
+		err := bson.Unmarshal(tokenSet.PlainToken, &token)
+		if err != nil {
+			return nil, "", false, purgeTokenSet(db, tokenSet.UserId, err)
+		}
+		err = saveTokenSet(db, tokenCipher, tokenSet.UserId, &token, tokenSet.PlainIdToken)
+		if err != nil {
+			return nil, "", false, err
+		}
+		return &token, tokenSet.PlainIdToken, true, nil
+	}
+
+	token, idToken, err := tokenCipher.Open(tokenSet)
+	if err != nil {
+		return nil, "", false, purgeTokenSet(db, tokenSet.UserId, err)
+	}
+	if tokenCipher.IsCurrentKey(tokenSet) {
+		return token, idToken, false, nil
+	}
+
+	// Encrypt the token set with the current key, keeping when it expires
+	newTokenSet, err := tokenCipher.Seal(tokenSet.UserId, token, idToken, tokenSet.ExpiresAt.Time())
+	if err != nil {
+		return nil, "", false, err
+	}
+	err = database.SaveTokenSet(db, newTokenSet)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return token, idToken, true, nil
+}
+
+// purgeTokenSet deletes a token set which couldn't be decrypted
+func purgeTokenSet(db *mongo.Database, userId string, cause error) error {
+	err := database.DeleteTokenSet(db, userId)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", errTokenSetPurged, cause)
+}
+
+// migrateTokenSets encrypts every token set which is in plain text or encrypted with an old key with the
+// current key, and deletes the token sets which can't be decrypted, so that old keys can be removed.
+func migrateTokenSets(db *mongo.Database, tokenCipher *auth.TokenCipher) (int, int, error) {
+	tokenSets, err := database.FindAllTokenSets(db)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	migrated, purged := 0, 0
+	for _, tokenSet := range tokenSets {
+		_, _, reencrypted, err := openTokenSet(db, tokenCipher, tokenSet)
+		if errors.Is(err, errTokenSetPurged) {
+			purged++
+			continue
+		}
+		if err != nil {
+			return migrated, purged, err
+		}
+		if reencrypted {
+			migrated++
+		}
+	}
+	return migrated, purged, nil
+}
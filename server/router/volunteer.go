@@ -0,0 +1,202 @@
+package router
+
+import (
+	"net/http"
+	"server/auth"
+	"server/database"
+	"server/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// POST /volunteer/auth - Check to make sure a volunteer is authenticated
+func VolunteerAuthenticated(ctx *gin.Context) {
+	// This route will run the middleware first, and if the middleware
+	// passes, then that means the volunteer is authenticated
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
+
+// POST /volunteer/project/:id/status - SetProjectStatus marks a project as present, absent or hidden.
+// Absent and hidden projects aren't judged, and marking a project present resolves its absent flags.
+func SetProjectStatus(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the volunteer from the context
+	user := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo)
+
+	// Get the project
+	project := findEventProjectFromParam(ctx)
+	if project == nil {
+		return
+	}
+
+	// Get the status from the request
+	var statusReq models.ProjectStatusRequest
+	err := ctx.BindJSON(&statusReq)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+	var active, absent bool
+	switch statusReq.Status {
+	case "present":
+		active, absent = true, false
+	case "absent":
+		active, absent = false, true
+	case "hidden":
+		active, absent = false, false
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "status must be present, absent or hidden"})
+		return
+	}
+
+	// Apply the status, recording who changed it in the project's history
+	changes := make([]models.ProjectFieldChange, 0)
+	if project.Active != active {
+		changes = append(changes, models.ProjectFieldChange{Field: "active", Old: project.Active, New: active})
+		project.Active = active
+	}
+	if project.Absent != absent {
+		changes = append(changes, models.ProjectFieldChange{Field: "absent", Old: project.Absent, New: absent})
+		project.Absent = absent
+	}
+	if len(changes) > 0 {
+		change := models.NewProjectChange(project, user.Subject, user.GetFullName(), changes, false)
+		err = database.UpdateProjectDetails(db, project, change, false)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project in database: " + err.Error()})
+			return
+		}
+	}
+
+	// The team is back, so judges' absent flags no longer need following up
+	var resolved int64
+	if statusReq.Status == "present" {
+		resolved, err = database.ResolveProjectAbsentFlags(db, &project.Id, user.Subject, user.GetFullName())
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error resolving flags in database: " + err.Error()})
+			return
+		}
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "project": project, "changes": changes, "resolved_flags": resolved})
+}
+
+// PUT /volunteer/project/:id/location - MoveProject moves a project to another table, updating judges' copies
+// of it and recording the move in its history
+func MoveProject(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the volunteer and event from the context
+	user := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the project
+	project := findEventProjectFromParam(ctx)
+	if project == nil {
+		return
+	}
+
+	// Get the location from the request
+	var locationReq models.LocationRequest
+	err := ctx.BindJSON(&locationReq)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "error reading request body: " + err.Error()})
+		return
+	}
+	location := strings.TrimSpace(locationReq.Location)
+	if location == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "location cannot be empty"})
+		return
+	}
+	if location == project.Location {
+		ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "project": project})
+		return
+	}
+
+	// Move the project
+	changes := []models.ProjectFieldChange{{Field: "location", Old: project.Location, New: location}}
+	history := []*models.ProjectChange{models.NewProjectChange(project, user.Subject, user.GetFullName(), changes, true)}
+	err = database.UpdateProjectLocations(db, event.Id, map[primitive.ObjectID]string{project.Id: location}, history)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error updating project location in database: " + err.Error()})
+		return
+	}
+	project.Location = location
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1, "project": project})
+}
+
+// GET /volunteer/flags - ListAbsentFlags lists the absent flags which haven't been resolved yet, oldest first
+func ListAbsentFlags(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the event from the context
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the flags
+	flags, err := database.FindUnresolvedAbsentFlags(db, event.Id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting flags: " + err.Error()})
+		return
+	}
+	if !fillFlagJudgeNames(ctx, flags) {
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, flags)
+}
+
+// POST /volunteer/flags/:id/resolve - ResolveAbsentFlag marks an absent flag as followed up, e.g. when the team
+// has been found or signed out. Other flags are left to admins.
+func ResolveAbsentFlag(ctx *gin.Context) {
+	// Get the database from the context
+	db := ctx.MustGet("db").(*mongo.Database)
+
+	// Get the volunteer and event from the context
+	user := ctx.MustGet("user").(*auth.DurHackKeycloakUserInfo)
+	event := ctx.MustGet("event").(*models.Event)
+
+	// Get the flag
+	flagId, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid flag ID"})
+		return
+	}
+	flag, err := database.FindFlagById(db, &flagId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error getting flag from database: " + err.Error()})
+		return
+	}
+	if flag == nil || flag.EventId != event.Id {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "flag not found"})
+		return
+	}
+	if flag.Reason != "absent" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "only absent flags can be resolved by volunteers"})
+		return
+	}
+
+	// Resolve it
+	resolved, err := database.ResolveFlag(db, &flagId, user.Subject, user.GetFullName())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "error resolving flag in database: " + err.Error()})
+		return
+	}
+	if !resolved {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "flag has already been resolved"})
+		return
+	}
+
+	// Send OK
+	ctx.JSON(http.StatusOK, gin.H{"yes_no": 1})
+}
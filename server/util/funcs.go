@@ -1,6 +1,8 @@
 package util
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,3 +24,13 @@ func GetFullHostname(ctx *gin.Context) string {
 func Now() primitive.DateTime {
 	return primitive.NewDateTimeFromTime(time.Now())
 }
+
+// NewToken returns a random URL-safe token that is infeasible to guess
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SafeReturnPath checks a path to return to after logging in, returning it if it is a path on the client at
+// origin, or "" if it isn't. Absolute URLs are only accepted if they are on origin, and are turned into paths,
+// so that the login can't be used to redirect users to another site.
+func SafeReturnPath(returnTo string, origin string) string {
+	if returnTo == "" || strings.ContainsAny(returnTo, "\\\r\n\t") {
+		return ""
+	}
+	parsed, err := url.Parse(returnTo)
+	if err != nil {
+		return ""
+	}
+
+	if parsed.Scheme != "" || parsed.Host != "" {
+		originUrl, err := url.Parse(origin)
+		if err != nil || parsed.Scheme != originUrl.Scheme || parsed.Host != originUrl.Host {
+			return ""
+		}
+	} else if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") {
+		return ""
+	}
+
+	// Rebuild the path so that only the path, query and fragment are kept
+	path := parsed.EscapedPath()
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return ""
+	}
+	result := &url.URL{RawPath: path, Path: parsed.Path, RawQuery: parsed.RawQuery, Fragment: parsed.Fragment}
+	return result.String()
+}
@@ -0,0 +1,29 @@
+package util_test
+
+import (
+	"server/util"
+	"testing"
+)
+
+func TestSafeReturnPath(t *testing.T) {
+	origin := "https://jury.durhack.com"
+	cases := map[string]string{
+		"/judge/project/1":                       "/judge/project/1",
+		"/judge?tab=2#top":                       "/judge?tab=2#top",
+		"https://jury.durhack.com/judge":         "/judge",
+		"https://evil.example.com/judge":         "",
+		"http://jury.durhack.com/judge":          "",
+		"//evil.example.com/judge":               "",
+		"/\\evil.example.com":                    "",
+		"judge":                                  "",
+		"javascript:alert(1)":                    "",
+		"https://jury.durhack.com//evil.example": "",
+		"":                                       "",
+	}
+	for returnTo, expected := range cases {
+		actual := util.SafeReturnPath(returnTo, origin)
+		if actual != expected {
+			t.Errorf("SafeReturnPath(%q): expected %q, got %q", returnTo, expected, actual)
+		}
+	}
+}